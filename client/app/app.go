@@ -6,6 +6,7 @@ import (
 	"ethstats/client/config"
 	"ethstats/common/util/cmdutil"
 	"ethstats/common/util/connutil"
+	"ethstats/common/util/promutil"
 	"fmt"
 	"github.com/bitxx/logger"
 	"github.com/bitxx/logger/logbase"
@@ -14,6 +15,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -33,6 +35,13 @@ type App struct {
 	delayTicker *time.Timer
 	pingTicker  *time.Timer
 	procNames   []string
+	connected   atomic.Bool  // logged in to the server
+	nonce       atomic.Value // string, the last challenge of the server signed by the next login
+	scrapeCh    chan []promutil.Sample
+	customCh    chan map[string]float64
+	heartbeats  heartbeats
+	jobs        jobRuns
+	pendingCh   chan struct{} // a heartbeat or a job run is waiting to be sent
 }
 
 func NewApp() *App {
@@ -56,6 +65,9 @@ func NewApp() *App {
 		procNames:  names,
 		readyCh:    make(chan struct{}),
 		pongCh:     make(chan struct{}),
+		scrapeCh:   make(chan []promutil.Sample, 1),
+		customCh:   make(chan map[string]float64, 1),
+		pendingCh:  make(chan struct{}, 1),
 		logger:     logInit,
 	}
 }
@@ -84,11 +96,21 @@ func (a *App) Start() {
 		}
 	}()
 
-	conn, err = connutil.NewDialConn(config.AppConfig.ServerUrl)
+	conn, err = dialServer()
 	if err != nil {
 		a.logger.Warn("dial error: ", err)
 		return
 	}
+	nonce, err := readChallenge(conn)
+	if err != nil {
+		a.logger.Warn("login challenge error: ", err)
+		return
+	}
+	a.nonce.Store(nonce)
+	if err = a.enroll(conn); err != nil {
+		a.logger.Warn("enrollment error: ", err)
+		return
+	}
 
 	for {
 		select {
@@ -97,6 +119,10 @@ func (a *App) Start() {
 				break
 			}
 			a.pingTicker.Reset(PingTime * time.Second)
+			// the server ignores the messages before the login
+			if !a.connected.Load() {
+				break
+			}
 			if err = a.ping(conn); err != nil {
 				a.logger.Warn("requested ping failed: ", err)
 			}
@@ -106,10 +132,14 @@ func (a *App) Start() {
 			a.delayTicker.Reset(time.Duration(config.AppConfig.DelayTime) * time.Second)
 
 			//request login,need here
+			timestamp, signature := sign(a.nonce.Load().(string))
 			login := map[string][]interface{}{
-				"emit": {"hello", map[string]string{
-					"id":     a.appName,
-					"secret": config.AppConfig.Secret,
+				"emit": {"hello", map[string]interface{}{
+					"id":        a.appName,
+					"timestamp": timestamp,
+					"signature": signature,
+					"labels":    config.AppConfig.Labels,
+					"inventory": hostInventory(),
 				}},
 			}
 			err := conn.WriteJSON(login)
@@ -121,9 +151,30 @@ func (a *App) Start() {
 			//read info
 			go a.readLoop(conn)
 		case <-a.readyCh:
-			if err = a.reportErrProc(conn); err != nil {
+			procs := a.checkProcs()
+			if err = a.reportErrProc(conn, procs); err != nil {
 				a.logger.Warn("proc report failed: ", err)
 			}
+			if err = a.reportStatus(conn, procs); err != nil {
+				a.logger.Warn("status report failed: ", err)
+			}
+			a.reportPending(conn)
+		case <-a.pendingCh:
+			a.reportPending(conn)
+		case samples := <-a.scrapeCh:
+			if !a.connected.Load() {
+				break
+			}
+			if err = a.reportScrape(conn, samples); err != nil {
+				a.logger.Warn("scrape report failed: ", err)
+			}
+		case metrics := <-a.customCh:
+			if !a.connected.Load() {
+				break
+			}
+			if err = a.reportCustom(conn, metrics); err != nil {
+				a.logger.Warn("custom metrics report failed: ", err)
+			}
 		case <-interrupt:
 			a.close(conn)
 			isInterrupt = true
@@ -169,6 +220,7 @@ func (a *App) readLoop(conn *connutil.ConnWrapper) {
 		case "ready":
 			//login success
 			a.logger.Info("login success!")
+			a.connected.Store(true)
 			a.readyCh <- struct{}{}
 		case "un-authorization":
 			//login error
@@ -178,6 +230,20 @@ func (a *App) readLoop(conn *connutil.ConnWrapper) {
 				}
 			}
 			return
+		case "challenge":
+			//the nonce of the next login
+			if nonce, ok := parseChallenge(msg["emit"]); ok {
+				a.nonce.Store(nonce)
+			}
+		case "credential":
+			//the key is rotated by the server
+			if key, ok := parseCredential(msg["emit"]); ok {
+				if err := saveCredential(key); err != nil {
+					a.logger.Error("save the rotated credential error: ", err)
+				} else {
+					a.logger.Info("the credential is rotated")
+				}
+			}
 		case "node-pong":
 			//ping pong
 			a.pongCh <- struct{}{}
@@ -224,14 +290,13 @@ func (a *App) ping(conn *connutil.ConnWrapper) error {
 	return conn.WriteJSON(stats)
 }
 
-// reportErrProc
+// checkProcs
 //
-//	@Description: report error proc
+//	@Description: check whether the monitored processes are running
 //	@receiver a
-//	@param conn
-//	@return error
-func (a *App) reportErrProc(conn *connutil.ConnWrapper) error {
-	errProcs := ""
+//	@return map[string]bool proc name => running
+func (a *App) checkProcs() map[string]bool {
+	procs := make(map[string]bool)
 	for _, procName := range a.procNames {
 		if procName == "" {
 			continue
@@ -239,6 +304,23 @@ func (a *App) reportErrProc(conn *connutil.ConnWrapper) error {
 		_, err := cmdutil.RunCmd(fmt.Sprintf("pidof %s", procName))
 		if err != nil {
 			a.logger.Error(err)
+		}
+		procs[procName] = err == nil
+	}
+	return procs
+}
+
+// reportErrProc
+//
+//	@Description: report error proc
+//	@receiver a
+//	@param conn
+//	@param procs
+//	@return error
+func (a *App) reportErrProc(conn *connutil.ConnWrapper, procs map[string]bool) error {
+	errProcs := ""
+	for _, procName := range a.procNames {
+		if running, ok := procs[procName]; ok && !running {
 			errProcs += procName + ","
 		}
 	}
@@ -264,7 +346,37 @@ func (a *App) reportErrProc(conn *connutil.ConnWrapper) error {
 	return nil
 }
 
+// reportStatus
+//
+//	@Description: report the state of all monitored processes and the host metrics
+//	@receiver a
+//	@param conn
+//	@param procs
+//	@return error
+func (a *App) reportStatus(conn *connutil.ConnWrapper, procs map[string]bool) error {
+	status := map[string][]interface{}{
+		"emit": {"node-stats", map[string]interface{}{
+			"id":         config.AppConfig.Name,
+			"clientTime": time.Now().String(),
+			"procs":      procs,
+			"stats":      hostStats(),
+		}},
+	}
+	return conn.WriteJSON(status)
+}
+
+// reportPending sends the heartbeats and the job runs received by the local endpoint
+func (a *App) reportPending(conn *connutil.ConnWrapper) {
+	if err := a.reportHeartbeats(conn); err != nil {
+		a.logger.Warn("heartbeat report failed: ", err)
+	}
+	if err := a.reportJobs(conn); err != nil {
+		a.logger.Warn("job report failed: ", err)
+	}
+}
+
 func (a *App) close(conn *connutil.ConnWrapper) {
+	a.connected.Store(false)
 	if conn != nil {
 		_ = conn.Close()
 	}
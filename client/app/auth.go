@@ -0,0 +1,66 @@
+package app
+
+import (
+	"errors"
+	"ethstats/client/config"
+	"ethstats/common/util/authutil"
+	"ethstats/common/util/connutil"
+	"fmt"
+	"time"
+)
+
+const readTimeout = 10 * time.Second
+
+// readChallenge waits for the first challenge sent by the server after the connection.
+// The servers without the challenge-response login send nothing, they must be upgraded before the clients
+func readChallenge(conn *connutil.ConnWrapper) (string, error) {
+	emit, err := readEmit(conn)
+	if err != nil {
+		return "", fmt.Errorf("%s, the server may be too old for the challenge-response login", err)
+	}
+	nonce, ok := parseChallenge(emit)
+	if !ok {
+		return "", fmt.Errorf("expected a challenge, received %v", emit)
+	}
+	return nonce, nil
+}
+
+// readEmit reads a message before the read loop is started, the caller closes the connection after an error,
+// which ends the read
+func readEmit(conn *connutil.ConnWrapper) ([]interface{}, error) {
+	type result struct {
+		emit []interface{}
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		var msg map[string][]interface{}
+		err := conn.ReadJSON(&msg)
+		ch <- result{emit: msg["emit"], err: err}
+	}()
+	select {
+	case r := <-ch:
+		return r.emit, r.err
+	case <-time.After(readTimeout):
+		return nil, errors.New("no message received")
+	}
+}
+
+// parseChallenge returns the nonce of a challenge message
+func parseChallenge(emit []interface{}) (string, bool) {
+	if len(emit) < 2 || emit[0] != "challenge" {
+		return "", false
+	}
+	payload, ok := emit[1].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	nonce, ok := payload["nonce"].(string)
+	return nonce, ok && nonce != ""
+}
+
+// sign returns the timestamp and the signature of the nonce for the node name with the key of the node
+func sign(nonce string) (int64, string) {
+	timestamp := time.Now().Unix()
+	return timestamp, authutil.Sign(signingSecret(), nonce, config.AppConfig.Name, timestamp)
+}
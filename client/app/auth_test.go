@@ -0,0 +1,35 @@
+package app
+
+import (
+	"ethstats/client/config"
+	"ethstats/common/util/authutil"
+	"testing"
+	"time"
+)
+
+func TestParseChallenge(t *testing.T) {
+	nonce, ok := parseChallenge([]interface{}{"challenge", map[string]interface{}{"nonce": "abc"}})
+	if !ok || nonce != "abc" {
+		t.Fatalf("unexpected nonce %s", nonce)
+	}
+	for _, emit := range [][]interface{}{
+		nil,
+		{"ready"},
+		{"challenge"},
+		{"challenge", "abc"},
+		{"challenge", map[string]interface{}{"nonce": ""}},
+	} {
+		if _, ok := parseChallenge(emit); ok {
+			t.Errorf("%v isn't a challenge", emit)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	config.AppConfig.Name, config.AppConfig.Secret = "geth-01", "123456"
+	defer func() { config.AppConfig.Name, config.AppConfig.Secret = "", "" }()
+	timestamp, signature := sign("abc")
+	if err := authutil.Verify("123456", "abc", "geth-01", timestamp, signature, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"encoding/json"
+	"ethstats/client/config"
+	"ethstats/common/util/connutil"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const DefaultCredentialFile = "files/credential.json"
+
+// credential is the key of the node got by the enrollment, it replaces the shared secret
+type credential struct {
+	NodeID    string    `json:"nodeId"`
+	Key       string    `json:"key"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func credentialFile() string {
+	if config.AppConfig.CredentialFile != "" {
+		return config.AppConfig.CredentialFile
+	}
+	return DefaultCredentialFile
+}
+
+// loadCredential returns the credential of the node name, nil when the node isn't enrolled
+func loadCredential() (*credential, error) {
+	content, err := os.ReadFile(credentialFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cred credential
+	if err = json.Unmarshal(content, &cred); err != nil {
+		return nil, fmt.Errorf("invalid credential file %s: %s", credentialFile(), err)
+	}
+	if cred.NodeID != config.AppConfig.Name || cred.Key == "" {
+		return nil, nil
+	}
+	return &cred, nil
+}
+
+// saveCredential writes the key to a temporary file and renames it, only the owner can read the file
+func saveCredential(key string) error {
+	file := credentialFile()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(credential{NodeID: config.AppConfig.Name, Key: key, UpdatedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err = os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// signingSecret returns the key of the credential file, or the shared secret when the node isn't enrolled.
+// The file is read every time, so the wrap command uses the key rotated by the running client
+func signingSecret() string {
+	if cred, err := loadCredential(); err == nil && cred != nil {
+		return cred.Key
+	}
+	return config.AppConfig.Secret
+}
+
+// enroll exchanges the join token for the key of the node when the join token is configured and
+// the node has no credential yet, it must be called before the login
+func (a *App) enroll(conn *connutil.ConnWrapper) error {
+	if config.AppConfig.JoinToken == "" {
+		return nil
+	}
+	cred, err := loadCredential()
+	if err != nil || cred != nil {
+		return err
+	}
+	msg := map[string][]interface{}{
+		"emit": {"enroll", map[string]string{
+			"id":    config.AppConfig.Name,
+			"token": config.AppConfig.JoinToken,
+		}},
+	}
+	if err = conn.WriteJSON(msg); err != nil {
+		return err
+	}
+	emit, err := readEmit(conn)
+	if err != nil {
+		return err
+	}
+	if len(emit) > 1 && emit[0] == "un-authorization" {
+		return fmt.Errorf("%v", emit[1])
+	}
+	key, ok := parseCredential(emit)
+	if !ok {
+		return fmt.Errorf("expected a credential, received %v", emit)
+	}
+	if err = saveCredential(key); err != nil {
+		return err
+	}
+	a.logger.Infof("node enrolled, the credential is saved to %s", credentialFile())
+	return nil
+}
+
+// parseCredential returns the key of a credential message
+func parseCredential(emit []interface{}) (string, bool) {
+	if len(emit) < 2 || emit[0] != "credential" {
+		return "", false
+	}
+	payload, ok := emit[1].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	key, ok := payload["key"].(string)
+	return key, ok && key != ""
+}
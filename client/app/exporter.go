@@ -0,0 +1,101 @@
+package app
+
+import (
+	"bytes"
+	"ethstats/client/config"
+	"ethstats/common/util/promutil"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const defaultExporterPath = "/metrics"
+
+// StartExporter serves the process and host metrics in the Prometheus text format when exporter.listen is configured.
+// The metrics are collected on every scrape, so the exporter keeps working when the server can't be reached
+func (a *App) StartExporter() {
+	listen := config.ExporterConfig.Listen
+	if listen == "" {
+		return
+	}
+	path := config.ExporterConfig.Path
+	if path == "" {
+		path = defaultExporterPath
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, a.handleMetrics)
+	server := &http.Server{Addr: listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		a.logger.Infof("prometheus exporter listen on http://%s%s", listen, path)
+		if err := server.ListenAndServe(); err != nil {
+			a.logger.Errorf("prometheus exporter error: %s", err)
+		}
+	}()
+}
+
+func (a *App) handleMetrics(w http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	pw := promutil.NewWriter(&buf)
+	labels := exporterLabels()
+
+	connected := 0.0
+	if a.connected.Load() {
+		connected = 1
+	}
+	pw.Write("osmonitor_client_server_connected", promutil.TypeGauge,
+		"Whether the client is logged in to the osmonitor server.", labels, connected)
+
+	procs := a.checkProcs()
+	for _, name := range a.procNames {
+		running, ok := procs[name]
+		if !ok {
+			continue
+		}
+		up := 0.0
+		if running {
+			up = 1
+		}
+		pw.Write("osmonitor_node_process_up", promutil.TypeGauge, "Whether the configured process is running.",
+			withLabel(labels, "proc", name), up)
+	}
+
+	stats := hostStats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pw.Write("osmonitor_node_"+promutil.SanitizeName(name), promutil.TypeGauge,
+			"The host stat "+name+" of the node.", labels, stats[name])
+	}
+
+	if err := pw.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// exporterLabels returns the node name and the labels of the node, like the metrics of the server
+func exporterLabels() map[string]string {
+	labels := map[string]string{"node": config.AppConfig.Name}
+	for k, v := range config.AppConfig.Labels {
+		name := promutil.SanitizeLabel(k)
+		if name == "node" || name == "proc" || len(name) > 1 && name[:2] == "__" {
+			continue
+		}
+		labels[name] = v
+	}
+	return labels
+}
+
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[name] = value
+	return result
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"encoding/json"
+	"ethstats/client/config"
+	"ethstats/common/util/connutil"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxHeartbeatMessage = 1024
+
+// heartbeat is a heartbeat of a check waiting to be sent to the server
+type heartbeat struct {
+	Name    string `json:"name"`
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// heartbeats keeps the latest heartbeat of every check until it's sent, so the heartbeats received
+// while the server can't be reached are sent after the next login
+type heartbeats struct {
+	lock    sync.Mutex
+	pending map[string]heartbeat
+}
+
+func (h *heartbeats) add(beat heartbeat) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.pending == nil {
+		h.pending = make(map[string]heartbeat)
+	}
+	h.pending[beat.Name] = beat
+}
+
+func (h *heartbeats) take() []heartbeat {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	result := make([]heartbeat, 0, len(h.pending))
+	for _, beat := range h.pending {
+		result = append(result, beat)
+	}
+	h.pending = nil
+	return result
+}
+
+// StartHeartbeat serves the local endpoint when heartbeat.listen is configured:
+//   - POST /heartbeat/{name}?status=success|fail&msg= the heartbeat of a check, the request body is used as the message when ?msg= is missing
+//   - POST /job the job run sent by `client wrap`
+func (a *App) StartHeartbeat() {
+	listen := config.HeartbeatConfig.Listen
+	if listen == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /heartbeat/{name}", a.handleHeartbeat)
+	mux.HandleFunc("POST /job", a.handleJob)
+	server := &http.Server{Addr: listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		a.logger.Infof("local endpoint of the heartbeats and the jobs listen on http://%s", listen)
+		if err := server.ListenAndServe(); err != nil {
+			a.logger.Errorf("local endpoint error: %s", err)
+		}
+	}()
+}
+
+func (a *App) handleHeartbeat(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	beat := heartbeat{Name: req.PathValue("name"), Status: query.Get("status"), Message: query.Get("msg")}
+	if beat.Status != "" && beat.Status != "success" && beat.Status != "fail" {
+		http.Error(w, "unknown status "+beat.Status+", expected success or fail", http.StatusBadRequest)
+		return
+	}
+	if beat.Message == "" {
+		body, _ := io.ReadAll(io.LimitReader(req.Body, maxHeartbeatMessage))
+		beat.Message = strings.TrimSpace(string(body))
+	}
+	a.heartbeats.add(beat)
+	select {
+	case a.pendingCh <- struct{}{}:
+	default:
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]bool{"connected": a.connected.Load()})
+}
+
+// reportHeartbeats sends the pending heartbeats to the server, they are kept for the next login when the client isn't logged in
+func (a *App) reportHeartbeats(conn *connutil.ConnWrapper) error {
+	if !a.connected.Load() {
+		return nil
+	}
+	beats := a.heartbeats.take()
+	for i, beat := range beats {
+		msg := map[string][]interface{}{
+			"emit": {"heartbeat", map[string]string{
+				"id":      config.AppConfig.Name,
+				"name":    beat.Name,
+				"status":  beat.Status,
+				"message": beat.Message,
+			}},
+		}
+		if err := conn.WriteJSON(msg); err != nil {
+			for _, rest := range beats[i:] {
+				a.heartbeats.add(rest)
+			}
+			return err
+		}
+		a.logger.Infof("heartbeat %s sent", beat.Name)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"ethstats/client/config"
+	"ethstats/common/util/connutil"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	maxJobOutput      = 4096 //bytes of the stdout and stderr tail sent to the server
+	maxPendingJobRuns = 100
+	jobReportTimeout  = 10 * time.Second
+)
+
+// JobRun is a run of a job started by `client wrap`
+type JobRun struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Command    string     `json:"command,omitempty"`
+	State      string     `json:"state"` // running, succeeded or failed
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Duration   float64    `json:"duration"` // second
+	ExitCode   int        `json:"exitCode"`
+	Error      string     `json:"error,omitempty"`
+	Stdout     string     `json:"stdout,omitempty"`
+	Stderr     string     `json:"stderr,omitempty"`
+}
+
+// RunJob runs the command with the stdin, stdout and stderr of the wrap command and reports the run to the server
+// when it starts and when it exits. The interrupt and terminate signals are passed to the command.
+// It returns the exit code of the command, 127 when the command can't be started
+func RunJob(name string, args []string) int {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	run := &JobRun{
+		ID:        hex.EncodeToString(b),
+		Name:      name,
+		Command:   strings.Join(args, " "),
+		State:     "running",
+		StartedAt: time.Now(),
+	}
+	if err := ReportJob(run); err != nil {
+		fmt.Fprintf(os.Stderr, "osmonitor: report the start of job %s error: %s\n", name, err)
+	}
+
+	stdout, stderr := &tailBuffer{max: maxJobOutput}, &tailBuffer{max: maxJobOutput}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, stdout)
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	err := cmd.Start()
+	if err == nil {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			for sig := range signals {
+				_ = cmd.Process.Signal(sig)
+			}
+		}()
+		err = cmd.Wait()
+		signal.Stop(signals)
+		close(signals)
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Duration = finished.Sub(run.StartedAt).Seconds()
+	run.Stdout = stdout.String()
+	run.Stderr = stderr.String()
+	run.State = "succeeded"
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		run.State = "failed"
+		run.ExitCode = exitErr.ExitCode()
+		if run.ExitCode < 0 {
+			run.Error = exitErr.Error() // killed by a signal
+			run.ExitCode = 128
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				run.ExitCode += int(status.Signal())
+			}
+		}
+	default:
+		run.State = "failed"
+		run.ExitCode = 127
+		run.Error = err.Error()
+		fmt.Fprintf(os.Stderr, "osmonitor: %s\n", err)
+	}
+	if err := ReportJob(run); err != nil {
+		fmt.Fprintf(os.Stderr, "osmonitor: report the result of job %s error: %s\n", name, err)
+	}
+	return run.ExitCode
+}
+
+// ReportJob sends the run to the local endpoint of the running client, the client forwards it to the server.
+// Without the local endpoint, the run is sent with a short-lived connection to the server authenticated with the secret
+func ReportJob(run *JobRun) error {
+	var localErr error
+	if listen := config.HeartbeatConfig.Listen; listen != "" {
+		if localErr = reportJobLocal(listen, run); localErr == nil {
+			return nil
+		}
+	}
+	if err := reportJobDirect(run); err != nil {
+		if localErr != nil {
+			return fmt.Errorf("local client: %s, server: %s", localErr, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func reportJobLocal(listen string, run *JobRun) error {
+	content, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: jobReportTimeout}
+	resp, err := client.Post("http://"+listen+"/job", "application/json", bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// reportJobDirect connects to the server without login, the report signs the challenge of the connection
+func reportJobDirect(run *JobRun) error {
+	conn, err := dialServer()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	nonce, err := readChallenge(conn)
+	if err != nil {
+		return err
+	}
+	timestamp, signature := sign(nonce)
+	report := map[string][]interface{}{
+		"emit": {"job-report", map[string]interface{}{
+			"id":        config.AppConfig.Name,
+			"timestamp": timestamp,
+			"signature": signature,
+			"run":       run,
+		}},
+	}
+	if err = conn.WriteJSON(report); err != nil {
+		return err
+	}
+	result := make(chan error, 1)
+	go func() {
+		var msg map[string][]interface{}
+		// the server sends a new challenge after the report is verified
+		for {
+			if err := conn.ReadJSON(&msg); err != nil {
+				result <- err
+				return
+			}
+			if _, ok := parseChallenge(msg["emit"]); !ok {
+				break
+			}
+		}
+		emit := msg["emit"]
+		if len(emit) > 0 && emit[0] == "job-ack" {
+			result <- nil
+			return
+		}
+		if len(emit) > 1 {
+			result <- fmt.Errorf("%v", emit[1])
+			return
+		}
+		result <- fmt.Errorf("unexpected response %v", msg)
+	}()
+	select {
+	case err = <-result:
+		return err
+	case <-time.After(jobReportTimeout):
+		return errors.New("no response from the server")
+	}
+}
+
+// handleJob queues a run sent by the wrap command
+func (a *App) handleJob(w http.ResponseWriter, req *http.Request) {
+	var run JobRun
+	if err := json.NewDecoder(io.LimitReader(req.Body, 64*1024)).Decode(&run); err != nil {
+		http.Error(w, "invalid job run: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if run.ID == "" || run.Name == "" {
+		http.Error(w, "the id and the name of the job run can't be empty", http.StatusBadRequest)
+		return
+	}
+	a.jobs.add(run)
+	select {
+	case a.pendingCh <- struct{}{}:
+	default:
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// jobRuns keeps the job runs until they are sent, the oldest runs are dropped when there are too many
+type jobRuns struct {
+	lock    sync.Mutex
+	pending []JobRun
+}
+
+func (j *jobRuns) add(runs ...JobRun) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.pending = append(j.pending, runs...)
+	if len(j.pending) > maxPendingJobRuns {
+		j.pending = j.pending[len(j.pending)-maxPendingJobRuns:]
+	}
+}
+
+func (j *jobRuns) take() []JobRun {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	result := j.pending
+	j.pending = nil
+	return result
+}
+
+// reportJobs sends the pending job runs to the server, they are kept for the next login when the client isn't logged in
+func (a *App) reportJobs(conn *connutil.ConnWrapper) error {
+	if !a.connected.Load() {
+		return nil
+	}
+	runs := a.jobs.take()
+	for i, run := range runs {
+		msg := map[string][]interface{}{
+			"emit": {"job-report", map[string]interface{}{
+				"id":  config.AppConfig.Name,
+				"run": run,
+			}},
+		}
+		if err := conn.WriteJSON(msg); err != nil {
+			a.jobs.add(runs[i:]...)
+			return err
+		}
+	}
+	return nil
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	lock sync.Mutex
+	max  int
+	buf  []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+// String returns the kept bytes, a broken utf-8 character at the start is dropped
+func (t *tailBuffer) String() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return strings.ToValidUTF8(string(t.buf), "")
+}
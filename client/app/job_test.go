@@ -0,0 +1,43 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 8}
+	_, _ = b.Write([]byte("hello"))
+	if b.String() != "hello" {
+		t.Fatalf("unexpected tail %q", b.String())
+	}
+	_, _ = b.Write([]byte(" world"))
+	if b.String() != "lo world" {
+		t.Fatalf("unexpected tail %q", b.String())
+	}
+	n, _ := b.Write([]byte(strings.Repeat("x", 20)))
+	if n != 20 || b.String() != "xxxxxxxx" {
+		t.Fatalf("unexpected tail %q of %d bytes", b.String(), n)
+	}
+
+	// the first byte of the kept tail is in the middle of a character
+	b = &tailBuffer{max: 4}
+	_, _ = b.Write([]byte("a世界"))
+	if b.String() != "界" {
+		t.Fatalf("unexpected tail %q", b.String())
+	}
+}
+
+func TestJobRuns(t *testing.T) {
+	var j jobRuns
+	for i := 0; i < maxPendingJobRuns+5; i++ {
+		j.add(JobRun{ID: strings.Repeat("a", i+1)})
+	}
+	runs := j.take()
+	if len(runs) != maxPendingJobRuns || len(runs[0].ID) != 6 {
+		t.Fatalf("unexpected pending runs %d, first %s", len(runs), runs[0].ID)
+	}
+	if len(j.take()) != 0 {
+		t.Fatal("the runs should be taken")
+	}
+}
@@ -0,0 +1,148 @@
+package app
+
+import (
+	"errors"
+	"ethstats/client/config"
+	"ethstats/common/util/connutil"
+	"ethstats/common/util/promutil"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultScrapeInterval   = 30    //second
+	defaultScrapeTimeout    = 10    //second
+	defaultScrapeMaxSamples = 10000 //samples of a target
+	maxScrapeBodySize       = 32 << 20
+)
+
+// StartScraper scrapes the configured local exporters periodically, the latest samples are relayed to the server
+// after the client logged in, the samples scraped while the server can't be reached are dropped
+func (a *App) StartScraper() {
+	cfg := config.ScrapeConfig
+	if len(cfg.Targets) == 0 {
+		return
+	}
+	filter := promutil.NameFilter{Allow: cfg.Allow, Deny: cfg.Deny}
+	if err := filter.Validate(); err != nil {
+		a.logger.Fatalf("scrape config error: %s", err)
+	}
+	names := make(map[string]bool)
+	for _, target := range cfg.Targets {
+		if target.Name == "" || target.Url == "" {
+			a.logger.Fatal("scrape config error: the name and url of a target can't be empty")
+		}
+		if names[target.Name] {
+			a.logger.Fatalf("scrape config error: duplicate target name %s", target.Name)
+		}
+		names[target.Name] = true
+	}
+	interval := time.Duration(cfg.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultScrapeInterval * time.Second
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultScrapeTimeout * time.Second
+	}
+	maxSamples := cfg.MaxSamples
+	if maxSamples <= 0 {
+		maxSamples = defaultScrapeMaxSamples
+	}
+	client := &http.Client{Timeout: timeout}
+
+	go func() {
+		for {
+			var samples []promutil.Sample
+			for _, target := range cfg.Targets {
+				samples = append(samples, a.scrape(client, target, filter, maxSamples)...)
+			}
+			// keep only the latest scrape
+			select {
+			case <-a.scrapeCh:
+			default:
+			}
+			a.scrapeCh <- samples
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// scrape returns the filtered samples of the target with the exporter label, followed by the
+// osmonitor_scrape_up and osmonitor_scrape_duration_seconds samples of the target
+func (a *App) scrape(client *http.Client, target config.ScrapeTarget, filter promutil.NameFilter, maxSamples int) []promutil.Sample {
+	start := time.Now()
+	parsed, err := fetchSamples(client, target.Url)
+	result := make([]promutil.Sample, 0, len(parsed)+2)
+	for _, s := range parsed {
+		if !filter.Match(s.Name) {
+			continue
+		}
+		if len(result) >= maxSamples {
+			a.logger.Warnf("scrape %s: more than %d samples, the rest are dropped", target.Name, maxSamples)
+			break
+		}
+		s.Labels = exporterSampleLabels(s.Labels, target.Name)
+		result = append(result, s)
+	}
+	up := 1.0
+	if err != nil {
+		up = 0
+		a.logger.Warnf("scrape %s error: %s", target.Name, err)
+	}
+	labels := map[string]string{"exporter": target.Name}
+	result = append(result,
+		promutil.Sample{Name: "osmonitor_scrape_up", Labels: labels, Value: up, Type: promutil.TypeGauge},
+		promutil.Sample{Name: "osmonitor_scrape_duration_seconds", Labels: labels, Value: time.Since(start).Seconds(),
+			Type: promutil.TypeGauge},
+	)
+	return result
+}
+
+func fetchSamples(client *http.Client, url string) ([]promutil.Sample, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	samples, err := promutil.Parse(io.LimitReader(resp.Body, maxScrapeBodySize))
+	if err != nil {
+		return nil, errors.New("parse metrics error: " + err.Error())
+	}
+	return samples, nil
+}
+
+// exporterSampleLabels adds the exporter label, an exporter label of the sample is renamed to exported_exporter
+func exporterSampleLabels(labels map[string]string, exporter string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		if k == "exporter" {
+			k = "exported_exporter"
+		}
+		result[k] = v
+	}
+	result["exporter"] = exporter
+	return result
+}
+
+// reportScrape sends the scraped samples to the server
+func (a *App) reportScrape(conn *connutil.ConnWrapper, samples []promutil.Sample) error {
+	report := map[string][]interface{}{
+		"emit": {"scrape-report", map[string]interface{}{
+			"id":         config.AppConfig.Name,
+			"clientTime": time.Now().String(),
+			"samples":    samples,
+		}},
+	}
+	return conn.WriteJSON(report)
+}
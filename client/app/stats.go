@@ -0,0 +1,33 @@
+package app
+
+import (
+	"ethstats/client/config"
+	"os"
+	"runtime"
+	"strconv"
+)
+
+// hostStats collects the host metrics sent with the node status, the names are
+// snake case and end with the unit, e.g. mem_used_percent
+func hostStats() map[string]float64 {
+	stats := map[string]float64{
+		"cpu_count": float64(runtime.NumCPU()),
+	}
+	collectHostStats(stats)
+	return stats
+}
+
+// hostInventory collects the static host facts sent on login
+func hostInventory() map[string]string {
+	inventory := map[string]string{
+		"os":             runtime.GOOS,
+		"arch":           runtime.GOARCH,
+		"cpu_count":      strconv.Itoa(runtime.NumCPU()),
+		"client_version": config.AppConfig.Version,
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		inventory["hostname"] = hostname
+	}
+	collectHostInventory(inventory)
+	return inventory
+}
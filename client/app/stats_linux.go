@@ -0,0 +1,84 @@
+//go:build linux
+
+package app
+
+import (
+	"bufio"
+	"golang.org/x/sys/unix"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// collectHostStats reads the load, memory, uptime and root disk usage from /proc and statfs
+func collectHostStats(stats map[string]float64) {
+	if content, err := os.ReadFile("/proc/loadavg"); err == nil {
+		fields := strings.Fields(string(content))
+		for i, name := range []string{"load1", "load5", "load15"} {
+			if i < len(fields) {
+				if v, err := strconv.ParseFloat(fields[i], 64); err == nil {
+					stats[name] = v
+				}
+			}
+		}
+	}
+
+	if content, err := os.ReadFile("/proc/uptime"); err == nil {
+		if fields := strings.Fields(string(content)); len(fields) > 0 {
+			if v, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				stats["uptime_seconds"] = v
+			}
+		}
+	}
+
+	if f, err := os.Open("/proc/meminfo"); err == nil {
+		mem := make(map[string]float64)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			if v, err := strconv.ParseFloat(fields[1], 64); err == nil {
+				mem[strings.TrimSuffix(fields[0], ":")] = v * 1024 // kB
+			}
+		}
+		_ = f.Close()
+		if total := mem["MemTotal"]; total > 0 {
+			stats["mem_total_bytes"] = total
+			stats["mem_available_bytes"] = mem["MemAvailable"]
+			stats["mem_used_percent"] = round2((total - mem["MemAvailable"]) / total * 100)
+		}
+		if total := mem["SwapTotal"]; total > 0 {
+			stats["swap_used_percent"] = round2((total - mem["SwapFree"]) / total * 100)
+		}
+	}
+
+	var fs unix.Statfs_t
+	if err := unix.Statfs("/", &fs); err == nil && fs.Blocks > 0 {
+		total := float64(fs.Blocks) * float64(fs.Bsize)
+		free := float64(fs.Bavail) * float64(fs.Bsize)
+		stats["disk_total_bytes"] = total
+		stats["disk_free_bytes"] = free
+		stats["disk_used_percent"] = round2((total - free) / total * 100)
+	}
+}
+
+// collectHostInventory reads the kernel release and the distribution name
+func collectHostInventory(inventory map[string]string) {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err == nil {
+		inventory["kernel"] = unix.ByteSliceToString(uts.Release[:])
+	}
+	if content, err := os.ReadFile("/etc/os-release"); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			if value, ok := strings.CutPrefix(line, "PRETTY_NAME="); ok {
+				inventory["distribution"] = strings.Trim(value, `"`)
+			}
+		}
+	}
+}
+
+func round2(v float64) float64 {
+	return float64(int64(v*100+0.5)) / 100
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package app
+
+// collectHostStats only reports the cpu count on other platforms
+func collectHostStats(stats map[string]float64) {}
+
+// collectHostInventory adds nothing on other platforms
+func collectHostInventory(inventory map[string]string) {}
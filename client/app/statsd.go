@@ -0,0 +1,237 @@
+package app
+
+import (
+	"errors"
+	"ethstats/client/config"
+	"ethstats/common/util/connutil"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStatsdFlushInterval = 10 //second
+	maxStatsdPacketSize        = 64 * 1024
+)
+
+// StartStatsd listens for the StatsD line protocol on the configured udp address, the metrics are aggregated
+// and sent to the server as custom metrics every flush interval
+func (a *App) StartStatsd() {
+	listen := config.StatsdConfig.Listen
+	if listen == "" {
+		return
+	}
+	interval := time.Duration(config.StatsdConfig.FlushInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultStatsdFlushInterval * time.Second
+	}
+	conn, err := net.ListenPacket("udp", listen)
+	if err != nil {
+		a.logger.Fatalf("statsd listen error: %s", err)
+	}
+	a.logger.Infof("statsd listen on udp://%s, flush every %s", listen, interval)
+	aggregator := newStatsdAggregator()
+
+	go func() {
+		buf := make([]byte, maxStatsdPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				a.logger.Errorf("statsd read error: %s", err)
+				continue
+			}
+			for _, line := range strings.Split(string(buf[:n]), "\n") {
+				if line = strings.TrimSpace(line); line == "" {
+					continue
+				}
+				if err := aggregator.add(line); err != nil {
+					a.logger.Warnf("statsd invalid line %q from %s: %s", line, addr, err)
+				}
+			}
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		// an empty flush is sent once after the last names were removed, so the server drops them too
+		sent := 0
+		for range ticker.C {
+			metrics := aggregator.flush(interval)
+			if len(metrics) == 0 && sent == 0 {
+				continue
+			}
+			sent = len(metrics)
+			select {
+			case a.customCh <- metrics:
+			default:
+				a.logger.Warn("the last statsd metrics are not sent yet, drop the new ones")
+			}
+		}
+	}()
+}
+
+// reportCustom sends the aggregated statsd metrics to the server
+func (a *App) reportCustom(conn *connutil.ConnWrapper, metrics map[string]float64) error {
+	report := map[string][]interface{}{
+		"emit": {"custom-metrics", map[string]interface{}{
+			"id":         config.AppConfig.Name,
+			"clientTime": time.Now().String(),
+			"metrics":    metrics,
+		}},
+	}
+	return conn.WriteJSON(report)
+}
+
+// statsdAggregator aggregates the statsd metrics of a flush interval, the gauges keep their value across intervals
+// and the counters, timers and sets seen before are reported as 0 when they get no new value
+type statsdAggregator struct {
+	lock     sync.Mutex
+	counters map[string]float64
+	gauges   map[string]float64
+	timers   map[string]*statsdTimer
+	sets     map[string]map[string]struct{}
+}
+
+type statsdTimer struct {
+	values []float64
+	count  float64 // count scaled by the sample rates
+}
+
+func newStatsdAggregator() *statsdAggregator {
+	return &statsdAggregator{
+		counters: make(map[string]float64),
+		gauges:   make(map[string]float64),
+		timers:   make(map[string]*statsdTimer),
+		sets:     make(map[string]map[string]struct{}),
+	}
+}
+
+// add parses a line like name:value|type[|@rate][|#tags], the types are c, g, ms, h and s.
+// The value of a gauge with a sign is a change of the current value, the tags are ignored
+func (g *statsdAggregator) add(line string) error {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" || strings.ContainsAny(name, " \t|") {
+		return errors.New("invalid metric name")
+	}
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return errors.New("missing metric type")
+	}
+	value, typ := fields[0], fields[1]
+	rate := 1.0
+	for _, field := range fields[2:] {
+		if strings.HasPrefix(field, "@") {
+			r, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || r <= 0 || r > 1 {
+				return errors.New("invalid sample rate")
+			}
+			rate = r
+		}
+	}
+
+	if typ == "s" {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		if g.sets[name] == nil {
+			g.sets[name] = make(map[string]struct{})
+		}
+		g.sets[name][value] = struct{}{}
+		return nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return errors.New("invalid value")
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	switch typ {
+	case "c":
+		g.counters[name] += v / rate
+	case "g":
+		if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+			g.gauges[name] += v
+		} else {
+			g.gauges[name] = v
+		}
+	case "ms", "h":
+		t := g.timers[name]
+		if t == nil {
+			t = &statsdTimer{}
+			g.timers[name] = t
+		}
+		t.values = append(t.values, v)
+		t.count += 1 / rate
+	default:
+		return errors.New("unknown metric type " + typ)
+	}
+	return nil
+}
+
+// flush returns the aggregates of the interval and starts a new interval:
+//   - counter: name (count of the interval) and name.rate (per second)
+//   - gauge: name
+//   - timer: name.count, name.mean, name.min, name.max, name.p50, name.p90 and name.p99
+//   - set: name (count of the unique values)
+//
+// A counter, timer or set without samples in the interval is reported as 0 once and then removed,
+// so the names that are no longer sent don't stay forever
+func (g *statsdAggregator) flush(interval time.Duration) map[string]float64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	result := make(map[string]float64)
+	for name, count := range g.counters {
+		result[name] = count
+		result[name+".rate"] = count / interval.Seconds()
+		if count == 0 {
+			delete(g.counters, name)
+		} else {
+			g.counters[name] = 0
+		}
+	}
+	for name, v := range g.gauges {
+		result[name] = v
+	}
+	for name, t := range g.timers {
+		result[name+".count"] = t.count
+		if len(t.values) > 0 {
+			sort.Float64s(t.values)
+			sum := 0.0
+			for _, v := range t.values {
+				sum += v
+			}
+			result[name+".mean"] = sum / float64(len(t.values))
+			result[name+".min"] = t.values[0]
+			result[name+".max"] = t.values[len(t.values)-1]
+			result[name+".p50"] = percentile(t.values, 50)
+			result[name+".p90"] = percentile(t.values, 90)
+			result[name+".p99"] = percentile(t.values, 99)
+		}
+		if t.count == 0 {
+			delete(g.timers, name)
+		} else {
+			g.timers[name] = &statsdTimer{}
+		}
+	}
+	for name, values := range g.sets {
+		result[name] = float64(len(values))
+		if len(values) == 0 {
+			delete(g.sets, name)
+		} else {
+			g.sets[name] = make(map[string]struct{})
+		}
+	}
+	return result
+}
+
+// percentile returns the nearest-rank percentile of the sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
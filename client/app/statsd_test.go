@@ -0,0 +1,73 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsdAggregator(t *testing.T) {
+	g := newStatsdAggregator()
+	lines := []string{
+		"api.requests:1|c",
+		"api.requests:2|c|@0.5",
+		"queue.size:10|g",
+		"queue.size:-3|g",
+		"db.query:10|ms",
+		"db.query:30|ms|#table:users",
+		"db.query:20|h",
+		"users.online:alice|s",
+		"users.online:bob|s",
+		"users.online:alice|s",
+	}
+	for _, line := range lines {
+		if err := g.add(line); err != nil {
+			t.Fatalf("add %s: %s", line, err)
+		}
+	}
+	expected := map[string]float64{
+		"api.requests":      5,
+		"api.requests.rate": 0.5,
+		"queue.size":        7,
+		"db.query.count":    3,
+		"db.query.mean":     20,
+		"db.query.min":      10,
+		"db.query.max":      30,
+		"db.query.p50":      20,
+		"db.query.p90":      30,
+		"db.query.p99":      30,
+		"users.online":      2,
+	}
+	result := g.flush(10 * time.Second)
+	for name, v := range expected {
+		if result[name] != v {
+			t.Errorf("%s: expected %v, got %v", name, v, result[name])
+		}
+	}
+	if len(result) != len(expected) {
+		t.Errorf("unexpected metrics %v", result)
+	}
+
+	// a new interval reports 0 for the seen counters, timers and sets and keeps the gauges
+	result = g.flush(10 * time.Second)
+	if result["api.requests"] != 0 || result["db.query.count"] != 0 || result["users.online"] != 0 || result["queue.size"] != 7 {
+		t.Errorf("unexpected metrics of an empty interval %v", result)
+	}
+	if _, ok := result["db.query.mean"]; ok {
+		t.Error("an empty timer must not report the mean")
+	}
+
+	// the idle names are removed after they reported 0 once
+	result = g.flush(10 * time.Second)
+	if len(result) != 1 || result["queue.size"] != 7 {
+		t.Errorf("expected only the gauge after an idle interval, got %v", result)
+	}
+	if len(g.counters) != 0 || len(g.timers) != 0 || len(g.sets) != 0 {
+		t.Errorf("expected the idle names to be removed, got %v %v %v", g.counters, g.timers, g.sets)
+	}
+
+	for _, line := range []string{"a", "a:1", ":1|c", "a:x|c", "a:1|x", "a:1|c|@2", "a b:1|c"} {
+		if err := g.add(line); err == nil {
+			t.Errorf("expected error of %q", line)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"crypto/tls"
+	"ethstats/client/config"
+	"ethstats/common/util/connutil"
+	"ethstats/common/util/tlsutil"
+	"sync"
+)
+
+// clientCert is the tls client certificate, loaded by the first dial
+var clientCert struct {
+	lock     sync.Mutex
+	reloader *tlsutil.CertReloader
+}
+
+// dialTLSConfig returns the tls config of the connection to the server, nil when the tls section isn't configured.
+// The client certificate is checked on every dial, so a renewed certificate is used after the next reconnection,
+// the loaded certificate is still used when the renewed files are invalid
+func dialTLSConfig() (*tls.Config, error) {
+	c := config.TLSConfig
+	if c.CaFile == "" && c.CertFile == "" && c.ServerName == "" && !c.InsecureSkipVerify {
+		return nil, nil
+	}
+	result := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CaFile != "" {
+		pool, err := tlsutil.LoadCertPool(c.CaFile)
+		if err != nil {
+			return nil, err
+		}
+		result.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		reloader, err := loadClientCert(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		result.GetClientCertificate = reloader.GetClientCertificate
+	}
+	return result, nil
+}
+
+// loadClientCert loads the client certificate on the first dial, and reloads it on the next dials when the files changed
+func loadClientCert(certFile, keyFile string) (*tlsutil.CertReloader, error) {
+	clientCert.lock.Lock()
+	defer clientCert.lock.Unlock()
+	if clientCert.reloader == nil {
+		reloader, err := tlsutil.NewCertReloader(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		clientCert.reloader = reloader
+		return reloader, nil
+	}
+	// the error is ignored, the loaded certificate is kept until the renewed files are valid
+	_, _ = clientCert.reloader.Reload()
+	return clientCert.reloader, nil
+}
+
+// dialServer connects to the server url of the config
+func dialServer() (*connutil.ConnWrapper, error) {
+	tlsConfig, err := dialTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return connutil.NewDialConn(config.AppConfig.ServerUrl, tlsConfig)
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"ethstats/client/config"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate of the common name and its key
+func writeClientCert(t *testing.T, certFile, keyFile, name string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDialTLSConfigReloadsClientCert(t *testing.T) {
+	saved := *config.TLSConfig
+	t.Cleanup(func() {
+		*config.TLSConfig = saved
+		clientCert.reloader = nil
+	})
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	writeClientCert(t, certFile, keyFile, "geth-01")
+	config.TLSConfig.CertFile, config.TLSConfig.KeyFile = certFile, keyFile
+
+	commonName := func() string {
+		t.Helper()
+		tlsConfig, err := dialTLSConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := tlsConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+	if name := commonName(); name != "geth-01" {
+		t.Fatalf("expected the certificate of geth-01, got %s", name)
+	}
+
+	// the renewed certificate is used by the next dial, an invalid one keeps the loaded certificate
+	writeClientCert(t, certFile, keyFile, "geth-01-renewed")
+	later := time.Now().Add(time.Minute)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if name := commonName(); name != "geth-01-renewed" {
+		t.Errorf("expected the renewed certificate, got %s", name)
+	}
+	if err := os.WriteFile(keyFile, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if name := commonName(); name != "geth-01-renewed" {
+		t.Errorf("expected the loaded certificate to be kept, got %s", name)
+	}
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"errors"
 	"ethstats/client/cmd/run"
+	"ethstats/client/cmd/wrap"
 	"github.com/spf13/cobra"
 	"os"
 )
@@ -23,7 +24,7 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
-	rootCmd.AddCommand(run.StartCmd)
+	rootCmd.AddCommand(run.StartCmd, wrap.WrapCmd)
 }
 
 // Execute : apply commands
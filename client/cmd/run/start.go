@@ -8,6 +8,7 @@ import (
 	"github.com/bitxx/load-config/source/file"
 	"github.com/spf13/cobra"
 	"log"
+	"strings"
 )
 
 var (
@@ -23,11 +24,15 @@ const (
 	delayTime = "delay-time"
 	isPing    = "is-ping"
 	procNames = "proc-names"
+	label     = "label"
 	logPath   = "log-path"
 	logLevel  = "log-level"
 	logStdout = "log-stdout"
 	logType   = "log-type"
 	logCap    = "log-cap"
+
+	exporterListen = "exporter-listen"
+	joinToken      = "join-token"
 )
 
 func init() {
@@ -63,6 +68,18 @@ func init() {
 			if procName, _ := flag.GetString(procNames); procName != "" {
 				config.AppConfig.ProcNames = procName
 			}
+			if labels, _ := flag.GetStringArray(label); len(labels) > 0 {
+				if config.AppConfig.Labels == nil {
+					config.AppConfig.Labels = make(map[string]string)
+				}
+				for _, l := range labels {
+					k, v, ok := strings.Cut(l, "=")
+					if !ok || k == "" {
+						log.Fatalf("invalid label %s, expected key=value", l)
+					}
+					config.AppConfig.Labels[k] = v
+				}
+			}
 			if logPath, _ := flag.GetString(logPath); logPath != "" && config.LoggerConfig.Path == "" {
 				config.LoggerConfig.Path = logPath
 			}
@@ -78,11 +95,17 @@ func init() {
 			if logCap, _ := flag.GetUint(logCap); logCap > 0 && config.LoggerConfig.Cap <= 0 {
 				config.LoggerConfig.Cap = logCap
 			}
+			if exporterListen, _ := flag.GetString(exporterListen); exporterListen != "" {
+				config.ExporterConfig.Listen = exporterListen
+			}
 			if config.AppConfig.Name == "" {
 				log.Fatal("param name can't empty")
 			}
-			if config.AppConfig.Secret == "" {
-				log.Fatal("param secret can't empty")
+			if joinToken, _ := flag.GetString(joinToken); joinToken != "" {
+				config.AppConfig.JoinToken = joinToken
+			}
+			if config.AppConfig.Secret == "" && config.AppConfig.JoinToken == "" {
+				log.Fatal("param secret or joinToken can't empty")
 			}
 			if config.AppConfig.ServerUrl == "" {
 				log.Fatal("param serverUrl can't empty")
@@ -102,17 +125,25 @@ func init() {
 	cmd.Uint(delayTime, 60, "business data transmission interval time")
 	cmd.Bool(isPing, false, "turn on/off ping function")
 	cmd.String(procNames, "", "monitor proc name, use ',' split multiple proc name")
+	cmd.StringArray(label, nil, "node label key=value, can be repeated, override the labels of the configuration file")
 	cmd.String(logPath, "", "log path")
 	cmd.String(logLevel, "trace", "log level")
 	cmd.String(logStdout, "default", "default,file")
 	cmd.String(logType, "default", "default、zap、logrus")
 	cmd.Uint(logCap, 50, "log cap")
+	cmd.String(exporterListen, "", "listen address of the local prometheus exporter, e.g. 127.0.0.1:9105")
+	cmd.String(joinToken, "", "one-time join token to enroll the node, created by `server credential join`")
 }
 
 func run() error {
 	logoContent := []byte{10, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 10, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 87, 101, 108, 99, 111, 109, 101, 32, 116, 111, 32, 117, 115, 101, 32, 111, 115, 109, 111, 110, 105, 116, 111, 114, 32, 99, 108, 105, 101, 110, 116, 10, 10, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 95, 111, 111, 79, 111, 111, 95, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 111, 56, 56, 56, 56, 56, 56, 56, 111, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 56, 56, 34, 32, 46, 32, 34, 56, 56, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 40, 124, 32, 45, 95, 45, 32, 124, 41, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 79, 92, 32, 32, 61, 32, 32, 47, 79, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 95, 95, 95, 95, 47, 96, 45, 45, 45, 39, 92, 95, 95, 95, 95, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 46, 39, 32, 32, 92, 92, 124, 32, 32, 32, 32, 32, 124, 47, 47, 32, 32, 96, 46, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 47, 32, 32, 92, 92, 124, 124, 124, 32, 32, 58, 32, 32, 124, 124, 124, 47, 47, 32, 32, 92, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 47, 32, 32, 95, 124, 124, 124, 124, 124, 32, 45, 58, 45, 32, 124, 124, 124, 124, 124, 45, 32, 32, 92, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 124, 32, 32, 32, 124, 32, 92, 92, 92, 32, 32, 45, 32, 32, 47, 47, 47, 32, 124, 32, 32, 32, 124, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 124, 32, 92, 95, 124, 32, 32, 39, 39, 92, 45, 45, 45, 47, 39, 39, 32, 32, 124, 32, 32, 32, 124, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 92, 32, 32, 46, 45, 92, 95, 95, 32, 32, 96, 45, 96, 32, 32, 95, 95, 95, 47, 45, 46, 32, 47, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 95, 95, 95, 96, 46, 32, 46, 39, 32, 32, 47, 45, 45, 46, 45, 45, 92, 32, 32, 96, 46, 32, 46, 32, 95, 95, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 46, 34, 34, 32, 39, 60, 32, 32, 96, 46, 95, 95, 95, 92, 95, 60, 124, 62, 95, 47, 95, 95, 95, 46, 39, 32, 32, 62, 39, 34, 34, 46, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 124, 32, 124, 32, 58, 32, 32, 96, 45, 32, 92, 96, 46, 59, 96, 92, 32, 95, 32, 47, 96, 59, 46, 96, 47, 32, 45, 32, 96, 32, 58, 32, 124, 32, 124, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 92, 32, 32, 92, 32, 96, 45, 46, 32, 32, 32, 92, 95, 32, 95, 95, 92, 32, 47, 95, 95, 32, 95, 47, 32, 32, 32, 46, 45, 96, 32, 47, 32, 32, 47, 10, 32, 32, 32, 32, 61, 61, 61, 61, 61, 61, 96, 45, 46, 95, 95, 95, 95, 96, 45, 46, 95, 95, 95, 92, 95, 95, 95, 95, 95, 47, 95, 95, 95, 46, 45, 96, 95, 95, 95, 95, 46, 45, 39, 61, 61, 61, 61, 61, 61, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 96, 61, 45, 45, 45, 61, 39, 10, 32, 32, 32, 32, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 94, 10, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 66, 117, 100, 100, 104, 97, 32, 98, 108, 101, 115, 115, 32, 32, 32, 32, 32, 32, 66, 117, 103, 32, 98, 108, 101, 115, 115, 10, 10, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 61, 10}
 	fmt.Println(textutils.Red(string(logoContent)))
 
-	app.NewApp().Start()
+	a := app.NewApp()
+	a.StartExporter()
+	a.StartScraper()
+	a.StartStatsd()
+	a.StartHeartbeat()
+	a.Start()
 	return nil
 }
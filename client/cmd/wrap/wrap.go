@@ -0,0 +1,48 @@
+package wrap
+
+import (
+	"errors"
+	"ethstats/client/app"
+	"ethstats/client/config"
+	"github.com/bitxx/load-config/source/file"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var (
+	configPath string
+	WrapCmd    *cobra.Command
+)
+
+const (
+	name = "name"
+)
+
+func init() {
+	WrapCmd = &cobra.Command{
+		Use:          "wrap --name <job> -- <command> [args...]",
+		Short:        "run a command and report the run to the server",
+		Example:      "client wrap -c settings.yml --name backup -- /usr/local/bin/backup.sh --full",
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			config.Setup(
+				file.NewSource(file.WithPath(configPath)),
+			)
+			if n, _ := cmd.Flags().GetString(name); n == "" {
+				return errors.New("param name can't empty")
+			}
+			if config.AppConfig.Name == "" || config.AppConfig.Secret == "" && config.AppConfig.JoinToken == "" {
+				return errors.New("app.name and app.secret or app.joinToken of the configuration file can't empty")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			n, _ := cmd.Flags().GetString(name)
+			os.Exit(app.RunJob(n, args))
+		},
+	}
+	WrapCmd.Flags().StringVarP(&configPath, "config", "c", "", "client configuration file")
+	WrapCmd.Flags().String(name, "", "job name")
+	WrapCmd.Flags().SetInterspersed(false) // the flags after the command belong to the command
+}
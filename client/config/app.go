@@ -8,6 +8,10 @@ type App struct {
 	ProcNames string
 	IsPing    bool
 	DelayTime uint
+	Labels    map[string]string // e.g. region, role, owner, env; sent to the server on login
+
+	JoinToken      string // one-time token to enroll the node and get its own key, used when the credential file is missing
+	CredentialFile string // the key of the node, default files/credential.json
 }
 
 var AppConfig = new(App)
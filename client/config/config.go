@@ -8,8 +8,13 @@ import (
 )
 
 type Config struct {
-	App       *App    `yaml:"app"`
-	Logger    *Logger `yaml:"logger"`
+	App       *App       `yaml:"app"`
+	Logger    *Logger    `yaml:"logger"`
+	Exporter  *Exporter  `yaml:"exporter"`
+	Scrape    *Scrape    `yaml:"scrape"`
+	Statsd    *Statsd    `yaml:"statsd"`
+	Heartbeat *Heartbeat `yaml:"heartbeat"`
+	TLS       *TLS       `yaml:"tls"`
 	callbacks []func()
 }
 
@@ -39,6 +44,11 @@ func Setup(s source.Source,
 	_cfg := &Config{
 		App:       AppConfig,
 		Logger:    LoggerConfig,
+		Exporter:  ExporterConfig,
+		Scrape:    ScrapeConfig,
+		Statsd:    StatsdConfig,
+		Heartbeat: HeartbeatConfig,
+		TLS:       TLSConfig,
 		callbacks: fs,
 	}
 	var err error
@@ -0,0 +1,9 @@
+package config
+
+// Exporter is the local Prometheus exporter of the client
+type Exporter struct {
+	Listen string // e.g. 127.0.0.1:9105, empty to disable
+	Path   string // default /metrics
+}
+
+var ExporterConfig = new(Exporter)
@@ -0,0 +1,8 @@
+package config
+
+// Heartbeat is the local endpoint of the client for the heartbeats of the cron jobs
+type Heartbeat struct {
+	Listen string // e.g. 127.0.0.1:9106, empty to disable
+}
+
+var HeartbeatConfig = new(Heartbeat)
@@ -0,0 +1,19 @@
+package config
+
+// Scrape is the local exporters scraped by the client, the samples are relayed to the server
+type Scrape struct {
+	Interval   uint     // second, default 30
+	Timeout    uint     // second, default 10
+	MaxSamples int      // max count of the samples of a target, default 10000
+	Allow      []string // metric name patterns, e.g. node_cpu_*; all metrics are allowed when it's empty
+	Deny       []string // metric name patterns
+	Targets    []ScrapeTarget
+}
+
+// ScrapeTarget is a local exporter
+type ScrapeTarget struct {
+	Name string // added to the samples as the exporter label
+	Url  string // e.g. http://127.0.0.1:9100/metrics
+}
+
+var ScrapeConfig = new(Scrape)
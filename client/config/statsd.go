@@ -0,0 +1,9 @@
+package config
+
+// Statsd is the local StatsD listener of the client, the aggregates are sent to the server as custom metrics
+type Statsd struct {
+	Listen        string // udp address, e.g. 127.0.0.1:8125, empty to disable
+	FlushInterval uint   // second, default 10
+}
+
+var StatsdConfig = new(Statsd)
@@ -0,0 +1,12 @@
+package config
+
+// TLS is used by the wss:// server url
+type TLS struct {
+	CaFile             string // PEM encoded CA certificates of the server certificate, the system CAs are used when empty
+	CertFile           string // client certificate, required when the server requires mutual TLS
+	KeyFile            string
+	ServerName         string // overrides the host name of the server url used to verify the server certificate
+	InsecureSkipVerify bool   // only for tests
+}
+
+var TLSConfig = new(TLS)
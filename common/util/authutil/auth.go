@@ -0,0 +1,60 @@
+package authutil
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// MaxClockSkew is the max difference between the timestamp of a signature and the time of the server
+const MaxClockSkew = 5 * time.Minute
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpiredSignature = errors.New("the timestamp of the signature is out of the allowed clock skew")
+)
+
+// NewNonce returns a random hex nonce of 32 bytes
+func NewNonce() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Sign returns the hex HMAC-SHA256 of nonce \n nodeID \n timestamp with the secret, the timestamp is in unix seconds.
+// The nonce is hex and the timestamp is decimal, so the separators keep the node id apart from both
+func Sign(secret, nonce, nodeID string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(signInput(nonce, nodeID, timestamp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signInput returns the signed bytes, e.g. "9f2c...\ngeth-01\n1700000000"
+func signInput(nonce, nodeID string, timestamp int64) []byte {
+	return []byte(nonce + "\n" + nodeID + "\n" + strconv.FormatInt(timestamp, 10))
+}
+
+// Verify checks the signature in constant time and the timestamp against now
+func Verify(secret, nonce, nodeID string, timestamp int64, signature string, now time.Time) error {
+	if nonce == "" {
+		return ErrInvalidSignature
+	}
+	if !Equal(Sign(secret, nonce, nodeID, timestamp), signature) {
+		return ErrInvalidSignature
+	}
+	skew := now.Sub(time.Unix(timestamp, 0))
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		return ErrExpiredSignature
+	}
+	return nil
+}
+
+// Equal compares the strings in constant time
+func Equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
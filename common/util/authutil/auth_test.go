@@ -0,0 +1,66 @@
+package authutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	nonce := NewNonce()
+	if len(nonce) != 64 || nonce == NewNonce() {
+		t.Fatalf("unexpected nonce %s", nonce)
+	}
+	signature := Sign("123456", nonce, "geth-01", now.Unix())
+	if err := Verify("123456", nonce, "geth-01", now.Unix(), signature, now); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name      string
+		secret    string
+		nonce     string
+		nodeID    string
+		timestamp int64
+		expected  error
+	}{
+		{"secret", "654321", nonce, "geth-01", now.Unix(), ErrInvalidSignature},
+		{"nonce", "123456", NewNonce(), "geth-01", now.Unix(), ErrInvalidSignature},
+		{"empty nonce", "123456", "", "geth-01", now.Unix(), ErrInvalidSignature},
+		{"node", "123456", nonce, "geth-02", now.Unix(), ErrInvalidSignature},
+		{"timestamp", "123456", nonce, "geth-01", now.Unix() + 1, ErrInvalidSignature},
+	}
+	for _, c := range cases {
+		if err := Verify(c.secret, c.nonce, c.nodeID, c.timestamp, signature, now); err != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, err)
+		}
+	}
+
+	old := now.Add(-MaxClockSkew - time.Second).Unix()
+	if err := Verify("123456", nonce, "geth-01", old, Sign("123456", nonce, "geth-01", old), now); err != ErrExpiredSignature {
+		t.Fatalf("expected expired signature, got %v", err)
+	}
+	future := now.Add(MaxClockSkew + time.Second).Unix()
+	if err := Verify("123456", nonce, "geth-01", future, Sign("123456", nonce, "geth-01", future), now); err != ErrExpiredSignature {
+		t.Fatalf("expected expired signature, got %v", err)
+	}
+}
+
+func TestSignInput(t *testing.T) {
+	if input := string(signInput("abc", "geth-01", 1700000000)); input != "abc\ngeth-01\n1700000000" {
+		t.Fatalf("unexpected signed input %q", input)
+	}
+	// HMAC-SHA256 of "abc\ngeth-01\n1700000000" with the key "123456"
+	if signature := Sign("123456", "abc", "geth-01", 1700000000); signature != "987f6a7fe21638a5adf211adac5b887d9b2c666c5faf78e35e7310b68ab3d4ae" {
+		t.Fatalf("unexpected signature %s", signature)
+	}
+	// the boundary between the node id and the timestamp can't be moved
+	if Sign("123456", "abc", "geth-0", 11700000000) == Sign("123456", "abc", "geth-01", 1700000000) {
+		t.Fatal("different node ids and timestamps have the same signature")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	if !Equal("abc", "abc") || Equal("abc", "abd") || Equal("abc", "ab") || Equal("", "a") {
+		t.Fatal("unexpected compare result")
+	}
+}
@@ -1,6 +1,7 @@
 package connutil
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"github.com/gorilla/websocket"
@@ -17,11 +18,13 @@ type ConnWrapper struct {
 }
 
 // NewDialConn 不加读写锁，执行时会出现问题
-func NewDialConn(url string) (*ConnWrapper, error) {
+// tlsConfig is used by the wss:// urls, e.g. a custom CA and a client certificate; nil uses the system CAs
+func NewDialConn(url string, tlsConfig *tls.Config) (*ConnWrapper, error) {
 	//发现有的节点因为网络问题，默认的45秒有点短，导致总timeout，这里先固定成120s，后续根据需要再考虑要不要可配置化
 	dial := websocket.Dialer{
 		Proxy:            http.ProxyFromEnvironment,
 		HandshakeTimeout: 120 * time.Second,
+		TLSClientConfig:  tlsConfig,
 	}
 	c, _, err := dial.Dial(url, nil)
 	if err != nil {
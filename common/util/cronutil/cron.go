@@ -0,0 +1,198 @@
+package cronutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next activation time after the given time
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// field is the range and the names of a cron field
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	descriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// Parse parses a standard five field cron expression: minute hour day-of-month month day-of-week.
+// The fields support *, lists, ranges, steps and the english names of months and weekdays, e.g. "0 9 * * mon-fri".
+// The descriptors @yearly, @monthly, @weekly, @daily, @hourly and "@every <duration>" are supported too.
+// Like the classic cron, a time matches when both day fields are restricted and one of them matches
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %s", spec, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("invalid cron expression %q: the interval must be at least 1s", spec)
+		}
+		return every(d.Truncate(time.Second)), nil
+	}
+	if expr, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = expr
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, found %d", spec, len(fields))
+	}
+
+	s := &specSchedule{}
+	var err error
+	if s.minute, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], domField); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dowField); err != nil {
+		return nil, err
+	}
+	// 7 is sunday too
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*" || strings.HasPrefix(fields[2], "*/")
+	s.dowStar = fields[4] == "*" || strings.HasPrefix(fields[4], "*/")
+	return s, nil
+}
+
+// parseField parses a comma separated list of *, values, ranges and steps into a bit set
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q of the %s field", stepStr, f.name)
+			}
+		}
+		start, end := f.min, f.max
+		switch {
+		case rng == "*":
+		default:
+			lo, hi, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = f.value(lo); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = f.value(hi); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				end = f.max
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q of the %s field", rng, f.name)
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q of the %s field, expected %d-%d", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// specSchedule is a parsed cron expression, every field is a bit set of the allowed values
+type specSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// Next returns the first matching minute after t, in the location of t.
+// The zero time is returned when nothing matches within five years, e.g. for "0 0 30 2 *"
+func (s *specSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) { // the clock was set back, e.g. the end of the daylight saving time
+				next = t.Add(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *specSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// every is a fixed interval schedule
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e)).Truncate(time.Second)
+}
@@ -0,0 +1,54 @@
+package cronutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	cases := []struct {
+		spec string
+		from time.Time
+		next time.Time
+	}{
+		{"0 9 * * *", time.Date(2024, 5, 1, 8, 59, 30, 0, shanghai), time.Date(2024, 5, 1, 9, 0, 0, 0, shanghai)},
+		{"0 9 * * *", time.Date(2024, 5, 1, 9, 0, 0, 0, shanghai), time.Date(2024, 5, 2, 9, 0, 0, 0, shanghai)},
+		{"*/15 * * * *", time.Date(2024, 5, 1, 10, 7, 0, 0, time.UTC), time.Date(2024, 5, 1, 10, 15, 0, 0, time.UTC)},
+		{"30 8 * * mon-fri", time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC), time.Date(2024, 5, 6, 8, 30, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 5, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * fri", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 feb *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 1, 30, 0, 0, time.UTC)},
+		// 02:30 doesn't exist on the day the daylight saving time starts
+		{"30 2 * * *", time.Date(2024, 3, 9, 3, 0, 0, 0, newYork), time.Date(2024, 3, 11, 2, 30, 0, 0, newYork)},
+		{"0 0 30 2 *", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.spec)
+		if err != nil {
+			t.Fatalf("parse %q: %s", c.spec, err)
+		}
+		if next := s.Next(c.from); !next.Equal(c.next) {
+			t.Errorf("%q after %s: expected %s, got %s", c.spec, c.from, c.next, next)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *",
+		"* * * * 8", "5-1 * * * *", "*/0 * * * *", "* * * * xyz", "@every 10ms", "@every x"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
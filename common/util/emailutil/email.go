@@ -1,10 +1,10 @@
 package emailutil
 
 import (
-	"crypto/tls"
 	"errors"
 	"ethstats/server/config"
 	"gopkg.in/gomail.v2"
+	"io"
 	"strings"
 	"sync"
 )
@@ -13,45 +13,65 @@ var (
 	wlock sync.Mutex
 )
 
+// DefaultOptions returns the smtp options of the email config
+func DefaultOptions() Options {
+	return Options{
+		Host:       config.EmailConfig.Host,
+		Port:       config.EmailConfig.Port,
+		Username:   config.EmailConfig.Username,
+		Password:   config.EmailConfig.Password,
+		TLSMode:    config.EmailConfig.TlsMode,
+		SkipVerify: config.EmailConfig.SkipVerify,
+		CAFile:     config.EmailConfig.CaFile,
+		AuthMethod: config.EmailConfig.AuthMethod,
+	}
+}
+
 func SendEmailDefault(subject, content string) error {
-	return SendEmail(config.EmailConfig.ToEmail, config.EmailConfig.SubjectPrefix+" "+subject, content,
-		config.EmailConfig.Username, config.EmailConfig.FromEmail, config.EmailConfig.Host,
-		config.EmailConfig.Password, config.EmailConfig.ContentType, config.EmailConfig.Port)
+	return SendEmailWithOptions(DefaultOptions(), config.EmailConfig.ToEmail, config.EmailConfig.SubjectPrefix+" "+subject, content,
+		config.EmailConfig.FromEmail, config.EmailConfig.ContentType)
 }
 
 // SendEmail send to notification
 func SendEmail(toEmailList, subject, content string, username, fromEmail, host, password, contentType string, port int) error {
-	if len(toEmailList) <= 1 || subject == "" || content == "" || username == "" || fromEmail == "" || host == "" || password == "" || contentType == "" || port <= 0 {
+	opts := DefaultOptions()
+	opts.Host, opts.Port, opts.Username, opts.Password = host, port, username, password
+	return SendEmailWithOptions(opts, toEmailList, subject, content, fromEmail, contentType)
+}
+
+// SendEmailWithOptions send to notification with the given smtp options, toEmailList is split by ','
+func SendEmailWithOptions(opts Options, toEmailList, subject, content, fromEmail, contentType string) error {
+	return SendEmailAlternative(opts, toEmailList, subject, fromEmail, contentType, content, "", "")
+}
+
+// SendEmailAlternative send to notification with an alternative body, e.g. text/plain and text/html
+func SendEmailAlternative(opts Options, toEmailList, subject, fromEmail, contentType, content, altContentType, altContent string) error {
+	var emails []string
+	for _, v := range strings.Split(toEmailList, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			emails = append(emails, v)
+		}
+	}
+	if len(emails) <= 0 || subject == "" || content == "" || fromEmail == "" || opts.Host == "" || contentType == "" || opts.Port <= 0 {
 		return errors.New("param init error,not send email")
 	}
 	wlock.Lock()
 	defer wlock.Unlock()
 
-	emailSlips := strings.Split(toEmailList, ",")
-	var emails []string
-	for _, v := range emailSlips {
-		emails = append(emails, v)
-	}
-
-	// smtp 配置
-	d := gomail.NewDialer(host, port, username, password)
-	d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
-
-	// Send emails using d.
-	toEmails := emails
-
 	m := gomail.NewMessage()
 	m.SetHeader("From", fromEmail)
-	m.SetHeader("To", toEmails...)
+	m.SetHeader("To", emails...)
 
 	// 邮件标题
 	m.SetHeader("Subject", subject)
 
 	m.SetBody(contentType, content)
-
-	if err := d.DialAndSend(m); err != nil {
-		return err
+	if altContentType != "" && altContent != "" {
+		m.AddAlternative(altContentType, altContent)
 	}
 
-	return nil
+	return sendMail(opts, fromEmail, emails, func(w io.Writer) error {
+		_, err := m.WriteTo(w)
+		return err
+	})
 }
@@ -0,0 +1,334 @@
+package emailutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TLSModeNone     = "none"     // plain connection
+	TLSModeStartTLS = "starttls" // plain connection upgraded with STARTTLS, usually port 587 or 25
+	TLSModeTLS      = "tls"      // implicit tls, usually port 465
+
+	AuthNone    = "none"
+	AuthPlain   = "plain"
+	AuthLogin   = "login"
+	AuthCramMD5 = "cram-md5"
+
+	defaultTimeout = 30 * time.Second
+)
+
+// Options of the smtp connection
+type Options struct {
+	Host     string
+	Port     int
+	Username string // no authentication when empty
+	Password string
+	// TLSMode is none, starttls or tls. When empty, port 465 uses tls,
+	// other ports use starttls if the server supports it
+	TLSMode    string
+	SkipVerify bool
+	CAFile     string // pem file of the ca that signed the server certificate, added to the system pool
+	// AuthMethod is plain, login, cram-md5 or none. When empty, cram-md5 is used if
+	// the server supports it, otherwise plain
+	AuthMethod string
+	Timeout    time.Duration
+	// Transcript receives the smtp conversation, credentials are masked
+	Transcript io.Writer
+}
+
+// smtpClient is a minimal smtp client which can report the whole conversation
+type smtpClient struct {
+	opts Options
+	conn net.Conn
+	text *textproto.Conn
+	ext  map[string]string
+	tls  bool
+}
+
+// sendMail delivers the message written by write to all recipients
+func sendMail(opts Options, from string, to []string, write func(w io.Writer) error) error {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	mode := strings.ToLower(opts.TLSMode)
+	if mode == "" && opts.Port == 465 {
+		mode = TLSModeTLS
+	}
+	switch mode {
+	case "", TLSModeNone, TLSModeStartTLS, TLSModeTLS:
+	default:
+		return fmt.Errorf("unknown tls mode: %s", opts.TLSMode)
+	}
+
+	tlsConfig, err := opts.tlsConfig()
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	var conn net.Conn
+	if mode == TLSModeTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(opts.Timeout * 4))
+	c := &smtpClient{opts: opts, tls: mode == TLSModeTLS}
+	c.setConn(conn)
+	defer c.text.Close()
+	c.log("* connected to %s, tls: %t", addr, c.tls)
+
+	if _, _, err = c.read(220); err != nil {
+		return err
+	}
+	if err = c.hello(); err != nil {
+		return err
+	}
+	if _, ok := c.ext["STARTTLS"]; !c.tls && (mode == TLSModeStartTLS || mode == "" && ok) {
+		if !ok {
+			return errors.New("the smtp server doesn't support STARTTLS")
+		}
+		if _, _, err = c.cmd(220, "STARTTLS"); err != nil {
+			return err
+		}
+		tlsConn := tls.Client(c.conn, tlsConfig)
+		if err = tlsConn.Handshake(); err != nil {
+			c.log("* tls handshake error: %s", err)
+			return err
+		}
+		c.setConn(tlsConn)
+		c.tls = true
+		c.log("* tls handshake finished")
+		if err = c.hello(); err != nil {
+			return err
+		}
+	}
+	if err = c.auth(); err != nil {
+		return err
+	}
+
+	if _, _, err = c.cmd(250, "MAIL FROM:<%s>", from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if _, _, err = c.cmd(25, "RCPT TO:<%s>", rcpt); err != nil {
+			return err
+		}
+	}
+	if _, _, err = c.cmd(354, "DATA"); err != nil {
+		return err
+	}
+	w := c.text.DotWriter()
+	if err = write(w); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	c.log("C: <message body>")
+	if _, _, err = c.read(250); err != nil {
+		return err
+	}
+	_, _, _ = c.cmd(221, "QUIT")
+	return nil
+}
+
+func (c *smtpClient) setConn(conn net.Conn) {
+	c.conn = conn
+	c.text = textproto.NewConn(conn)
+}
+
+// hello sends EHLO and falls back to HELO, the extensions of the server are saved
+func (c *smtpClient) hello() error {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		name = "localhost"
+	}
+	_, msg, err := c.cmd(250, "EHLO %s", name)
+	if err != nil {
+		c.ext = nil
+		_, _, err = c.cmd(250, "HELO %s", name)
+		return err
+	}
+	c.ext = make(map[string]string)
+	lines := strings.Split(msg, "\n")
+	for _, line := range lines[1:] {
+		k, v, _ := strings.Cut(line, " ")
+		c.ext[strings.ToUpper(k)] = v
+	}
+	return nil
+}
+
+// auth authenticates with the configured or the best supported method
+func (c *smtpClient) auth() error {
+	method := strings.ToLower(c.opts.AuthMethod)
+	if c.opts.Username == "" || method == AuthNone {
+		return nil
+	}
+	mechs, ok := c.ext["AUTH"]
+	if !ok {
+		return errors.New("the smtp server doesn't support AUTH, set authMethod to none or remove the username")
+	}
+	if method == "" {
+		method = AuthPlain
+		if strings.Contains(strings.ToUpper(mechs), "CRAM-MD5") {
+			method = AuthCramMD5
+		}
+	}
+	var a smtp.Auth
+	switch method {
+	case AuthPlain:
+		a = smtp.PlainAuth("", c.opts.Username, c.opts.Password, c.opts.Host)
+	case AuthLogin:
+		a = &loginAuth{username: c.opts.Username, password: c.opts.Password, host: c.opts.Host}
+	case AuthCramMD5:
+		a = smtp.CRAMMD5Auth(c.opts.Username, c.opts.Password)
+	default:
+		return fmt.Errorf("unknown auth method: %s", c.opts.AuthMethod)
+	}
+
+	mech, resp, err := a.Start(&smtp.ServerInfo{Name: c.opts.Host, TLS: c.tls, Auth: strings.Fields(mechs)})
+	if err != nil {
+		c.log("* auth %s error: %s", method, err)
+		return err
+	}
+	code, msg, err := c.cmdSecret(0, strings.TrimSpace("AUTH "+mech+" "+encode(resp)))
+	for err == nil {
+		var challenge []byte
+		switch code {
+		case 334:
+			challenge, err = base64.StdEncoding.DecodeString(msg)
+		case 235:
+			challenge = []byte(msg)
+		default:
+			err = &textproto.Error{Code: code, Msg: msg}
+		}
+		if err == nil {
+			resp, err = a.Next(challenge, code == 334)
+		}
+		if err != nil {
+			_, _, _ = c.cmd(501, "*")
+			break
+		}
+		if resp == nil {
+			break
+		}
+		code, msg, err = c.cmdSecret(0, encode(resp))
+	}
+	return err
+}
+
+// cmd sends a command and reads the response, expectCode works like in textproto.ReadResponse
+func (c *smtpClient) cmd(expectCode int, format string, args ...interface{}) (int, string, error) {
+	line := fmt.Sprintf(format, args...)
+	c.log("C: %s", line)
+	if err := c.text.PrintfLine("%s", line); err != nil {
+		return 0, "", err
+	}
+	return c.read(expectCode)
+}
+
+// cmdSecret is cmd without writing the credentials to the transcript
+func (c *smtpClient) cmdSecret(expectCode int, line string) (int, string, error) {
+	masked := line
+	if strings.HasPrefix(line, "AUTH ") {
+		fields := strings.Fields(line)
+		masked = "AUTH " + fields[1]
+		if len(fields) > 2 {
+			masked += " ********"
+		}
+	} else if line != "" {
+		masked = "********"
+	}
+	c.log("C: %s", masked)
+	if err := c.text.PrintfLine("%s", line); err != nil {
+		return 0, "", err
+	}
+	return c.read(expectCode)
+}
+
+func (c *smtpClient) read(expectCode int) (int, string, error) {
+	code, msg, err := c.text.ReadResponse(expectCode)
+	for _, line := range strings.Split(msg, "\n") {
+		c.log("S: %d %s", code, line)
+	}
+	return code, msg, err
+}
+
+func (c *smtpClient) log(format string, args ...interface{}) {
+	if c.opts.Transcript != nil {
+		_, _ = fmt.Fprintf(c.opts.Transcript, format+"\n", args...)
+	}
+}
+
+// tlsConfig verifies the server certificate with the system pool and the optional ca file
+func (o *Options) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         o.Host,
+		InsecureSkipVerify: o.SkipVerify,
+	}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+func encode(b []byte) string {
+	if b == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp doesn't provide.
+// Like smtp.PlainAuth, it refuses to send the password over an unencrypted connection except to localhost
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && a.host != "localhost" && a.host != "127.0.0.1" && a.host != "::1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	prompt := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(prompt, "user"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "pass"):
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected server challenge: %s", fromServer)
+}
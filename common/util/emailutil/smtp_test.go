@@ -0,0 +1,84 @@
+package emailutil
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeSmtp answers one smtp session without tls, auth only supports LOGIN
+func fakeSmtp(ln net.Listener, received chan<- string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	write := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+	write("220 fake ESMTP")
+	var data strings.Builder
+	inData := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if inData {
+			if line == "." {
+				inData = false
+				write("250 queued")
+				received <- data.String()
+				continue
+			}
+			data.WriteString(line + "\n")
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "EHLO"):
+			write("250-fake\r\n250 AUTH LOGIN")
+		case line == "AUTH LOGIN":
+			write("334 VXNlcm5hbWU6")
+		case line == "dXNlcg==": // user
+			write("334 UGFzc3dvcmQ6")
+		case line == "c2VjcmV0": // secret
+			write("235 ok")
+		case strings.HasPrefix(line, "MAIL"), strings.HasPrefix(line, "RCPT"):
+			write("250 ok")
+		case line == "DATA":
+			inData = true
+			write("354 go ahead")
+		case line == "QUIT":
+			write("221 bye")
+			return
+		default:
+			write("502 unknown")
+		}
+	}
+}
+
+func TestSendMail(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go fakeSmtp(ln, received)
+
+	var transcript bytes.Buffer
+	opts := Options{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port, Username: "user", Password: "secret",
+		AuthMethod: AuthLogin, TLSMode: TLSModeNone, Transcript: &transcript}
+	err = SendEmailWithOptions(opts, "a@example.com, b@example.com", "test", "hello", "osmonitor@example.com", "text/plain")
+	if err != nil {
+		t.Fatalf("%s\n%s", err, transcript.String())
+	}
+	if body := <-received; !strings.Contains(body, "hello") || !strings.Contains(body, "Subject: test") {
+		t.Errorf("unexpected message: %s", body)
+	}
+	if strings.Contains(transcript.String(), "c2VjcmV0") {
+		t.Error("the password is not masked in the transcript")
+	}
+}
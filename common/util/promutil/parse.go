@@ -0,0 +1,198 @@
+package promutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Sample is a sample of the text exposition format
+type Sample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+	Type   string            `json:"type,omitempty"` // gauge or counter, empty for the other types and the samples of histograms and summaries
+}
+
+type jsonSample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  string            `json:"value"`
+	Type   string            `json:"type,omitempty"`
+}
+
+// MarshalJSON encodes the value as a string, json can't encode NaN and Inf
+func (s Sample) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonSample{Name: s.Name, Labels: s.Labels, Value: FormatValue(s.Value), Type: s.Type})
+}
+
+// UnmarshalJSON decodes a sample encoded by MarshalJSON
+func (s *Sample) UnmarshalJSON(data []byte) error {
+	var v jsonSample
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	value, err := parseValue(v.Value)
+	if err != nil {
+		return fmt.Errorf("invalid value of %s: %s", v.Name, err)
+	}
+	*s = Sample{Name: v.Name, Labels: v.Labels, Value: value, Type: v.Type}
+	return nil
+}
+
+// Parse reads the samples of the text exposition format, the HELP lines, the other comments and the timestamps are ignored
+func Parse(r io.Reader) ([]Sample, error) {
+	var samples []Sample
+	types := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "#") {
+			if fields := strings.Fields(text); len(fields) == 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+		sample, err := parseLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		if typ := types[sample.Name]; typ == TypeGauge || typ == TypeCounter {
+			sample.Type = typ
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// parseLine parses a line like name{label="value",...} value [timestamp]
+func parseLine(text string) (Sample, error) {
+	var s Sample
+	i := strings.IndexAny(text, "{ \t")
+	if i <= 0 {
+		return s, fmt.Errorf("invalid sample %q", text)
+	}
+	s.Name = text[:i]
+	rest := text[i:]
+	if rest[0] == '{' {
+		labels, n, err := parseLabels(rest)
+		if err != nil {
+			return s, err
+		}
+		s.Labels = labels
+		rest = rest[n:]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return s, fmt.Errorf("invalid value of %s", s.Name)
+	}
+	v, err := parseValue(fields[0])
+	if err != nil {
+		return s, fmt.Errorf("invalid value of %s: %s", s.Name, err)
+	}
+	s.Value = v
+	return s, nil
+}
+
+// parseLabels parses {a="x",b="y"} and returns the labels and the length of the label part
+func parseLabels(text string) (map[string]string, int, error) {
+	labels := make(map[string]string)
+	i := 1
+	for {
+		for i < len(text) && (text[i] == ' ' || text[i] == ',') {
+			i++
+		}
+		if i >= len(text) {
+			return nil, 0, fmt.Errorf("unterminated labels")
+		}
+		if text[i] == '}' {
+			return labels, i + 1, nil
+		}
+		eq := strings.IndexByte(text[i:], '=')
+		if eq <= 0 {
+			return nil, 0, fmt.Errorf("invalid label in %q", text)
+		}
+		name := strings.TrimSpace(text[i : i+eq])
+		i += eq + 1
+		if i >= len(text) || text[i] != '"' {
+			return nil, 0, fmt.Errorf("label %s value must be quoted", name)
+		}
+		i++
+		var value strings.Builder
+		for ; i < len(text) && text[i] != '"'; i++ {
+			if text[i] == '\\' && i+1 < len(text) {
+				i++
+				switch text[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(text[i])
+				}
+				continue
+			}
+			value.WriteByte(text[i])
+		}
+		if i >= len(text) {
+			return nil, 0, fmt.Errorf("unterminated value of label %s", name)
+		}
+		labels[name] = value.String()
+		i++
+	}
+}
+
+func parseValue(s string) (float64, error) {
+	switch s {
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// NameFilter selects the metrics by name with the allow and deny patterns, e.g. node_cpu_*.
+// A metric is selected when it matches an allow pattern, or no allow pattern is given, and doesn't match any deny pattern
+type NameFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+// Validate checks the syntax of the patterns
+func (f NameFilter) Validate() error {
+	for _, pattern := range append(append([]string(nil), f.Allow...), f.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid metric pattern %s: %s", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether the metric is selected
+func (f NameFilter) Match(name string) bool {
+	if len(f.Allow) > 0 && !matchAny(f.Allow, name) {
+		return false
+	}
+	return !matchAny(f.Deny, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package promutil
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// metric types of the text exposition format
+const (
+	TypeGauge   = "gauge"
+	TypeCounter = "counter"
+)
+
+// Writer writes metrics in the Prometheus text exposition format.
+// The HELP and TYPE lines of a metric are written before its first sample
+type Writer struct {
+	w       io.Writer
+	written map[string]bool
+	err     error
+}
+
+// NewWriter creates a writer on w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, written: make(map[string]bool)}
+}
+
+// Write writes a sample of the metric, all samples of a metric must be written together
+func (w *Writer) Write(name, typ, help string, labels map[string]string, value float64) {
+	if w.err != nil {
+		return
+	}
+	if !w.written[name] {
+		w.written[name] = true
+		if help != "" {
+			w.printf("# HELP %s %s\n", name, escapeHelp(help))
+		}
+		if typ != "" {
+			w.printf("# TYPE %s %s\n", name, typ)
+		}
+	}
+	w.printf("%s%s %s\n", name, FormatLabels(labels), FormatValue(value))
+}
+
+// Has reports whether a sample of the metric was written
+func (w *Writer) Has(name string) bool {
+	return w.written[name]
+}
+
+// Err returns the first write error
+func (w *Writer) Err() error {
+	return w.err
+}
+
+func (w *Writer) printf(format string, args ...interface{}) {
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.w, format, args...)
+	}
+}
+
+// FormatLabels formats the labels sorted by name, e.g. {node="geth-01",region="eu"}
+func FormatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(labels[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// FormatValue formats the value like Prometheus, e.g. +Inf, NaN
+func FormatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// SanitizeName replaces the characters not allowed in a metric name with '_', e.g. mem.used -> mem_used
+func SanitizeName(name string) string {
+	return sanitize(name, true)
+}
+
+// SanitizeLabel replaces the characters not allowed in a label name with '_'
+func SanitizeLabel(name string) string {
+	return sanitize(name, false)
+}
+
+func sanitize(name string, colon bool) string {
+	b := []byte(name)
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || colon && c == ':' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		b[i] = '_'
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,129 @@
+package promutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write("osmonitor_node_up", TypeGauge, "Whether the node is online.", map[string]string{"node": "geth-01", "region": "eu"}, 1)
+	w.Write("osmonitor_node_up", TypeGauge, "Whether the node is online.", map[string]string{"node": `a"b\c`}, 0)
+	w.Write("osmonitor_messages_total", TypeCounter, "", nil, 12)
+	w.Write("osmonitor_ratio", "", "", nil, math.Inf(1))
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP osmonitor_node_up Whether the node is online.
+# TYPE osmonitor_node_up gauge
+osmonitor_node_up{node="geth-01",region="eu"} 1
+osmonitor_node_up{node="a\"b\\c"} 0
+# TYPE osmonitor_messages_total counter
+osmonitor_messages_total 12
+osmonitor_ratio +Inf
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	cases := map[string]string{
+		"mem_used_percent": "mem_used_percent",
+		"disk.used-bytes":  "disk_used_bytes",
+		"1m_load":          "_m_load",
+		"a:b":              "a:b",
+		"":                 "_",
+	}
+	for name, expected := range cases {
+		if got := SanitizeName(name); got != expected {
+			t.Errorf("SanitizeName(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+	if got := SanitizeLabel("a:b"); got != "a_b" {
+		t.Errorf("SanitizeLabel(a:b) = %q", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	text := `# HELP http_requests_total The total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 4773
+rpc_duration_seconds_count 2693
+msdos_file_access_time_seconds{path="C:\\DIR\\FILE.TXT",error="Cannot find file:\n\"FILE.TXT\""} 1.458255915e9
+missing_value -Inf
+`
+	samples, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 6 {
+		t.Fatalf("expected 6 samples, got %d", len(samples))
+	}
+	if s := samples[0]; s.Name != "http_requests_total" || s.Type != TypeCounter || s.Value != 1027 || s.Labels["code"] != "200" {
+		t.Errorf("unexpected sample %+v", s)
+	}
+	if s := samples[2]; s.Type != "" || s.Labels["quantile"] != "0.5" {
+		t.Errorf("the samples of a summary must be untyped: %+v", s)
+	}
+	if s := samples[4]; s.Labels["path"] != `C:\DIR\FILE.TXT` || s.Labels["error"] != "Cannot find file:\n\"FILE.TXT\"" {
+		t.Errorf("unexpected labels %v", s.Labels)
+	}
+	if !math.IsInf(samples[5].Value, -1) {
+		t.Errorf("expected -Inf, got %v", samples[5].Value)
+	}
+
+	for _, invalid := range []string{`a{b="c" 1`, `a{b=c} 1`, `a`, `a 1 2 3`, `a x`} {
+		if _, err := Parse(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected error of %q", invalid)
+		}
+	}
+}
+
+func TestNameFilter(t *testing.T) {
+	f := NameFilter{Allow: []string{"node_*", "up"}, Deny: []string{"node_scrape_*"}}
+	cases := map[string]bool{
+		"node_cpu_seconds_total":        true,
+		"up":                            true,
+		"node_scrape_collector_success": false,
+		"go_goroutines":                 false,
+	}
+	for name, expected := range cases {
+		if f.Match(name) != expected {
+			t.Errorf("match %s: expected %v", name, expected)
+		}
+	}
+	if !(NameFilter{Deny: []string{"go_*"}}).Match("up") {
+		t.Error("all metrics must be allowed without allow patterns")
+	}
+	if err := (NameFilter{Allow: []string{"["}}).Validate(); err == nil {
+		t.Error("expected invalid pattern error")
+	}
+}
+
+func TestSampleJSON(t *testing.T) {
+	samples := []Sample{
+		{Name: "up", Labels: map[string]string{"job": "node"}, Value: 1, Type: TypeGauge},
+		{Name: "ratio", Value: math.NaN()},
+		{Name: "le", Value: math.Inf(1)},
+	}
+	content, err := json.Marshal(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Sample
+	if err = json.Unmarshal(content, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 3 || decoded[0].Value != 1 || decoded[0].Labels["job"] != "node" || decoded[0].Type != TypeGauge ||
+		!math.IsNaN(decoded[1].Value) || !math.IsInf(decoded[2].Value, 1) {
+		t.Errorf("unexpected samples %+v", decoded)
+	}
+}
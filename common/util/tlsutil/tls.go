@@ -0,0 +1,119 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader keeps a certificate and its key loaded from files, and loads them again after one of the files changed,
+// so a renewed certificate is used without restarting
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	lock     sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+// NewCertReloader loads the certificate and the key
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the files again when the modification time of one of them changed,
+// the loaded certificate is kept when the new files are invalid, e.g. the certificate is written but the key is not yet
+func (r *CertReloader) Reload() (bool, error) {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return false, err
+	}
+	r.lock.RLock()
+	changed := r.cert == nil || !modTime.Equal(r.modTime)
+	r.lock.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+	r.lock.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.lock.Unlock()
+	return true, nil
+}
+
+// Watch checks the files every interval in the background, fn is called after every reload and every failed reload
+func (r *CertReloader) Watch(interval time.Duration, fn func(reloaded bool, err error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if reloaded, err := r.Reload(); reloaded || err != nil {
+				fn(reloaded, err)
+			}
+		}
+	}()
+}
+
+// Certificate returns the loaded certificate
+func (r *CertReloader) Certificate() *tls.Certificate {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.cert
+}
+
+// GetCertificate is used as tls.Config.GetCertificate of a server
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// GetClientCertificate is used as tls.Config.GetClientCertificate of a client
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+func (r *CertReloader) lastModified() (time.Time, error) {
+	var result time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return result, err
+		}
+		if info.ModTime().After(result) {
+			result = info.ModTime()
+		}
+	}
+	return result, nil
+}
+
+// LoadCertPool loads the PEM encoded CA certificates of the file
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, errors.New("no certificate found in " + file)
+	}
+	return pool, nil
+}
+
+// PeerName returns the common name of the verified client certificate of the connection,
+// an empty string when the client sent no certificate or it isn't verified
+func PeerName(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return state.VerifiedChains[0][0].Subject.CommonName
+}
@@ -0,0 +1,117 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate of the common name and its key
+func writeCert(t *testing.T, certFile, keyFile, name string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "first")
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := r.Reload(); reloaded || err != nil {
+		t.Fatalf("unchanged files reloaded: %v %v", reloaded, err)
+	}
+
+	// a broken key keeps the loaded certificate
+	if err = os.WriteFile(keyFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(keyFile, later, later)
+	if _, err = r.Reload(); err == nil {
+		t.Fatal("broken key should fail")
+	}
+	if name := commonName(t, r.Certificate()); name != "first" {
+		t.Fatalf("unexpected certificate %s", name)
+	}
+
+	writeCert(t, certFile, keyFile, "second")
+	later = later.Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+	if reloaded, err := r.Reload(); !reloaded || err != nil {
+		t.Fatalf("changed files not reloaded: %v %v", reloaded, err)
+	}
+	cert, _ := r.GetCertificate(nil)
+	if name := commonName(t, cert); name != "second" {
+		t.Fatalf("unexpected certificate %s", name)
+	}
+}
+
+func TestLoadCertPool(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "ca")
+	if _, err := LoadCertPool(certFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCertPool(keyFile); err == nil {
+		t.Fatal("a key file isn't a certificate pool")
+	}
+}
+
+func TestPeerName(t *testing.T) {
+	if PeerName(nil) != "" || PeerName(&tls.ConnectionState{}) != "" {
+		t.Fatal("no verified certificate should have no name")
+	}
+	state := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "geth-01"}}}}}
+	if PeerName(state) != "geth-01" {
+		t.Fatalf("unexpected name %s", PeerName(state))
+	}
+}
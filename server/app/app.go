@@ -2,8 +2,10 @@ package app
 
 import (
 	"ethstats/server/app/model"
+	"ethstats/server/app/notifier"
 	"ethstats/server/app/service"
 	"ethstats/server/config"
+	"ethstats/server/frontend"
 	"github.com/bitxx/logger"
 	"github.com/bitxx/logger/logbase"
 	"net/http"
@@ -18,8 +20,8 @@ func NewApp() *App {
 	channel := &model.Channel{
 		MsgPing:    make(chan []byte),
 		MsgLatency: make(chan []byte),
+		Alerts:     make(chan *model.Alert, 64),
 		LoginIDs:   make(map[string]string),
-		InfoPool:   make(map[string]map[string]string),
 	}
 	return &App{
 		channel: channel,
@@ -34,9 +36,74 @@ func NewApp() *App {
 }
 
 func (a *App) Start() {
-	relay := service.NewRelay(a.channel, a.logger)
-	api := service.NewApi(a.channel, a.logger)
+	silences, err := service.NewSilenceStore(config.SilenceConfig.Path)
+	if err != nil {
+		a.logger.Fatalf("load silences error: %s", err)
+	}
+	notifiers, err := notifier.New(*config.NotifiersConfig)
+	if err != nil {
+		a.logger.Fatalf("load notifiers error: %s", err)
+	}
+	outbox, err := notifier.NewOutbox(a.logger)
+	if err != nil {
+		a.logger.Fatalf("load outbox error: %s", err)
+	}
+	notifiers = outbox.Wrap(notifiers)
+	outbox.Start()
+	events, err := service.NewEventStore(a.logger)
+	if err != nil {
+		a.logger.Fatalf("load events error: %s", err)
+	}
+	events.Start()
+	registry := service.NewRegistry()
+	stats := service.NewStats()
+	beats, err := service.NewHeartbeatMonitor(*config.HeartbeatsConfig, a.channel, registry, events, silences, a.logger)
+	if err != nil {
+		a.logger.Fatalf("load heartbeats error: %s", err)
+	}
+	beats.Start()
+	jobs, err := service.NewJobStore(a.channel, registry, events, silences, a.logger)
+	if err != nil {
+		a.logger.Fatalf("load jobs error: %s", err)
+	}
+	jobs.Start()
+	creds, err := service.NewCredentialStore(config.CredentialsConfig.Path)
+	if err != nil {
+		a.logger.Fatalf("load node credentials error: %s", err)
+	}
+	relay := service.NewRelay(a.channel, registry, events, silences, stats, beats, jobs, creds, a.logger)
+	if _, err = service.NewRuleEngine(*config.RulesConfig, a.channel, registry, events, silences, a.logger); err != nil {
+		a.logger.Fatalf("load rules error: %s", err)
+	}
+	reporter, err := service.NewReporter(registry, events, notifiers, a.logger)
+	if err != nil {
+		a.logger.Fatalf("load reports error: %s", err)
+	}
+	reporter.Start()
+	router, err := notifier.NewRouter(*config.RoutesConfig, notifiers)
+	if err != nil {
+		a.logger.Fatalf("load routes error: %s", err)
+	}
+	alerts := service.NewAlertLog()
+	api := service.NewApi(a.channel, registry, events, router, alerts, a.logger)
+	tokens, err := service.NewTokenStore(config.ApiConfig.TokenPath)
+	if err != nil {
+		a.logger.Fatalf("load api tokens error: %s", err)
+	}
+	users, err := service.NewUserStore(config.ApiConfig.UserPath)
+	if err != nil {
+		a.logger.Fatalf("load users error: %s", err)
+	}
+	auth, err := service.NewAuth(tokens, users, stats, a.logger)
+	if err != nil {
+		a.logger.Fatalf("load api auth error: %s", err)
+	}
+	rest := service.NewRest(registry, events, alerts, silences, outbox, auth, beats, jobs, a.logger)
+	metrics := service.NewMetrics(registry, outbox, stats, a.logger)
 	http.HandleFunc("/", relay.HandleRequest)
-	http.HandleFunc("/api", api.HandleRequest)
-	a.logger.Fatal(http.ListenAndServe(config.ApplicationConfig.Host+":"+config.ApplicationConfig.Port, nil))
+	http.HandleFunc("/api", auth.Wrap(model.ScopeRead, api.HandleRequest))
+	http.HandleFunc("/metrics", auth.Wrap(model.ScopeRead, metrics.HandleRequest))
+	rest.Register(http.DefaultServeMux)
+	http.Handle(frontend.Path, frontend.Handler())
+	a.logger.Fatal(a.listen())
 }
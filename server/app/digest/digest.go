@@ -0,0 +1,324 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	ScopeFull    = "full"    // summary, events and node sections
+	ScopeEvents  = "events"  // summary and events
+	ScopeSummary = "summary" // only the fleet summary
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Options selects the nodes and the content of a report
+type Options struct {
+	Scope   string              // full, events or summary; empty means full
+	Nodes   []string            // patterns of the node ids, e.g. "geth-*"; empty means all nodes
+	Labels  model.LabelSelector // the node labels must match
+	GroupBy string              // label key, the node sections are grouped by its value
+}
+
+// Report is the data of the digest templates
+type Report struct {
+	Server       string
+	Title        string
+	Scope        string
+	GroupBy      string
+	Time         time.Time
+	DashboardUrl string
+	Summary      Summary
+	Nodes        []Node
+	Groups       []Group // the nodes grouped by the GroupBy label, one group without name when not grouped
+	Events       []model.Event
+}
+
+// Group is the nodes with the same value of the GroupBy label
+type Group struct {
+	Name    string
+	Online  int
+	Offline int
+	Nodes   []Node
+}
+
+// Summary is the fleet summary at the top of the digest
+type Summary struct {
+	Total    int
+	Online   int
+	Offline  int
+	Expected int      // count of the expected nodes, 0 when not configured
+	Missing  []string // expected nodes that never logged in
+}
+
+// Node is the section of one node
+type Node struct {
+	ID       string
+	Addr     string
+	Labels   []Label
+	Url      string
+	Online   bool
+	LastSeen time.Time
+	Latency  int64
+	Procs    []Proc
+	Stats    []Stat
+}
+
+// Proc is the state of a monitored process
+type Proc struct {
+	Name string
+	Up   bool
+}
+
+// Label is a node label
+type Label struct {
+	Key   string
+	Value string
+}
+
+// Stat is a formatted host metric
+type Stat struct {
+	Name  string
+	Value string
+}
+
+// Build creates the report data of the node states and the aggregated events of the report period
+func Build(title string, nodes []model.NodeState, events []model.Event, opts Options) *Report {
+	report := &Report{
+		Server:       config.ApplicationConfig.Name,
+		Title:        title,
+		Scope:        opts.Scope,
+		GroupBy:      opts.GroupBy,
+		Time:         time.Now(),
+		DashboardUrl: strings.TrimRight(config.DigestConfig.DashboardUrl, "/"),
+	}
+	if report.Scope == "" {
+		report.Scope = ScopeFull
+	}
+	labels := make(map[string]map[string]string)
+	for _, n := range nodes {
+		labels[n.ID] = n.Labels
+	}
+	for _, e := range events {
+		nodeLabels, ok := labels[e.Node]
+		if !ok {
+			nodeLabels = config.NodeLabels(e.Node, nil)
+		}
+		if opts.matchNode(e.Node, nodeLabels) {
+			report.Events = append(report.Events, e)
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, n := range nodes {
+		known[n.ID] = true
+		if !opts.matchNode(n.ID, n.Labels) {
+			continue
+		}
+		node := Node{
+			ID:       n.ID,
+			Addr:     n.Addr,
+			Online:   n.Online,
+			LastSeen: n.LastSeen,
+			Latency:  n.Latency,
+		}
+		if report.DashboardUrl != "" {
+			node.Url = report.DashboardUrl + "/#/node/" + url.PathEscape(n.ID)
+		}
+		for k, v := range n.Labels {
+			node.Labels = append(node.Labels, Label{Key: k, Value: v})
+		}
+		sort.Slice(node.Labels, func(i, j int) bool { return node.Labels[i].Key < node.Labels[j].Key })
+		for name, up := range n.Procs {
+			node.Procs = append(node.Procs, Proc{Name: name, Up: up})
+		}
+		sort.Slice(node.Procs, func(i, j int) bool { return node.Procs[i].Name < node.Procs[j].Name })
+		for name, value := range n.Stats {
+			node.Stats = append(node.Stats, Stat{Name: name, Value: FormatStat(name, value)})
+		}
+		sort.Slice(node.Stats, func(i, j int) bool { return node.Stats[i].Name < node.Stats[j].Name })
+		report.Nodes = append(report.Nodes, node)
+		if n.Online {
+			report.Summary.Online++
+		} else {
+			report.Summary.Offline++
+		}
+	}
+	expected := 0
+	for _, id := range config.DigestConfig.ExpectedNodes {
+		if !opts.matchNode(id, config.NodeLabels(id, nil)) {
+			continue
+		}
+		expected++
+		if !known[id] {
+			report.Summary.Missing = append(report.Summary.Missing, id)
+		}
+	}
+	report.Summary.Expected = expected
+	report.Summary.Offline += len(report.Summary.Missing)
+	report.Summary.Total = len(report.Nodes) + len(report.Summary.Missing)
+
+	// offline nodes first, they need attention
+	sort.SliceStable(report.Nodes, func(i, j int) bool {
+		return !report.Nodes[i].Online && report.Nodes[j].Online
+	})
+	report.Groups = groupNodes(report.Nodes, nodes, opts.GroupBy)
+	sort.SliceStable(report.Events, func(i, j int) bool {
+		if !report.Events[i].FirstSeen.Equal(report.Events[j].FirstSeen) {
+			return report.Events[i].FirstSeen.Before(report.Events[j].FirstSeen)
+		}
+		if report.Events[i].Node != report.Events[j].Node {
+			return report.Events[i].Node < report.Events[j].Node
+		}
+		return report.Events[i].ID < report.Events[j].ID
+	})
+	return report
+}
+
+// groupNodes groups the node sections by the value of the label, sorted by the value
+func groupNodes(sections []Node, nodes []model.NodeState, key string) []Group {
+	if len(sections) == 0 {
+		return nil
+	}
+	if key == "" {
+		group := Group{Nodes: sections}
+		for _, n := range sections {
+			if n.Online {
+				group.Online++
+			} else {
+				group.Offline++
+			}
+		}
+		return []Group{group}
+	}
+	values := make(map[string]string)
+	for _, n := range nodes {
+		values[n.ID] = n.Labels[key]
+	}
+	index := make(map[string]int)
+	var groups []Group
+	for _, n := range sections {
+		value := values[n.ID]
+		i, ok := index[value]
+		if !ok {
+			i = len(groups)
+			index[value] = i
+			groups = append(groups, Group{Name: value})
+		}
+		groups[i].Nodes = append(groups[i].Nodes, n)
+		if n.Online {
+			groups[i].Online++
+		} else {
+			groups[i].Offline++
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// matchNode report whether the node is selected by the node patterns and the label selector
+func (o Options) matchNode(id string, labels map[string]string) bool {
+	if !o.Labels.Matches(labels) {
+		return false
+	}
+	if len(o.Nodes) == 0 {
+		return true
+	}
+	for _, pattern := range o.Nodes {
+		if ok, _ := path.Match(pattern, id); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Render executes the text and the html template, the template files of the digest config
+// are read on every call, so changes apply without restart
+func Render(report *Report) (text string, html string, err error) {
+	textContent, err := readTemplate(config.DigestConfig.TextTemplate, "templates/digest.txt")
+	if err != nil {
+		return "", "", err
+	}
+	textTpl, err := texttemplate.New("digest.txt").Funcs(funcs).Parse(textContent)
+	if err != nil {
+		return "", "", err
+	}
+	var textBuf bytes.Buffer
+	if err = textTpl.Execute(&textBuf, report); err != nil {
+		return "", "", err
+	}
+
+	htmlContent, err := readTemplate(config.DigestConfig.Template, "templates/digest.html")
+	if err != nil {
+		return "", "", err
+	}
+	htmlTpl, err := htmltemplate.New("digest.html").Funcs(funcs).Parse(htmlContent)
+	if err != nil {
+		return "", "", err
+	}
+	var htmlBuf bytes.Buffer
+	if err = htmlTpl.Execute(&htmlBuf, report); err != nil {
+		return "", "", err
+	}
+	return textBuf.String(), htmlBuf.String(), nil
+}
+
+func readTemplate(file, builtin string) (string, error) {
+	var content []byte
+	var err error
+	if file != "" {
+		content, err = os.ReadFile(file)
+	} else {
+		content, err = templates.ReadFile(builtin)
+	}
+	return string(content), err
+}
+
+// FormatStat formats a host metric by the unit suffix of its name
+func FormatStat(name string, value float64) string {
+	switch {
+	case strings.HasSuffix(name, "_percent"):
+		return fmt.Sprintf("%.1f%%", value)
+	case strings.HasSuffix(name, "_bytes"):
+		units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+		i := 0
+		for value >= 1024 && i < len(units)-1 {
+			value /= 1024
+			i++
+		}
+		return fmt.Sprintf("%.1f %s", value, units[i])
+	case strings.HasSuffix(name, "_seconds"):
+		return (time.Duration(value) * time.Second).String()
+	case value == float64(int64(value)):
+		return fmt.Sprintf("%d", int64(value))
+	}
+	return fmt.Sprintf("%.2f", value)
+}
+
+var funcs = map[string]interface{}{
+	"datetime": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04:05")
+	},
+	"latency": func(ms int64) string {
+		if ms < 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%dms", ms)
+	},
+}
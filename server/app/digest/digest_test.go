@@ -0,0 +1,83 @@
+package digest
+
+import (
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	config.DigestConfig.ExpectedNodes = []string{"geth-01", "geth-02"}
+	config.DigestConfig.DashboardUrl = "http://monitor.example.com/"
+	nodes := []model.NodeState{{
+		ID: "geth-01", Addr: "1.2.3.4:5678", Online: true, Latency: 12,
+		Procs: map[string]bool{"geth": false},
+		Stats: map[string]float64{"mem_used_percent": 41.25, "disk_total_bytes": 512 * 1024 * 1024 * 1024},
+	}}
+	now := time.Now()
+	events := []model.Event{
+		{ID: "b", Node: "geth-01", Kind: "proc-down", Subject: "geth", FirstSeen: now, LastSeen: now, Count: 3,
+			State: model.EventFiring, Content: "this process is stopped: geth"},
+		{ID: "a", Node: "geth-01", Kind: "node-error", Subject: "connection", FirstSeen: now.Add(-time.Hour), LastSeen: now,
+			Count: 1, State: model.EventResolved, Content: "<script>", Silenced: "s1"},
+	}
+	text, html, err := Render(Build("monitor report", nodes, events, Options{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"online: 1    offline: 1    total: 2    expected: 2", "never logged in: geth-02",
+		"geth DOWN", "[firing] geth-01 proc-down geth x3", "(silenced by s1)", "mem_used_percent=41.2%", "disk_total_bytes=512.0 GiB"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("text digest doesn't contain %q:\n%s", expected, text)
+		}
+	}
+	if strings.Index(text, "<script>") > strings.Index(text, "this process is stopped") {
+		t.Error("events are not sorted by time")
+	}
+	if strings.Contains(html, "<script>") || !strings.Contains(html, `href="http://monitor.example.com/#/node/geth-01"`) {
+		t.Errorf("unexpected html digest:\n%s", html)
+	}
+}
+
+func TestBuildOptions(t *testing.T) {
+	config.DigestConfig.ExpectedNodes = []string{"geth-01", "bsc-01"}
+	nodes := []model.NodeState{{ID: "geth-01", Online: true}, {ID: "geth-02", Online: false}, {ID: "bsc-02", Online: true}}
+	events := []model.Event{{ID: "a", Node: "geth-02"}, {ID: "b", Node: "bsc-02"}}
+	report := Build("ops report", nodes, events, Options{Scope: ScopeSummary, Nodes: []string{"bsc-*"}})
+	if report.Summary.Total != 2 || report.Summary.Online != 1 || report.Summary.Offline != 1 || report.Summary.Expected != 1 {
+		t.Errorf("unexpected summary: %+v", report.Summary)
+	}
+	if len(report.Events) != 1 || report.Events[0].Node != "bsc-02" {
+		t.Errorf("unexpected events: %+v", report.Events)
+	}
+	text, _, err := Render(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(text, "== events ==") || strings.Contains(text, "== nodes ==") {
+		t.Errorf("summary report contains other sections:\n%s", text)
+	}
+}
+
+func TestBuildGroupBy(t *testing.T) {
+	config.DigestConfig.ExpectedNodes = nil
+	nodes := []model.NodeState{
+		{ID: "geth-01", Online: true, Labels: map[string]string{"region": "us", "role": "validator"}},
+		{ID: "geth-02", Online: false, Labels: map[string]string{"region": "eu", "role": "validator"}},
+		{ID: "geth-03", Online: true, Labels: map[string]string{"region": "eu", "role": "archive"}},
+	}
+	selector, _ := model.ParseLabelSelector("role=validator")
+	report := Build("regions", nodes, nil, Options{Labels: selector, GroupBy: "region"})
+	if len(report.Groups) != 2 || report.Groups[0].Name != "eu" || report.Groups[0].Offline != 1 || report.Groups[1].Online != 1 {
+		t.Fatalf("unexpected groups: %+v", report.Groups)
+	}
+	text, _, err := Render(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "-- region: eu, online 0, offline 1 --") || !strings.Contains(text, "labels: region=us, role=validator") {
+		t.Errorf("unexpected text digest:\n%s", text)
+	}
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// Alert is a real-time notification raised by a node event
+type Alert struct {
+	Name       string            `json:"name"` // the event kind
+	NodeID     string            `json:"nodeId"`
+	NodeTags   []string          `json:"nodeTags,omitempty"`
+	NodeLabels map[string]string `json:"nodeLabels,omitempty"`
+	Addr       string            `json:"addr"`
+	Tag        string            `json:"tag"`
+	Severity   string            `json:"severity"`
+	Content    string            `json:"content"`
+	Time       time.Time         `json:"time"`
+}
+
+// AlertRecord is a sent alert with its receivers and the delivery error
+type AlertRecord struct {
+	*Alert
+	Receivers []string `json:"receivers"`
+	Error     string   `json:"error,omitempty"`
+}
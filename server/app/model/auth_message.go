@@ -4,10 +4,15 @@ import (
 	"ethstats/common/util/connutil"
 )
 
-// AuthMessage is the struct sent by the server on the first connection
+// AuthMessage is the struct sent by the server on the first connection.
+// The signature is the HMAC of the last challenge of the connection, the secret is only sent by the legacy clients
 type AuthMessage struct {
-	ID     string `json:"id"`
-	Secret string `json:"secret"`
+	ID        string            `json:"id"`
+	Secret    string            `json:"secret,omitempty"`
+	Timestamp int64             `json:"timestamp,omitempty"` // unix second of the signature
+	Signature string            `json:"signature,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Inventory map[string]string `json:"inventory,omitempty"` // static host facts, e.g. os, kernel, hostname
 }
 
 // SendResponse send the ready response to the node to initiate the communication
@@ -7,8 +7,9 @@ type Channel struct {
 	MsgPing    chan []byte
 	MsgLatency chan []byte
 
+	//real-time alerts raised by the relay
+	Alerts chan *Alert
+
 	//use for flag the login client
 	LoginIDs map[string]string
-
-	InfoPool map[string]map[string]string //save any info from client tag=>nodeInfo-latestTime
 }
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Credential is the key of a node, the node signs the login challenges with it instead of the shared secret.
+// The key is stored as is because the server verifies the signatures with it, the file is only readable by the owner
+type Credential struct {
+	NodeID     string     `json:"nodeId"`
+	Key        string     `json:"key"`
+	PendingKey string     `json:"pendingKey,omitempty"` // the new key of a rotation, sent to the node on its next login
+	CreatedAt  time.Time  `json:"createdAt"`
+	RotatedAt  *time.Time `json:"rotatedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	JoinToken  string     `json:"joinToken,omitempty"`  // id of the join token used by the enrollment
+	EnrolledBy string     `json:"enrolledBy,omitempty"` // remote address of the enrollment
+}
+
+// JoinToken is a one-time token used by a node to get its credential, only the sha256 hash of the token is stored
+type JoinToken struct {
+	ID        string     `json:"id"`
+	Hash      string     `json:"hash"`
+	Node      string     `json:"node,omitempty"` // pattern of the node names allowed to use the token, empty for all nodes
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	UsedBy    string     `json:"usedBy,omitempty"` // the node enrolled with the token
+}
+
+// EnrollMessage is sent by a node without a credential to exchange the join token for its key
+type EnrollMessage struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+const (
+	EventFiring   = "firing"
+	EventResolved = "resolved"
+)
+
+// Event aggregates all occurrences of the same problem of a node,
+// e.g. the process geth of node geth-01 is stopped
+type Event struct {
+	ID         string     `json:"id"`
+	Node       string     `json:"node"`
+	Addr       string     `json:"addr"`
+	Kind       string     `json:"kind"`
+	Tag        string     `json:"tag"`
+	Subject    string     `json:"subject"`
+	Content    string     `json:"content"` // content of the latest occurrence
+	FirstSeen  time.Time  `json:"firstSeen"`
+	LastSeen   time.Time  `json:"lastSeen"`
+	Count      int        `json:"count"`
+	State      string     `json:"state"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"` // nil while firing
+	Silenced   string     `json:"silenced,omitempty"`   // id of the silence of the latest occurrence
+	AckedBy    string     `json:"ackedBy,omitempty"`    // the user or token that acknowledged the firing event
+	AckedAt    *time.Time `json:"ackedAt,omitempty"`
+}
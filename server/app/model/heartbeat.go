@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+const (
+	HeartbeatWaiting = "waiting" // no heartbeat since the server started
+	HeartbeatUp      = "up"
+	HeartbeatFailed  = "failed" // the last heartbeat reported a failure
+	HeartbeatMissed  = "missed" // no heartbeat in the period and the grace time
+
+	HeartbeatSuccess = "success" // status of a heartbeat
+	HeartbeatFail    = "fail"
+)
+
+// Heartbeat is the state of a heartbeat check
+type Heartbeat struct {
+	Name        string     `json:"name"`
+	Node        string     `json:"node"`   // the node of the events of the check
+	Period      int        `json:"period"` // second
+	Grace       int        `json:"grace"`  // second
+	State       string     `json:"state"`
+	LastPing    *time.Time `json:"lastPing,omitempty"`
+	LastStatus  string     `json:"lastStatus,omitempty"`
+	LastMessage string     `json:"lastMessage,omitempty"`
+	Deadline    time.Time  `json:"deadline"` // the check is missed after the deadline
+}
+
+// HeartbeatPing is a heartbeat relayed by a client
+type HeartbeatPing struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// JobRun is a run of a job reported by `client wrap`, the running report is replaced by the finished one
+type JobRun struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Node       string     `json:"node"`
+	Command    string     `json:"command,omitempty"`
+	State      string     `json:"state"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Duration   float64    `json:"duration"` // second, the time since the start while running
+	ExitCode   int        `json:"exitCode"`
+	Error      string     `json:"error,omitempty"`  // e.g. the command was not found
+	Stdout     string     `json:"stdout,omitempty"` // the tail of the output
+	Stderr     string     `json:"stderr,omitempty"`
+}
+
+// JobReport is a run sent by a client, the signature or the legacy secret is only needed when the wrap command
+// connects to the server itself
+type JobReport struct {
+	ID        string `json:"id"`
+	Secret    string `json:"secret,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Run       JobRun `json:"run"`
+}
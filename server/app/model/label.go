@@ -0,0 +1,79 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// LabelMatcher matches the value of one label, the value supports shell patterns, e.g. "eu-*".
+// A missing label has the empty value
+type LabelMatcher struct {
+	Key    string
+	Value  string
+	Negate bool
+}
+
+// LabelSelector matches the labels when all of its matchers match
+type LabelSelector []LabelMatcher
+
+// ParseLabelSelector parses a comma separated selector like "region=eu-*,role!=archive"
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	var selector LabelSelector
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		m := LabelMatcher{}
+		key, value, ok := strings.Cut(part, "!=")
+		if ok {
+			m.Negate = true
+		} else if key, value, ok = strings.Cut(part, "="); !ok {
+			return nil, fmt.Errorf("invalid label selector %q, expected key=value or key!=value", part)
+		}
+		m.Key, m.Value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if m.Key == "" {
+			return nil, fmt.Errorf("invalid label selector %q, the key is empty", part)
+		}
+		if _, err := path.Match(m.Value, ""); err != nil {
+			return nil, fmt.Errorf("invalid label selector %q: %s", part, err)
+		}
+		selector = append(selector, m)
+	}
+	return selector, nil
+}
+
+// SelectorFromMap creates an equality selector from the labels of settings.yml, sorted by key
+func SelectorFromMap(labels map[string]string) LabelSelector {
+	var selector LabelSelector
+	for k, v := range labels {
+		selector = append(selector, LabelMatcher{Key: k, Value: v})
+	}
+	sort.Slice(selector, func(i, j int) bool { return selector[i].Key < selector[j].Key })
+	return selector
+}
+
+// Matches report whether all matchers match the labels
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, m := range s {
+		ok, _ := path.Match(m.Value, labels[m.Key])
+		if ok == m.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (s LabelSelector) String() string {
+	parts := make([]string, 0, len(s))
+	for _, m := range s {
+		op := "="
+		if m.Negate {
+			op = "!="
+		}
+		parts = append(parts, m.Key+op+m.Value)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,34 @@
+package model
+
+import "testing"
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"region": "eu-west", "role": "validator"}
+	cases := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"region=eu-*", true},
+		{"region=eu-*, role=validator", true},
+		{"region=us-*", false},
+		{"role!=archive", true},
+		{"role!=valid*", false},
+		{"owner=", true},
+		{"owner!=", false},
+	}
+	for _, c := range cases {
+		selector, err := ParseLabelSelector(c.selector)
+		if err != nil {
+			t.Fatalf("%q: %s", c.selector, err)
+		}
+		if selector.Matches(labels) != c.matches {
+			t.Errorf("%q: expected matches=%t", c.selector, c.matches)
+		}
+	}
+	for _, s := range []string{"region", "=eu", "region=[eu"} {
+		if _, err := ParseLabelSelector(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
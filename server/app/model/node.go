@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// NodeStats is the status report sent by the node after login
+type NodeStats struct {
+	ID    string             `json:"id"`
+	Time  string             `json:"clientTime"`
+	Procs map[string]bool    `json:"procs"`
+	Stats map[string]float64 `json:"stats"`
+}
+
+// CustomMetrics is the metrics aggregated by the statsd listener of the node
+type CustomMetrics struct {
+	ID      string             `json:"id"`
+	Time    string             `json:"clientTime"`
+	Metrics map[string]float64 `json:"metrics"`
+}
+
+// NodeLatency is the latency measured by the node with ping and pong
+type NodeLatency struct {
+	ID      string `json:"id"`
+	Latency string `json:"latency"`
+}
+
+// NodeState is the latest known state of a node
+type NodeState struct {
+	ID        string             `json:"id"`
+	Addr      string             `json:"addr"`
+	Labels    map[string]string  `json:"labels"`    // labels of the client, overridden by the nodeLabels config
+	Inventory map[string]string  `json:"inventory"` // static host facts sent on login
+	Online    bool               `json:"online"`
+	LoginTime time.Time          `json:"loginTime"`
+	LastSeen  time.Time          `json:"lastSeen"`
+	Latency   int64              `json:"latency"` // millisecond, -1 when unknown
+	Procs     map[string]bool    `json:"procs"`
+	Stats     map[string]float64 `json:"stats"`
+	StatsTime time.Time          `json:"statsTime"`
+	Custom    map[string]float64 `json:"custom,omitempty"` // the statsd metrics of the last flush interval
+}
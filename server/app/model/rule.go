@@ -0,0 +1,23 @@
+package model
+
+// comparisons of the metric rules
+var ruleOps = map[string]func(a, b float64) bool{
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// ValidRuleOp reports whether the comparison of a metric rule is supported
+func ValidRuleOp(op string) bool {
+	_, ok := ruleOps[op]
+	return ok
+}
+
+// RuleMatches compares the value with the threshold, an unknown comparison never matches
+func RuleMatches(op string, value, threshold float64) bool {
+	fn, ok := ruleOps[op]
+	return ok && fn(value, threshold)
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRuleMatches(t *testing.T) {
+	cases := []struct {
+		op               string
+		value, threshold float64
+		expected         bool
+	}{
+		{">", 11, 10, true},
+		{">", 10, 10, false},
+		{">=", 10, 10, true},
+		{"<", 9, 10, true},
+		{"<=", 11, 10, false},
+		{"==", 10, 10, true},
+		{"!=", 10, 10, false},
+		{">", math.NaN(), 10, false},
+		{"=>", 11, 10, false},
+	}
+	for _, c := range cases {
+		if RuleMatches(c.op, c.value, c.threshold) != c.expected {
+			t.Errorf("%v %s %v: expected %v", c.value, c.op, c.threshold, c.expected)
+		}
+	}
+	if ValidRuleOp("=>") || !ValidRuleOp("!=") {
+		t.Error("unexpected valid ops")
+	}
+}
@@ -0,0 +1,10 @@
+package model
+
+import "ethstats/common/util/promutil"
+
+// ScrapeReport is the samples of the local exporters scraped by the node
+type ScrapeReport struct {
+	ID      string            `json:"id"`
+	Time    string            `json:"clientTime"`
+	Samples []promutil.Sample `json:"samples"`
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// SilenceTarget describes the alert or digest entry that is checked against the silences
+type SilenceTarget struct {
+	NodeID string
+	Tag    string
+	Proc   string
+	Alert  string
+}
+
+// Silence mutes alerts and digest entries that match all of its non-empty matchers.
+// Every matcher supports shell patterns, e.g. "geth-*".
+// A silence with DailyStart and DailyEnd is a recurring maintenance window,
+// which is active on the given weekdays between the two clock times.
+type Silence struct {
+	ID         string    `json:"id"`
+	NodeID     string    `json:"nodeId,omitempty"`
+	Tag        string    `json:"tag,omitempty"`
+	Proc       string    `json:"proc,omitempty"`
+	Alert      string    `json:"alert,omitempty"`
+	StartsAt   time.Time `json:"startsAt"`
+	EndsAt     time.Time `json:"endsAt"`
+	Weekdays   string    `json:"weekdays,omitempty"`   // e.g. "sun,sat", empty means every day
+	DailyStart string    `json:"dailyStart,omitempty"` // e.g. "02:00"
+	DailyEnd   string    `json:"dailyEnd,omitempty"`   // e.g. "04:00"
+	Timezone   string    `json:"timezone,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	CreatedBy  string    `json:"createdBy,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// IsRecurring report whether the silence is a recurring maintenance window
+func (s *Silence) IsRecurring() bool {
+	return s.DailyStart != "" && s.DailyEnd != ""
+}
+
+// Matches report whether all non-empty matchers of the silence match the target
+func (s *Silence) Matches(target SilenceTarget) bool {
+	return matchPattern(s.NodeID, target.NodeID) &&
+		matchPattern(s.Tag, target.Tag) &&
+		matchPattern(s.Proc, target.Proc) &&
+		matchPattern(s.Alert, target.Alert)
+}
+
+// ActiveAt report whether the silence is in effect at the given time
+func (s *Silence) ActiveAt(t time.Time) bool {
+	if !s.StartsAt.IsZero() && t.Before(s.StartsAt) {
+		return false
+	}
+	if !s.EndsAt.IsZero() && !t.Before(s.EndsAt) {
+		return false
+	}
+	if !s.IsRecurring() {
+		return true
+	}
+
+	loc := time.Local
+	if s.Timezone != "" {
+		l, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return false
+		}
+		loc = l
+	}
+	t = t.In(loc)
+	start, err := time.ParseInLocation("15:04", s.DailyStart, loc)
+	if err != nil {
+		return false
+	}
+	end, err := time.ParseInLocation("15:04", s.DailyEnd, loc)
+	if err != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	// a window like 23:00-01:00 crosses midnight and belongs to the day it starts
+	day := t.Weekday()
+	if from <= to {
+		if now < from || now >= to {
+			return false
+		}
+	} else {
+		if now < from && now >= to {
+			return false
+		}
+		if now < to {
+			day = (day + 6) % 7
+		}
+	}
+	return matchWeekday(s.Weekdays, day)
+}
+
+// matchPattern match the value with a shell pattern, an empty pattern matches any value
+func matchPattern(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
+
+// ValidWeekdays report whether every day of the comma separated list is a weekday like "sun" or "sunday"
+func ValidWeekdays(weekdays string) bool {
+	if strings.TrimSpace(weekdays) == "" {
+		return true
+	}
+	for _, w := range strings.Split(weekdays, ",") {
+		w = strings.ToLower(strings.TrimSpace(w))
+		valid := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if len(w) >= 3 && strings.HasPrefix(strings.ToLower(day.String()), w) {
+				valid = true
+			}
+		}
+		if !valid {
+			return false
+		}
+	}
+	return true
+}
+
+// matchWeekday check the weekday against a comma separated list like "sun,sat"
+func matchWeekday(weekdays string, day time.Weekday) bool {
+	if strings.TrimSpace(weekdays) == "" {
+		return true
+	}
+	for _, w := range strings.Split(weekdays, ",") {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if len(w) >= 3 && strings.HasPrefix(strings.ToLower(day.String()), w[:3]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSilenceActiveAt(t *testing.T) {
+	window := &Silence{Weekdays: "sun", DailyStart: "23:00", DailyEnd: "01:00", Timezone: "UTC"}
+	cases := []struct {
+		time   string
+		active bool
+	}{
+		{"2024-06-02 23:30", true},  // sunday
+		{"2024-06-03 00:30", true},  // monday, but the window started on sunday
+		{"2024-06-03 01:00", false}, // window closed
+		{"2024-06-03 23:30", false}, // monday
+	}
+	for _, c := range cases {
+		tm, _ := time.ParseInLocation("2006-01-02 15:04", c.time, time.UTC)
+		if window.ActiveAt(tm) != c.active {
+			t.Errorf("%s: expected active=%t", c.time, c.active)
+		}
+	}
+
+	silence := &Silence{NodeID: "geth-*", Proc: "geth", EndsAt: time.Now().Add(time.Hour)}
+	if !silence.ActiveAt(time.Now()) || !silence.Matches(SilenceTarget{NodeID: "geth-01", Proc: "geth"}) {
+		t.Error("expected the silence to match geth-01")
+	}
+	if silence.Matches(SilenceTarget{NodeID: "storage-01", Proc: "geth"}) {
+		t.Error("expected the silence not to match storage-01")
+	}
+}
+
+func TestValidWeekdays(t *testing.T) {
+	for _, w := range []string{"", "sun", "sun, Sat", "monday,tue"} {
+		if !ValidWeekdays(w) {
+			t.Errorf("%q: expected valid weekdays", w)
+		}
+	}
+	for _, w := range []string{"su", "sun,", "sunny", "weekend"} {
+		if ValidWeekdays(w) {
+			t.Errorf("%q: expected invalid weekdays", w)
+		}
+	}
+}
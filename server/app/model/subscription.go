@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// topics of the dashboard subscriptions
+const (
+	TopicNodes   = "nodes"   // login, logout and process state of the nodes, the item is a NodeState
+	TopicMetrics = "metrics" // latency and host metrics of the nodes, the item is a NodeMetrics
+	TopicEvents  = "events"  // aggregated events, the item is an Event
+	TopicAlerts  = "alerts"  // sent alerts, the item is an AlertRecord
+)
+
+// Subscription is the request of a dashboard to receive the snapshot and the deltas of the topics
+type Subscription struct {
+	Topics []string `json:"topics"`
+	Nodes  []string `json:"nodes"`  // patterns of the node ids, empty means all nodes
+	Labels string   `json:"labels"` // label selector, e.g. "region=eu,role!=archive"
+}
+
+// Update is the snapshot or the delta of a topic sent to a dashboard
+type Update struct {
+	Topic string      `json:"topic"`
+	Items interface{} `json:"items,omitempty"` // snapshot
+	Item  interface{} `json:"item,omitempty"`  // delta
+}
+
+// NodeMetrics is the latest latency and host metrics of a node
+type NodeMetrics struct {
+	ID        string             `json:"id"`
+	Latency   int64              `json:"latency"`
+	Stats     map[string]float64 `json:"stats"`
+	StatsTime time.Time          `json:"statsTime"`
+	Custom    map[string]float64 `json:"custom,omitempty"` // the statsd metrics of the node
+}
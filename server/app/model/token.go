@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// scopes of the api tokens, a scope includes the permissions of the scopes before it
+const (
+	ScopeRead    = "read"    // read the api and subscribe the dashboard websocket
+	ScopeOperate = "operate" // create and remove silences, acknowledge events
+	ScopeAdmin   = "admin"   // manage the tokens and the users
+)
+
+// scopeLevels orders the scopes by their permissions
+var scopeLevels = map[string]int{ScopeRead: 1, ScopeOperate: 2, ScopeAdmin: 3}
+
+// ValidScope reports whether the scope is known
+func ValidScope(scope string) bool {
+	return scopeLevels[scope] > 0
+}
+
+// ScopeAllows reports whether the scope has the permissions of the required scope
+func ScopeAllows(scope, required string) bool {
+	return ValidScope(scope) && scopeLevels[scope] >= scopeLevels[required]
+}
+
+// Token is an api token, only the sha256 hash of the secret is stored
+type Token struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scope     string    `json:"scope"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+}
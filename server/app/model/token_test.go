@@ -0,0 +1,31 @@
+package model
+
+import "testing"
+
+func TestScopeAllows(t *testing.T) {
+	cases := []struct {
+		scope, required string
+		allows          bool
+	}{
+		{ScopeRead, ScopeRead, true},
+		{ScopeRead, ScopeOperate, false},
+		{ScopeOperate, ScopeRead, true},
+		{ScopeOperate, ScopeAdmin, false},
+		{ScopeAdmin, ScopeOperate, true},
+		{"", ScopeRead, false},
+		{"root", ScopeRead, false},
+	}
+	for _, c := range cases {
+		if ScopeAllows(c.scope, c.required) != c.allows {
+			t.Errorf("%q requires %q: expected allows=%t", c.scope, c.required, c.allows)
+		}
+	}
+	for role, scope := range map[string]string{RoleViewer: ScopeRead, RoleOperator: ScopeOperate, RoleAdmin: ScopeAdmin} {
+		if s, ok := RoleScope(role); !ok || s != scope {
+			t.Errorf("role %s: expected scope %s, got %s", role, scope, s)
+		}
+	}
+	if _, ok := RoleScope("root"); ok {
+		t.Error("unknown role must have no scope")
+	}
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// roles of the dashboard users
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// roleScopes are the api scopes of the roles
+var roleScopes = map[string]string{RoleViewer: ScopeRead, RoleOperator: ScopeOperate, RoleAdmin: ScopeAdmin}
+
+// RoleScope returns the api scope of the role
+func RoleScope(role string) (string, bool) {
+	scope, ok := roleScopes[role]
+	return scope, ok
+}
+
+// User is a dashboard account, the password is stored as bcrypt hash
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"passwordHash"`
+	Role         string    `json:"role"`
+	Labels       string    `json:"labels,omitempty"` // label selector of the visible nodes, empty means all nodes
+	CreatedAt    time.Time `json:"createdAt"`
+}
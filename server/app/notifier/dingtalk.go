@@ -0,0 +1,87 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"ethstats/server/config"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const dingTalkMaxLength = 20000
+
+// dingTalk robots accept 20 messages per minute
+var dingTalkLimiters = newLimiters(limit{count: 20, period: time.Minute})
+
+// DingTalk sends markdown messages to a dingtalk custom robot.
+// Url is the webhook of the robot, e.g. https://oapi.dingtalk.com/robot/send?access_token=xxx,
+// Secret is the "加签" secret of the robot, the keyword security setting needs no secret
+type DingTalk struct {
+	base
+	url     string
+	secret  string
+	retry   int
+	client  *http.Client
+	limiter *rateLimiter
+}
+
+// NewDingTalk creates a dingtalk robot notifier
+func NewDingTalk(item config.Notifier) (*DingTalk, error) {
+	if item.Url == "" {
+		return nil, errors.New("url can't empty")
+	}
+	return &DingTalk{
+		base:    newBase(item),
+		url:     item.Url,
+		secret:  item.Secret,
+		retry:   item.Retry,
+		client:  newHttpClient(item.Timeout),
+		limiter: dingTalkLimiters.get(item.Url),
+	}, nil
+}
+
+func (d *DingTalk) Notify(msg *Message) error {
+	payload := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": msg.Subject,
+			"text":  truncate(markdown(msg, true, "\n\n"), dingTalkMaxLength),
+		},
+	}
+	return retry(d.retry, func() error {
+		d.limiter.Wait()
+		target, err := d.signedUrl(time.Now())
+		if err != nil {
+			return err
+		}
+		return postRobot(d.client, target, payload)
+	})
+}
+
+// signedUrl appends the timestamp and sign parameters when a secret is configured
+func (d *DingTalk) signedUrl(now time.Time) (string, error) {
+	if d.secret == "" {
+		return d.url, nil
+	}
+	u, err := url.Parse(d.url)
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	query := u.Query()
+	query.Set("timestamp", timestamp)
+	query.Set("sign", DingTalkSign(d.secret, timestamp))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// DingTalkSign returns base64(hmac_sha256(secret, timestamp + "\n" + secret)), timestamp is in milliseconds
+func DingTalkSign(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,87 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"ethstats/server/config"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	discordTitleMaxLength       = 256
+	discordDescriptionMaxLength = 4096
+
+	discordColorReport = 0x3498db
+	discordColorAlert  = 0xe74c3c
+)
+
+// discord webhooks allow 5 requests per 2 seconds
+var discordLimiters = newLimiters(limit{count: 5, period: 2 * time.Second})
+
+// Discord sends embeds to a discord webhook, Url is the webhook,
+// e.g. https://discord.com/api/webhooks/xxx/yyy. Long messages are split into several embeds
+type Discord struct {
+	base
+	url     string
+	retry   int
+	client  *http.Client
+	limiter *rateLimiter
+}
+
+// NewDiscord creates a discord webhook notifier
+func NewDiscord(item config.Notifier) (*Discord, error) {
+	if item.Url == "" {
+		return nil, errors.New("url can't empty")
+	}
+	return &Discord{
+		base:    newBase(item),
+		url:     item.Url,
+		retry:   item.Retry,
+		client:  newHttpClient(item.Timeout),
+		limiter: discordLimiters.get(item.Url),
+	}, nil
+}
+
+func (d *Discord) Notify(msg *Message) error {
+	title := strings.TrimSpace(msg.Subject)
+	if utf8.RuneCountInString(title) > discordTitleMaxLength {
+		title = string([]rune(title)[:discordTitleMaxLength-3]) + "..."
+	}
+	color := discordColorReport
+	if msg.Kind == KindAlert {
+		color = discordColorAlert
+	}
+
+	// a message is limited to 6000 characters in all embeds, so every part is sent alone.
+	// The first embed carries the title and is sent even without content
+	parts := split(strings.TrimSpace(msg.Content), discordDescriptionMaxLength)
+	for i := 0; i == 0 || i < len(parts); i++ {
+		embed := map[string]interface{}{
+			"color": color,
+		}
+		if i < len(parts) {
+			embed["description"] = parts[i]
+		}
+		if i == 0 {
+			embed["title"] = title
+			embed["timestamp"] = msg.Time.Format(time.RFC3339)
+		}
+		payload := map[string]interface{}{"embeds": []interface{}{embed}}
+		err := retry(d.retry, func() error {
+			d.limiter.Wait()
+			body, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+			_, err = postJSON(d.client, d.url, body, nil)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
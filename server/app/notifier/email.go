@@ -0,0 +1,37 @@
+package notifier
+
+import (
+	"ethstats/common/util/emailutil"
+	"ethstats/server/config"
+)
+
+// Email sends the messages with the smtp server of the email config
+type Email struct {
+	base
+	to string
+}
+
+// NewEmail creates an email notifier, the recipients of the email config are used when To is empty
+func NewEmail(item config.Notifier) *Email {
+	return &Email{
+		base: newBase(item),
+		to:   item.To,
+	}
+}
+
+func (e *Email) Notify(msg *Message) error {
+	to := msg.To
+	if to == "" {
+		to = e.to
+	}
+	if to == "" {
+		to = config.EmailConfig.ToEmail
+	}
+	subject := config.EmailConfig.SubjectPrefix + " " + msg.Subject
+	if msg.Html != "" {
+		return emailutil.SendEmailAlternative(emailutil.DefaultOptions(), to, subject, config.EmailConfig.FromEmail,
+			"text/plain", msg.Content, "text/html", msg.Html)
+	}
+	return emailutil.SendEmailWithOptions(emailutil.DefaultOptions(), to, subject,
+		msg.Content, config.EmailConfig.FromEmail, config.EmailConfig.ContentType)
+}
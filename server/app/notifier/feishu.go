@@ -0,0 +1,81 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"ethstats/server/config"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const feishuMaxLength = 20000
+
+// feishu bots accept 100 messages per minute and 5 per second
+var feishuLimiters = newLimiters(limit{count: 100, period: time.Minute}, limit{count: 5, period: time.Second})
+
+// Feishu sends interactive cards to a feishu/lark custom bot.
+// Url is the webhook of the bot, e.g. https://open.feishu.cn/open-apis/bot/v2/hook/xxx,
+// Lark users use https://open.larksuite.com/open-apis/bot/v2/hook/xxx.
+// Secret is the signature verification secret of the bot
+type Feishu struct {
+	base
+	url     string
+	secret  string
+	retry   int
+	client  *http.Client
+	limiter *rateLimiter
+}
+
+// NewFeishu creates a feishu/lark bot notifier
+func NewFeishu(item config.Notifier) (*Feishu, error) {
+	if item.Url == "" {
+		return nil, errors.New("url can't empty")
+	}
+	return &Feishu{
+		base:    newBase(item),
+		url:     item.Url,
+		secret:  item.Secret,
+		retry:   item.Retry,
+		client:  newHttpClient(item.Timeout),
+		limiter: feishuLimiters.get(item.Url),
+	}, nil
+}
+
+func (f *Feishu) Notify(msg *Message) error {
+	template := "blue"
+	if msg.Kind == KindAlert {
+		template = "red"
+	}
+	card := map[string]interface{}{
+		"config": map[string]bool{"wide_screen_mode": true},
+		"header": map[string]interface{}{
+			"template": template,
+			"title":    map[string]string{"tag": "plain_text", "content": msg.Subject},
+		},
+		"elements": []interface{}{
+			map[string]string{"tag": "markdown", "content": truncate(markdown(msg, false, "\n"), feishuMaxLength)},
+		},
+	}
+	return retry(f.retry, func() error {
+		f.limiter.Wait()
+		payload := map[string]interface{}{
+			"msg_type": "interactive",
+			"card":     card,
+		}
+		if f.secret != "" {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			payload["timestamp"] = timestamp
+			payload["sign"] = FeishuSign(f.secret, timestamp)
+		}
+		return postRobot(f.client, f.url, payload)
+	})
+}
+
+// FeishuSign returns base64(hmac_sha256(key: timestamp + "\n" + secret, data: empty)), timestamp is in seconds
+func FeishuSign(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,168 @@
+package notifier
+
+import (
+	"errors"
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	KindReport = "report" //periodic monitor report
+	KindAlert  = "alert"  //real-time alert
+
+	TypeEmail    = "email"
+	TypeWebhook  = "webhook"
+	TypeDingTalk = "dingtalk"
+	TypeFeishu   = "feishu"
+	TypeLark     = "lark"
+	TypeWeCom    = "wecom"
+	TypeTelegram = "telegram"
+	TypeSlack    = "slack"
+	TypeDiscord  = "discord"
+)
+
+// Message is the content delivered by every notifier
+type Message struct {
+	Kind    string       `json:"kind"`
+	Server  string       `json:"server"`
+	Subject string       `json:"subject"`
+	Content string       `json:"content"`
+	Html    string       `json:"html,omitempty"` // html version of the content, only used by email
+	To      string       `json:"to,omitempty"`   // email recipients of the message, override the recipients of the notifier
+	Time    time.Time    `json:"time"`
+	Alert   *model.Alert `json:"alert,omitempty"`
+}
+
+// Notifier delivers messages to one target
+type Notifier interface {
+	// Name is the unique name of the notifier in settings.yml
+	Name() string
+	// Accept report whether the notifier wants the kind of message
+	Accept(kind string) bool
+	// Notify delivers the message
+	Notify(msg *Message) error
+}
+
+// Notifiers is the list of all configured notifiers
+type Notifiers []Notifier
+
+// New creates the notifiers from the notifiers config.
+// When no notifier is configured, the email config is used to send the reports, as before
+func New(items []config.Notifier) (Notifiers, error) {
+	if len(items) == 0 {
+		if config.EmailConfig.Host == "" || config.EmailConfig.ToEmail == "" {
+			return Notifiers{}, nil
+		}
+		return Notifiers{NewEmail(config.Notifier{Name: TypeEmail, Kinds: []string{KindReport}})}, nil
+	}
+	var result Notifiers
+	names := make(map[string]bool)
+	for _, item := range items {
+		if item.Name == "" {
+			item.Name = item.Type
+		}
+		if names[item.Name] {
+			return nil, fmt.Errorf("notifier name [%s] is repeated", item.Name)
+		}
+		names[item.Name] = true
+		n, err := newNotifier(item)
+		if err != nil {
+			return nil, fmt.Errorf("notifier [%s]: %s", item.Name, err)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func newNotifier(item config.Notifier) (Notifier, error) {
+	switch strings.ToLower(item.Type) {
+	case TypeEmail:
+		return NewEmail(item), nil
+	case TypeWebhook:
+		return NewWebhook(item)
+	case TypeDingTalk:
+		return NewDingTalk(item)
+	case TypeFeishu, TypeLark:
+		return NewFeishu(item)
+	case TypeWeCom:
+		return NewWeCom(item)
+	case TypeTelegram:
+		return NewTelegram(item)
+	case TypeSlack:
+		return NewSlack(item)
+	case TypeDiscord:
+		return NewDiscord(item)
+	}
+	return nil, fmt.Errorf("unknown notifier type: %s", item.Type)
+}
+
+// Select returns the notifiers with the given names, all notifiers when names is empty
+func (ns Notifiers) Select(names []string) (Notifiers, error) {
+	if len(names) == 0 {
+		return ns, nil
+	}
+	var result Notifiers
+	for _, name := range names {
+		found := false
+		for _, n := range ns {
+			if n.Name() == name {
+				result = append(result, n)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("notifier [%s] isn't configured", name)
+		}
+	}
+	return result, nil
+}
+
+// Send delivers the message to all notifiers that accept its kind and joins the errors
+func (ns Notifiers) Send(msg *Message) error {
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	if msg.Server == "" {
+		msg.Server = config.ApplicationConfig.Name
+	}
+	var errs []error
+	for _, n := range ns {
+		if !n.Accept(msg.Kind) {
+			continue
+		}
+		if err := n.Notify(msg); err != nil {
+			errs = append(errs, fmt.Errorf("notifier [%s]: %s", n.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// base holds the fields shared by all notifiers
+type base struct {
+	name  string
+	kinds []string
+}
+
+func newBase(item config.Notifier) base {
+	return base{name: item.Name, kinds: item.Kinds}
+}
+
+func (b *base) Name() string {
+	return b.name
+}
+
+func (b *base) Accept(kind string) bool {
+	if len(b.kinds) == 0 {
+		return true
+	}
+	for _, k := range b.kinds {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,305 @@
+package notifier
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"ethstats/server/config"
+	"github.com/bitxx/logger/logbase"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultOutboxPath = "files/outbox"
+
+	defaultMinBackoff = 30   //second
+	defaultMaxBackoff = 3600 //second
+	maxSentHistory    = 100
+
+	StateQueued = "queued"
+	StateSent   = "sent"
+)
+
+// OutboxItem is a message waiting for delivery, or a recently delivered one
+type OutboxItem struct {
+	ID          string    `json:"id"`
+	Notifier    string    `json:"notifier"`
+	State       string    `json:"state"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"lastError,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	NextAttempt time.Time `json:"nextAttempt,omitempty"`
+	SentAt      time.Time `json:"sentAt,omitempty"`
+	Message     *Message  `json:"message"`
+}
+
+// Outbox writes every message to disk before delivery and retries it with exponential backoff.
+// The file of a message is removed only after the notifier accepted it
+type Outbox struct {
+	dir        string
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	logger     *logbase.Helper
+	lock       sync.Mutex
+	queued     map[string]*OutboxItem
+	sent       []*OutboxItem
+	targets    map[string]Notifier
+	failures   map[string]uint64 // notifier => count of the failed attempts
+	wake       chan struct{}
+	now        func() time.Time // the clock of the attempts, replaced by the tests
+}
+
+// NewOutbox creates the outbox and loads the messages left by the last run
+func NewOutbox(logger *logbase.Helper) (*Outbox, error) {
+	o := &Outbox{
+		dir:        config.OutboxConfig.Path,
+		minBackoff: time.Duration(config.OutboxConfig.MinBackoff) * time.Second,
+		maxBackoff: time.Duration(config.OutboxConfig.MaxBackoff) * time.Second,
+		logger:     logger,
+		queued:     make(map[string]*OutboxItem),
+		targets:    make(map[string]Notifier),
+		failures:   make(map[string]uint64),
+		wake:       make(chan struct{}, 1),
+		now:        time.Now,
+	}
+	if o.dir == "" {
+		o.dir = DefaultOutboxPath
+	}
+	if o.minBackoff <= 0 {
+		o.minBackoff = defaultMinBackoff * time.Second
+	}
+	if o.maxBackoff <= 0 {
+		o.maxBackoff = defaultMaxBackoff * time.Second
+	}
+	if err := os.MkdirAll(o.dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(o.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var item OutboxItem
+		if err = json.Unmarshal(content, &item); err != nil {
+			logger.Errorf("skip broken outbox file %s: %s", file, err)
+			continue
+		}
+		o.queued[item.ID] = &item
+	}
+	if len(o.queued) > 0 {
+		logger.Infof("outbox loaded %d queued messages", len(o.queued))
+	}
+	return o, nil
+}
+
+// Wrap replaces the email notifiers with notifiers that deliver through the outbox
+func (o *Outbox) Wrap(ns Notifiers) Notifiers {
+	result := make(Notifiers, 0, len(ns))
+	for _, n := range ns {
+		if _, ok := n.(*Email); ok {
+			o.targets[n.Name()] = n
+			n = &queuedNotifier{Notifier: n, outbox: o}
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+// Start runs the delivery loop
+func (o *Outbox) Start() {
+	go o.loop()
+}
+
+// Enqueue persists the message for the notifier and wakes the delivery loop
+func (o *Outbox) Enqueue(notifier string, msg *Message) error {
+	item := &OutboxItem{
+		ID:          newOutboxID(),
+		Notifier:    notifier,
+		State:       StateQueued,
+		CreatedAt:   o.now(),
+		NextAttempt: o.now(),
+		Message:     msg,
+	}
+	o.lock.Lock()
+	err := o.save(item)
+	if err == nil {
+		o.queued[item.ID] = item
+	}
+	o.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Items returns the queued messages followed by the recently sent ones
+func (o *Outbox) Items() []OutboxItem {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	result := make([]OutboxItem, 0, len(o.queued)+len(o.sent))
+	for _, item := range o.queued {
+		result = append(result, *item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	for i := len(o.sent) - 1; i >= 0; i-- {
+		result = append(result, *o.sent[i])
+	}
+	return result
+}
+
+// Failures returns the count of the failed delivery attempts of every wrapped notifier since the server started
+func (o *Outbox) Failures() map[string]uint64 {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	result := make(map[string]uint64, len(o.targets))
+	for name := range o.targets {
+		result[name] = 0
+	}
+	for k, v := range o.failures {
+		result[k] = v
+	}
+	return result
+}
+
+func (o *Outbox) loop() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-o.wake:
+		}
+		next := o.deliver()
+		timer.Stop()
+		timer.Reset(time.Until(next))
+	}
+}
+
+// deliver tries all due messages once and returns the time of the next due message
+func (o *Outbox) deliver() time.Time {
+	now := o.now()
+	next := now.Add(o.maxBackoff)
+	for _, item := range o.due(now) {
+		target := o.targets[item.Notifier]
+		var err error
+		if target == nil {
+			err = errors.New("notifier is not configured")
+		} else {
+			err = target.Notify(item.Message)
+		}
+
+		o.lock.Lock()
+		item.Attempts++
+		if err == nil {
+			item.State = StateSent
+			item.SentAt = o.now()
+			item.NextAttempt = time.Time{}
+			delete(o.queued, item.ID)
+			if rmErr := os.Remove(o.file(item.ID)); rmErr != nil && !os.IsNotExist(rmErr) {
+				o.logger.Errorf("remove outbox file error: %s", rmErr)
+			}
+			o.sent = append(o.sent, item)
+			if len(o.sent) > maxSentHistory {
+				o.sent = o.sent[len(o.sent)-maxSentHistory:]
+			}
+			o.logger.Infof("outbox message %s delivered by %s after %d attempts", item.ID, item.Notifier, item.Attempts)
+		} else {
+			item.LastError = err.Error()
+			o.failures[item.Notifier]++
+			item.NextAttempt = o.now().Add(o.backoff(item.Attempts))
+			if saveErr := o.save(item); saveErr != nil {
+				o.logger.Errorf("save outbox file error: %s", saveErr)
+			}
+			o.logger.Errorf("outbox message %s attempt %d by %s error: %s, retry at %s", item.ID, item.Attempts,
+				item.Notifier, err, item.NextAttempt.Format("2006-01-02 15:04:05"))
+		}
+		o.lock.Unlock()
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for _, item := range o.queued {
+		if item.NextAttempt.Before(next) {
+			next = item.NextAttempt
+		}
+	}
+	return next
+}
+
+// due returns the queued messages whose next attempt is not later than now
+func (o *Outbox) due(now time.Time) []*OutboxItem {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	var result []*OutboxItem
+	for _, item := range o.queued {
+		if !item.NextAttempt.After(now) {
+			result = append(result, item)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// backoff doubles the delay after every failed attempt, up to the max backoff
+func (o *Outbox) backoff(attempts int) time.Duration {
+	d := o.minBackoff
+	for i := 1; i < attempts && d < o.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > o.maxBackoff {
+		d = o.maxBackoff
+	}
+	return d
+}
+
+func (o *Outbox) file(id string) string {
+	return filepath.Join(o.dir, id+".json")
+}
+
+// save writes the item to a temporary file and renames it
+func (o *Outbox) save(item *OutboxItem) error {
+	content, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := o.file(item.ID) + ".tmp"
+	if err = os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.file(item.ID))
+}
+
+// newOutboxID returns a sortable id made of the time and a random suffix
+func newOutboxID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return strings.ReplaceAll(time.Now().Format("20060102150405.000"), ".", "") + "-" + hex.EncodeToString(b)
+}
+
+// queuedNotifier hands the messages of a notifier over to the outbox
+type queuedNotifier struct {
+	Notifier
+	outbox *Outbox
+}
+
+func (q *queuedNotifier) Notify(msg *Message) error {
+	return q.outbox.Enqueue(q.Name(), msg)
+}
@@ -0,0 +1,63 @@
+package notifier
+
+import (
+	"errors"
+	"ethstats/server/config"
+	"github.com/bitxx/logger"
+	"testing"
+	"time"
+)
+
+type flakyNotifier struct {
+	base
+	fails int
+	calls int
+}
+
+func (f *flakyNotifier) Notify(msg *Message) error {
+	f.calls++
+	if f.calls <= f.fails {
+		return errors.New("smtp server is down")
+	}
+	return nil
+}
+
+func TestOutboxDeliver(t *testing.T) {
+	config.OutboxConfig.Path = t.TempDir()
+	config.OutboxConfig.MinBackoff = 30
+	outbox, err := NewOutbox(logger.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := &flakyNotifier{base: base{name: "email"}, fails: 1}
+	outbox.targets[target.name] = target
+	if err = outbox.Enqueue(target.name, &Message{Subject: "report", Content: "report"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the message survives a restart while it is not delivered
+	outbox.deliver()
+	reloaded, _ := NewOutbox(logger.NewLogger())
+	items := reloaded.Items()
+	if len(items) != 1 || items[0].Attempts != 1 || items[0].LastError == "" {
+		t.Fatalf("expected 1 queued message with 1 failed attempt, got %+v", items)
+	}
+
+	// the message isn't retried before its backoff ends
+	now := time.Now()
+	reloaded.now = func() time.Time { return now }
+	reloaded.targets[target.name] = target
+	reloaded.deliver()
+	if items = reloaded.Items(); items[0].Attempts != 1 {
+		t.Fatalf("expected no attempt before the backoff ends, got %+v", items)
+	}
+	now = now.Add(31 * time.Second)
+	reloaded.deliver()
+	items = reloaded.Items()
+	if len(items) != 1 || items[0].State != StateSent || items[0].Attempts != 2 {
+		t.Fatalf("expected 1 sent message after 2 attempts, got %+v", items)
+	}
+	if again, _ := NewOutbox(logger.NewLogger()); len(again.Items()) != 0 {
+		t.Error("expected the outbox file to be removed")
+	}
+}
@@ -0,0 +1,84 @@
+package notifier
+
+import (
+	"sync"
+	"time"
+)
+
+// limit allows count messages per period
+type limit struct {
+	count  int
+	period time.Duration
+}
+
+// rateLimiter blocks the sender until all limits of the target platform allow the next message
+type rateLimiter struct {
+	lock   sync.Mutex
+	limits []limit
+	sent   []time.Time
+}
+
+func newRateLimiter(limits ...limit) *rateLimiter {
+	return &rateLimiter{limits: limits}
+}
+
+// Wait blocks until the next message can be sent and records it
+func (r *rateLimiter) Wait() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for {
+		now := time.Now()
+		r.expire(now)
+		wait := time.Duration(0)
+		for _, l := range r.limits {
+			// the sent times are in order, the count-th newest one decides when a slot is free
+			if len(r.sent) < l.count {
+				continue
+			}
+			oldest := r.sent[len(r.sent)-l.count]
+			if d := oldest.Add(l.period).Sub(now); d > wait {
+				wait = d
+			}
+		}
+		if wait <= 0 {
+			r.sent = append(r.sent, now)
+			return
+		}
+		time.Sleep(wait)
+	}
+}
+
+// expire drops the sent times that are outside every period
+func (r *rateLimiter) expire(now time.Time) {
+	longest := time.Duration(0)
+	for _, l := range r.limits {
+		if l.period > longest {
+			longest = l.period
+		}
+	}
+	i := 0
+	for i < len(r.sent) && now.Sub(r.sent[i]) >= longest {
+		i++
+	}
+	r.sent = r.sent[i:]
+}
+
+// limiters shares one rate limiter per robot url, two notifiers may post to the same robot
+type limiters struct {
+	lock   sync.Mutex
+	limits []limit
+	items  map[string]*rateLimiter
+}
+
+func newLimiters(limits ...limit) *limiters {
+	return &limiters{limits: limits, items: make(map[string]*rateLimiter)}
+}
+
+func (l *limiters) get(url string) *rateLimiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.items[url] == nil {
+		l.items[url] = newRateLimiter(l.limits...)
+	}
+	return l.items[url]
+}
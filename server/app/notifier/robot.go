@@ -0,0 +1,99 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// robotResponse covers the result fields of the dingtalk, feishu and wecom robots
+type robotResponse struct {
+	ErrCode *int   `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+	Code    *int   `json:"code"`
+	Msg     string `json:"msg"`
+}
+
+// postRobot posts the payload to an im robot, the robots answer 200 even on errors,
+// so the error code of the response body is checked too
+func postRobot(client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	content, err := postJSON(client, url, body, nil)
+	if err != nil {
+		return err
+	}
+	var resp robotResponse
+	if err = json.Unmarshal(content, &resp); err != nil {
+		return nil
+	}
+	if resp.ErrCode != nil && *resp.ErrCode != 0 {
+		return fmt.Errorf("robot error %d: %s", *resp.ErrCode, resp.ErrMsg)
+	}
+	if resp.Code != nil && *resp.Code != 0 {
+		return fmt.Errorf("robot error %d: %s", *resp.Code, resp.Msg)
+	}
+	return nil
+}
+
+// markdown renders the message as markdown, lines are separated with sep
+// because the platforms disagree on what a line break is
+func markdown(msg *Message, withTitle bool, sep string) string {
+	var b strings.Builder
+	if withTitle {
+		b.WriteString("### " + strings.TrimSpace(msg.Subject) + sep)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(msg.Content), "\n") {
+		line = strings.TrimRight(line, "\r ")
+		if line == "" {
+			continue
+		}
+		if strings.HasSuffix(line, ":") && !strings.HasPrefix(line, "node:") {
+			line = "**" + line + "**"
+		}
+		b.WriteString(line + sep)
+	}
+	return b.String()
+}
+
+// truncate cuts the text to at most max bytes without breaking a utf8 character
+func truncate(text string, max int) string {
+	const suffix = "\n..."
+	if len(text) <= max {
+		return text
+	}
+	cut := max - len(suffix)
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + suffix
+}
+
+// split cuts the text into parts of at most max characters, preferably at line breaks
+func split(text string, max int) []string {
+	var parts []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			parts = append(parts, strings.TrimRight(string(current), "\n"))
+			current = current[:0]
+		}
+	}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		runes := []rune(line)
+		if len(current)+len(runes) > max {
+			flush()
+		}
+		for len(runes) > max {
+			parts = append(parts, string(runes[:max]))
+			runes = runes[max:]
+		}
+		current = append(current, runes...)
+	}
+	flush()
+	return parts
+}
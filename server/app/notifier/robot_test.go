@@ -0,0 +1,92 @@
+package notifier
+
+import (
+	"encoding/json"
+	"ethstats/server/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRobotSign(t *testing.T) {
+	if sign := DingTalkSign("SEC123", "1700000000000"); sign != "lkcPI1uoxBY1gUnCnnPH1Kkru0Hqjo7rFpA3haIVhEQ=" {
+		t.Errorf("invalid dingtalk sign: %s", sign)
+	}
+	if sign := FeishuSign("SEC123", "1700000000"); sign != "j/tImR0k8vYXRsYw0+GHVQkV1v/J/8obOuMU7PE/KDo=" {
+		t.Errorf("invalid feishu sign: %s", sign)
+	}
+}
+
+func TestRobotError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errcode":310000,"errmsg":"sign not match"}`))
+	}))
+	defer server.Close()
+
+	robot, _ := NewDingTalk(config.Notifier{Url: server.URL + "?access_token=x", Secret: "SEC123"})
+	err := robot.Notify(&Message{Subject: "test", Content: "test"})
+	if err == nil || !strings.Contains(err.Error(), "310000") {
+		t.Errorf("expected robot error, got %v", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	text := strings.Repeat("geth-01 proc-down\n", 300)
+	parts := split(text, 4096)
+	if len(parts) != 2 || strings.Join(parts, "\n")+"\n" != text {
+		t.Errorf("expected 2 parts without loss, got %d", len(parts))
+	}
+	for _, part := range split(strings.Repeat("节点", 5000), 4096) {
+		if len([]rune(part)) > 4096 {
+			t.Errorf("part is too long: %d", len([]rune(part)))
+		}
+	}
+}
+
+func TestTelegramNotify(t *testing.T) {
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:abc/sendMessage" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		var payload map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		texts = append(texts, payload["text"].(string))
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	telegram, _ := NewTelegram(config.Notifier{BaseUrl: server.URL, Token: "123:abc", ChatID: "-100"})
+	err := telegram.Notify(&Message{Subject: "report", Content: strings.Repeat("geth-01 proc-down\n", 300)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(texts) != 2 || !strings.HasPrefix(texts[0], "report") {
+		t.Errorf("expected 2 messages, got %d", len(texts))
+	}
+}
+
+func TestDiscordNotifyWithoutContent(t *testing.T) {
+	var embeds []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Embeds []map[string]interface{} `json:"embeds"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		embeds = append(embeds, payload.Embeds...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	discord, _ := NewDiscord(config.Notifier{Url: server.URL + "/api/webhooks/1/abc"})
+	for _, content := range []string{"", " \n "} {
+		embeds = nil
+		if err := discord.Notify(&Message{Kind: KindAlert, Subject: "[critical] geth-01 proc-down", Content: content}); err != nil {
+			t.Fatal(err)
+		}
+		if len(embeds) != 1 || embeds[0]["title"] != "[critical] geth-01 proc-down" || embeds[0]["description"] != nil {
+			t.Errorf("%q: expected 1 embed with the title only, got %v", content, embeds)
+		}
+	}
+}
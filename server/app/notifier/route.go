@@ -0,0 +1,138 @@
+package notifier
+
+import (
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Router selects the receivers of an alert with the routing tree of the routes config
+type Router struct {
+	routes   []*route
+	fallback Notifiers
+}
+
+// route is a node of the routing tree with the resolved receivers
+type route struct {
+	config.Route
+	labels    model.LabelSelector
+	receivers Notifiers
+	children  []*route
+}
+
+// NewRouter creates the routing tree, every receiver must be the name of a notifier.
+// Alerts that match no route are sent to all notifiers, as before
+func NewRouter(items []config.Route, ns Notifiers) (*Router, error) {
+	routes, err := newRoutes(items, ns)
+	if err != nil {
+		return nil, err
+	}
+	return &Router{routes: routes, fallback: ns}, nil
+}
+
+func newRoutes(items []config.Route, ns Notifiers) ([]*route, error) {
+	var routes []*route
+	for i, item := range items {
+		name := item.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		for _, severity := range item.Severities {
+			switch strings.ToLower(severity) {
+			case model.SeverityCritical, model.SeverityWarning, model.SeverityInfo:
+			default:
+				return nil, fmt.Errorf("route [%s]: unknown severity %s", name, severity)
+			}
+		}
+		receivers, err := ns.Select(item.Receivers)
+		if err != nil {
+			return nil, fmt.Errorf("route [%s]: %s", name, err)
+		}
+		if len(item.Receivers) == 0 {
+			receivers = nil
+		}
+		children, err := newRoutes(item.Routes, ns)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, &route{Route: item, labels: model.SelectorFromMap(item.Labels), receivers: receivers, children: children})
+	}
+	return routes, nil
+}
+
+// Route returns the receivers of the alert without duplicates
+func (r *Router) Route(alert *model.Alert) Notifiers {
+	receivers, matched := match(r.routes, alert)
+	if !matched {
+		return r.fallback
+	}
+	var result Notifiers
+	seen := make(map[string]bool)
+	for _, n := range receivers {
+		if !seen[n.Name()] {
+			seen[n.Name()] = true
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// match walks the routes in order and collects the receivers, matched is false when no route matches
+func match(routes []*route, alert *model.Alert) (receivers Notifiers, matched bool) {
+	for _, r := range routes {
+		if !r.matches(alert) {
+			continue
+		}
+		matched = true
+		if childReceivers, ok := match(r.children, alert); ok {
+			receivers = append(receivers, childReceivers...)
+		} else {
+			receivers = append(receivers, r.receivers...)
+		}
+		if !r.Continue {
+			break
+		}
+	}
+	return receivers, matched
+}
+
+func (r *route) matches(alert *model.Alert) bool {
+	if len(r.Nodes) > 0 && !matchAny(r.Nodes, alert.NodeID) {
+		return false
+	}
+	for _, tag := range r.Tags {
+		if !contains(alert.NodeTags, tag) {
+			return false
+		}
+	}
+	if !r.labels.Matches(alert.NodeLabels) {
+		return false
+	}
+	if len(r.Severities) > 0 && !contains(r.Severities, alert.Severity) {
+		return false
+	}
+	if len(r.Kinds) > 0 && !contains(r.Kinds, alert.Name) {
+		return false
+	}
+	return true
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package notifier
+
+import (
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	var ns Notifiers
+	for _, name := range []string{"ops", "storage", "chain", "pager"} {
+		ns = append(ns, NewEmail(config.Notifier{Name: name}))
+	}
+	router, err := NewRouter([]config.Route{
+		{Name: "pager", Severities: []string{"critical"}, Receivers: []string{"pager"}, Continue: true},
+		{Name: "storage", Tags: []string{"storage"}, Receivers: []string{"storage"}},
+		{Name: "eu", Labels: map[string]string{"region": "eu-*"}, Receivers: []string{"ops"}},
+		{Name: "geth", Nodes: []string{"geth-*"}, Receivers: []string{"chain"}, Routes: []config.Route{
+			{Kinds: []string{"node-error"}, Receivers: []string{"ops"}},
+		}},
+	}, ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		alert     model.Alert
+		receivers string
+	}{
+		{model.Alert{NodeID: "geth-01", Name: "proc-down", Severity: "critical"}, "pager,chain"},
+		{model.Alert{NodeID: "geth-01", Name: "node-error", Severity: "warning"}, "ops"},
+		{model.Alert{NodeID: "geth-01", NodeTags: []string{"storage"}, Name: "proc-down", Severity: "warning"}, "storage"},
+		{model.Alert{NodeID: "bsc-01", NodeLabels: map[string]string{"region": "eu-west"}, Name: "proc-down", Severity: "warning"}, "ops"},
+		{model.Alert{NodeID: "bsc-01", Name: "proc-down", Severity: "warning"}, "ops,storage,chain,pager"},
+	}
+	for _, c := range cases {
+		var names []string
+		for _, n := range router.Route(&c.alert) {
+			names = append(names, n.Name())
+		}
+		if strings.Join(names, ",") != c.receivers {
+			t.Errorf("%+v: expected %s, got %s", c.alert, c.receivers, strings.Join(names, ","))
+		}
+	}
+
+	if _, err = NewRouter([]config.Route{{Receivers: []string{"unknown"}}}, ns); err == nil {
+		t.Error("expected an error for an unknown receiver")
+	}
+}
@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"ethstats/server/config"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	slackHeaderMaxLength  = 150
+	slackSectionMaxLength = 3000
+	slackMaxBlocks        = 50
+)
+
+// slack incoming webhooks allow about one message per second
+var slackLimiters = newLimiters(limit{count: 1, period: time.Second})
+
+// Slack sends block kit messages to a slack incoming webhook,
+// Url is the webhook, e.g. https://hooks.slack.com/services/xxx
+type Slack struct {
+	base
+	url     string
+	retry   int
+	client  *http.Client
+	limiter *rateLimiter
+}
+
+// NewSlack creates a slack incoming webhook notifier
+func NewSlack(item config.Notifier) (*Slack, error) {
+	if item.Url == "" {
+		return nil, errors.New("url can't empty")
+	}
+	return &Slack{
+		base:    newBase(item),
+		url:     item.Url,
+		retry:   item.Retry,
+		client:  newHttpClient(item.Timeout),
+		limiter: slackLimiters.get(item.Url),
+	}, nil
+}
+
+func (s *Slack) Notify(msg *Message) error {
+	subject := strings.TrimSpace(msg.Subject)
+	if utf8.RuneCountInString(subject) > slackHeaderMaxLength {
+		subject = string([]rune(subject)[:slackHeaderMaxLength-3]) + "..."
+	}
+	var blocks []interface{}
+	for _, part := range split(strings.TrimSpace(msg.Content), slackSectionMaxLength-8) {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": "```" + part + "```"},
+		})
+	}
+
+	// one message holds the header and at most 49 sections, the rest goes into more messages
+	for i := 0; i == 0 || i < len(blocks); i += slackMaxBlocks - 1 {
+		end := i + slackMaxBlocks - 1
+		if end > len(blocks) {
+			end = len(blocks)
+		}
+		payload := map[string]interface{}{
+			"text": subject,
+			"blocks": append([]interface{}{map[string]interface{}{
+				"type": "header",
+				"text": map[string]string{"type": "plain_text", "text": subject},
+			}}, blocks[i:end]...),
+		}
+		err := retry(s.retry, func() error {
+			s.limiter.Wait()
+			body, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+			_, err = postJSON(s.client, s.url, body, nil)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"ethstats/server/config"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTelegramBaseUrl = "https://api.telegram.org"
+	telegramMaxLength      = 4096
+)
+
+// telegram allows about 20 messages per minute in the same group
+var telegramLimiters = newLimiters(limit{count: 20, period: time.Minute}, limit{count: 1, period: time.Second})
+
+// Telegram sends plain text messages with the sendMessage method of the telegram bot api,
+// long messages are split into several messages
+type Telegram struct {
+	base
+	url     string
+	chatID  string
+	retry   int
+	client  *http.Client
+	limiter *rateLimiter
+}
+
+// NewTelegram creates a telegram bot notifier, BaseUrl can point to a local stand-in of the bot api
+func NewTelegram(item config.Notifier) (*Telegram, error) {
+	if item.Token == "" || item.ChatID == "" {
+		return nil, errors.New("token and chatID can't empty")
+	}
+	baseUrl := item.BaseUrl
+	if baseUrl == "" {
+		baseUrl = defaultTelegramBaseUrl
+	}
+	return &Telegram{
+		base:    newBase(item),
+		url:     strings.TrimRight(baseUrl, "/") + "/bot" + item.Token + "/sendMessage",
+		chatID:  item.ChatID,
+		retry:   item.Retry,
+		client:  newHttpClient(item.Timeout),
+		limiter: telegramLimiters.get(item.ChatID),
+	}, nil
+}
+
+func (t *Telegram) Notify(msg *Message) error {
+	text := strings.TrimSpace(msg.Subject) + "\n\n" + strings.TrimSpace(msg.Content)
+	for _, part := range split(text, telegramMaxLength) {
+		payload := map[string]interface{}{
+			"chat_id":                  t.chatID,
+			"text":                     part,
+			"disable_web_page_preview": true,
+		}
+		err := retry(t.retry, func() error {
+			t.limiter.Wait()
+			return postTelegram(t.client, t.url, payload)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postTelegram posts to the bot api, which answers {"ok":false,"description":"..."} on errors
+func postTelegram(client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	content, err := postJSON(client, url, body, nil)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err = json.Unmarshal(content, &resp); err == nil && !resp.Ok {
+		return errors.New("telegram error: " + resp.Description)
+	}
+	return nil
+}
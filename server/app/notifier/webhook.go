@@ -0,0 +1,131 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"ethstats/server/config"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTimeout = 10 //second
+
+	HeaderSignature = "X-Osmonitor-Signature"
+	HeaderTimestamp = "X-Osmonitor-Timestamp"
+	HeaderEvent     = "X-Osmonitor-Event"
+)
+
+// Webhook posts the message as json to any http endpoint, e.g.
+//
+//	{"kind":"alert","server":"test","subject":"...","content":"...","time":"2024-06-01T09:00:00+08:00",
+//	 "alert":{"name":"proc-down","nodeId":"geth-01","addr":"1.2.3.4:5678","tag":"proc report","content":"...","time":"..."}}
+//
+// When a secret is configured, the X-Osmonitor-Signature header is "sha256=" followed by
+// the hex hmac-sha256 of "<X-Osmonitor-Timestamp>.<body>"
+type Webhook struct {
+	base
+	url     string
+	secret  string
+	headers map[string]string
+	retry   int
+	client  *http.Client
+}
+
+// NewWebhook creates a webhook notifier
+func NewWebhook(item config.Notifier) (*Webhook, error) {
+	if item.Url == "" {
+		return nil, errors.New("url can't empty")
+	}
+	return &Webhook{
+		base:    newBase(item),
+		url:     item.Url,
+		secret:  item.Secret,
+		headers: item.Headers,
+		retry:   item.Retry,
+		client:  newHttpClient(item.Timeout),
+	}, nil
+}
+
+func (w *Webhook) Notify(msg *Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return retry(w.retry, func() error {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers := map[string]string{
+			HeaderEvent:     msg.Kind,
+			HeaderTimestamp: timestamp,
+		}
+		if w.secret != "" {
+			headers[HeaderSignature] = "sha256=" + Sign(w.secret, timestamp, body)
+		}
+		for k, v := range w.headers {
+			headers[k] = v
+		}
+		_, err := postJSON(w.client, w.url, body, headers)
+		return err
+	})
+}
+
+// Sign returns the hex hmac-sha256 of "<timestamp>.<body>", receivers use it to verify the webhook
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newHttpClient(timeout int) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &http.Client{Timeout: time.Duration(timeout) * time.Second}
+}
+
+// postJSON posts the body and returns the response body, any status other than 2xx is an error
+func postJSON(client *http.Client, endpoint string, body []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// the urls of the bots and the robots carry their tokens, the error only keeps the host
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, fmt.Errorf("post to %s error: %w", req.URL.Host, urlErr.Err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	content, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return content, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(content))
+	}
+	return content, nil
+}
+
+// retry runs fn once and up to times more while it fails, waiting 1s, 2s, 4s... between attempts
+func retry(times int, fn func() error) error {
+	err := fn()
+	for i := 0; i < times && err != nil; i++ {
+		time.Sleep(time.Duration(1<<i) * time.Second)
+		err = fn()
+	}
+	return err
+}
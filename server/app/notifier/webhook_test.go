@@ -0,0 +1,41 @@
+package notifier
+
+import (
+	"ethstats/server/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookNotify(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		expected := "sha256=" + Sign("123456", r.Header.Get(HeaderTimestamp), body)
+		if r.Header.Get(HeaderSignature) != expected {
+			t.Errorf("invalid signature: %s", r.Header.Get(HeaderSignature))
+		}
+		if r.Header.Get("X-Team") != "ops" {
+			t.Errorf("custom header is missing")
+		}
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhook(config.Notifier{Name: "test", Url: server.URL, Secret: "123456", Retry: 1,
+		Headers: map[string]string{"X-Team": "ops"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = webhook.Notify(&Message{Kind: KindAlert, Subject: "test", Content: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
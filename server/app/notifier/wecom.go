@@ -0,0 +1,50 @@
+package notifier
+
+import (
+	"errors"
+	"ethstats/server/config"
+	"net/http"
+	"time"
+)
+
+// wecom markdown content is limited to 4096 bytes
+const weComMaxLength = 4096
+
+// wecom group robots accept 20 messages per minute
+var weComLimiters = newLimiters(limit{count: 20, period: time.Minute})
+
+// WeCom sends markdown messages to a wecom group robot.
+// Url is the webhook of the robot, e.g. https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=xxx
+type WeCom struct {
+	base
+	url     string
+	retry   int
+	client  *http.Client
+	limiter *rateLimiter
+}
+
+// NewWeCom creates a wecom group robot notifier
+func NewWeCom(item config.Notifier) (*WeCom, error) {
+	if item.Url == "" {
+		return nil, errors.New("url can't empty")
+	}
+	return &WeCom{
+		base:    newBase(item),
+		url:     item.Url,
+		retry:   item.Retry,
+		client:  newHttpClient(item.Timeout),
+		limiter: weComLimiters.get(item.Url),
+	}, nil
+}
+
+func (w *WeCom) Notify(msg *Message) error {
+	content := markdown(msg, true, "\n")
+	payload := map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": truncate(content, weComMaxLength)},
+	}
+	return retry(w.retry, func() error {
+		w.limiter.Wait()
+		return postRobot(w.client, w.url, payload)
+	})
+}
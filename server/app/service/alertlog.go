@@ -0,0 +1,59 @@
+package service
+
+import (
+	"ethstats/server/app/model"
+	"sync"
+	"time"
+)
+
+const defaultAlertLogSize = 1000
+
+// AlertFilter selects alert records, empty fields match all records
+type AlertFilter struct {
+	Node     string
+	Name     string
+	Severity string
+	Since    time.Time
+	Until    time.Time
+}
+
+// AlertLog keeps the latest sent alerts in memory, the oldest records are dropped when it is full
+type AlertLog struct {
+	lock    sync.RWMutex
+	size    int
+	records []model.AlertRecord
+}
+
+// NewAlertLog creates an alert log with the default size
+func NewAlertLog() *AlertLog {
+	return &AlertLog{size: defaultAlertLogSize}
+}
+
+// Add appends the record
+func (l *AlertLog) Add(record model.AlertRecord) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.records = append(l.records, record)
+	if len(l.records) > l.size {
+		l.records = l.records[len(l.records)-l.size:]
+	}
+}
+
+// List returns the records matching the filter, the newest first
+func (l *AlertLog) List(filter AlertFilter) []model.AlertRecord {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	result := make([]model.AlertRecord, 0)
+	for i := len(l.records) - 1; i >= 0; i-- {
+		r := l.records[i]
+		if filter.Node != "" && filter.Node != r.NodeID ||
+			filter.Name != "" && filter.Name != r.Name ||
+			filter.Severity != "" && filter.Severity != r.Severity ||
+			!filter.Since.IsZero() && r.Time.Before(filter.Since) ||
+			!filter.Until.IsZero() && !r.Time.Before(filter.Until) {
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
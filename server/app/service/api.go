@@ -2,14 +2,15 @@ package service
 
 import (
 	"ethstats/common/util/connutil"
-	"ethstats/common/util/emailutil"
+	"ethstats/common/util/dateutil"
 	"ethstats/server/app/model"
+	"ethstats/server/app/notifier"
 	"ethstats/server/config"
 	"fmt"
 	"github.com/bitxx/logger/logbase"
 	"github.com/gorilla/websocket"
 	"net/http"
-	"time"
+	"sync/atomic"
 )
 
 // Api is the responsible to send node state to registered hub
@@ -18,15 +19,34 @@ type Api struct {
 	hub    *hub
 }
 
-// NewApi creates a new Api struct with the required service
-func NewApi(channel *model.Channel, logger *logbase.Helper) *Api {
+// NewApi creates a new Api struct with the required service, the changes of the registry and the events
+// are published to the dashboards that subscribed their topics
+func NewApi(channel *model.Channel, registry *Registry, events *EventStore, router *notifier.Router, alerts *AlertLog,
+	logger *logbase.Helper) *Api {
 	hub := &hub{
-		register: make(chan *connutil.ConnWrapper),
-		logger:   logger,
-		close:    make(chan interface{}),
-		clients:  make(map[*connutil.ConnWrapper]bool),
-		channel:  channel,
+		register:   make(chan *dashboard),
+		unregister: make(chan *dashboard),
+		requests:   make(chan dashboardRequest),
+		publish:    make(chan *update, 1024),
+		logger:     logger,
+		close:      make(chan interface{}),
+		clients:    make(map[*dashboard]bool),
+		channel:    channel,
+		registry:   registry,
+		events:     events,
+		router:     router,
+		alerts:     alerts,
 	}
+	registry.Watch(func(topic string, node model.NodeState) {
+		var item interface{} = node
+		if topic == model.TopicMetrics {
+			item = nodeMetrics(node)
+		}
+		hub.send(&update{topic: topic, node: node.ID, labels: node.Labels, item: item})
+	})
+	events.Watch(func(event model.Event) {
+		hub.send(&update{topic: model.TopicEvents, node: event.Node, labels: hub.nodeLabels(event.Node), item: event})
+	})
 	go hub.loop()
 	return &Api{
 		logger: logger,
@@ -43,9 +63,7 @@ func (a *Api) Close() {
 // HandleRequest handle all request from hub that are not Ethereum nodes
 func (a *Api) HandleRequest(w http.ResponseWriter, r *http.Request) {
 	upgradeConn := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
+		CheckOrigin: CheckOrigin,
 	}
 	conn, err := connutil.NewUpgradeConn(upgradeConn, w, r)
 	if err != nil {
@@ -54,28 +72,41 @@ func (a *Api) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	a.logger.Infof("connected new client! (host=%s)", r.Host)
-	a.hub.register <- conn
+	d := newDashboard(conn, identityOf(r).Labels)
+	a.hub.register <- d
+	go d.writeLoop()
+	go d.readLoop(a.hub)
 }
 
 // hub maintain a list of registered clients to send messages
 type hub struct {
-	register chan *connutil.ConnWrapper
-	logger   *logbase.Helper
-	close    chan interface{}
-	clients  map[*connutil.ConnWrapper]bool
-	channel  *model.Channel
+	register   chan *dashboard
+	unregister chan *dashboard
+	requests   chan dashboardRequest
+	publish    chan *update
+	dropped    atomic.Uint64 // count of the updates dropped by send
+	logger     *logbase.Helper
+	close      chan interface{}
+	clients    map[*dashboard]bool
+	channel    *model.Channel
+	registry   *Registry
+	events     *EventStore
+	router     *notifier.Router
+	alerts     *AlertLog
 }
 
 // loop loops as the server is alive and send messages to registered clients
 func (h *hub) loop() {
-	poolInfoTicker := time.NewTicker(time.Duration(config.EmailConfig.DelayTime) * time.Second)
-	defer func() {
-		poolInfoTicker.Stop()
-	}()
 	for {
 		select {
 		case client := <-h.register:
 			h.clients[client] = true
+		case client := <-h.unregister:
+			h.remove(client)
+		case req := <-h.requests:
+			h.handleRequest(req)
+		case u := <-h.publish:
+			h.publishUpdate(u)
 		case ping := <-h.channel.MsgPing:
 			//debug log for show the ping
 			//h.logger.Info("debug log show ping = > ", string(ping))
@@ -86,52 +117,161 @@ func (h *hub) loop() {
 			//h.logger.Info("debug log show latency = > ", string(latency))
 			//use for send to any fronted client
 			h.writeMessage(latency)
-		case <-poolInfoTicker.C:
-			if len(h.channel.InfoPool) <= 0 {
+		case alert := <-h.channel.Alerts:
+			go h.sendAlert(alert)
+		case <-h.close:
+			h.quit()
+			return
+		}
+	}
+}
+
+// sendAlert sends the alert to the receivers of its route and records it in the alert log, the errors are only logged
+func (h *hub) sendAlert(alert *model.Alert) {
+	receivers := h.router.Route(alert)
+	msg := &notifier.Message{
+		Kind:    notifier.KindAlert,
+		Subject: fmt.Sprintf("[%s] %s %s", alert.Severity, alert.NodeID, alert.Name),
+		Content: fmt.Sprintf("%s\nnode: [%s-%s]\n%s\n", dateutil.ConvertToStr(alert.Time, -1), alert.NodeID, alert.Addr, alert.Content),
+		Alert:   alert,
+	}
+	record := model.AlertRecord{Alert: alert, Receivers: make([]string, 0, len(receivers))}
+	for _, n := range receivers {
+		if n.Accept(msg.Kind) {
+			record.Receivers = append(record.Receivers, n.Name())
+		}
+	}
+	if err := receivers.Send(msg); err != nil {
+		record.Error = err.Error()
+		h.logger.Errorf("send %s error: %s, message info: \n%s", msg.Kind, err, msg.Content)
+	}
+	h.alerts.Add(record)
+	h.send(&update{topic: model.TopicAlerts, node: alert.NodeID, labels: alert.NodeLabels, item: record})
+}
+
+// send queues the update for the dashboards. The watchers run in the goroutines of the nodes, so the update is dropped
+// instead of blocking them when the hub is busy or closed
+func (h *hub) send(u *update) {
+	select {
+	case h.publish <- u:
+	default:
+		if dropped := h.dropped.Add(1); dropped%1000 == 1 {
+			h.logger.Warnf("publish channel is full, drop the %s update of node %s, %d updates dropped", u.topic, u.node, dropped)
+		}
+	}
+}
+
+// handleRequest changes the subscriptions of the dashboard, a snapshot of every subscribed topic is sent before the deltas
+func (h *hub) handleRequest(req dashboardRequest) {
+	d := req.dashboard
+	if !h.clients[d] {
+		return
+	}
+	if req.action == messageUnsubscribe {
+		if len(req.sub.Topics) == 0 {
+			d.subs = make(map[string]*subscription)
+		}
+		for _, topic := range req.sub.Topics {
+			delete(d.subs, topic)
+		}
+		return
+	}
+	if err := d.subscribe(req.sub); err != nil {
+		if !d.enqueue(messageError, err.Error()) {
+			h.remove(d)
+		}
+		return
+	}
+	for _, topic := range req.sub.Topics {
+		if !d.enqueue(messageSnapshot, model.Update{Topic: topic, Items: h.snapshot(topic, d.subs[topic])}) {
+			h.remove(d)
+			return
+		}
+	}
+}
+
+// snapshot returns the current items of the topic matching the subscription
+func (h *hub) snapshot(topic string, sub *subscription) interface{} {
+	switch topic {
+	case model.TopicNodes, model.TopicMetrics:
+		nodes := make([]model.NodeState, 0)
+		metrics := make([]model.NodeMetrics, 0)
+		for _, node := range h.registry.List() {
+			if sub.matches(node.ID, node.Labels) {
+				nodes = append(nodes, node)
+				metrics = append(metrics, nodeMetrics(node))
+			}
+		}
+		if topic == model.TopicMetrics {
+			return metrics
+		}
+		return nodes
+	case model.TopicEvents:
+		result := make([]model.Event, 0)
+		for _, e := range h.events.List(EventFilter{State: model.EventFiring}) {
+			if sub.matches(e.Node, h.nodeLabels(e.Node)) {
+				result = append(result, e)
+			}
+		}
+		return result
+	default:
+		result := make([]model.AlertRecord, 0)
+		for _, r := range h.alerts.List(AlertFilter{}) {
+			if len(result) >= alertSnapshotSize {
 				break
 			}
-			msg := ""
-			for tag, infos := range h.channel.InfoPool {
-				msg += tag + ":\n"
-				for info, latestTime := range infos {
-					msg += latestTime + " => " + info + "\n"
-				}
-				msg += "\n"
+			if sub.matches(r.NodeID, r.NodeLabels) {
+				result = append(result, r)
 			}
+		}
+		return result
+	}
+}
 
-			h.channel.InfoPool = make(map[string]map[string]string) //clean cache
-
-			err := emailutil.SendEmailDefault(fmt.Sprintf("%s-monitor report\n", time.Now().Format("2006-01-02 15:04:05")), msg)
-			if err != nil {
-				h.logger.Errorf("send email error: %s, email info: \n%s", err, msg)
-			}
-		case <-h.close:
-			h.quit()
-			break
+// publishUpdate sends the delta to the dashboards that subscribed it
+func (h *hub) publishUpdate(u *update) {
+	for client := range h.clients {
+		if client.matches(u) && !client.enqueue(messageDelta, model.Update{Topic: u.topic, Item: u.item}) {
+			h.remove(client)
 		}
 	}
 }
 
-// writeMessage to all registered clients. If an error occurs sending a message to a client,
+// writeMessage to all registered clients without subscriptions and label scope. If a client can't keep up with the messages,
 // then these connection is closed and removed from the pool of registered clients
 func (h *hub) writeMessage(msg []byte) {
 	for client := range h.clients {
-		err := client.WriteMessage(1, msg)
-		if err != nil {
-			h.logger.Infof("Closed connection with client: %s", client.RemoteAddr())
-			// close and delete the client connection and release
-			client.Close()
-			delete(h.clients, client)
+		if len(client.subs) == 0 && len(client.scope) == 0 && !client.enqueueRaw(msg) {
+			h.remove(client)
 		}
 	}
 }
 
+// remove closes the send channel of the client, its write loop closes the connection
+func (h *hub) remove(client *dashboard) {
+	if h.clients[client] {
+		h.logger.Infof("Closed connection with client: %s", client.conn.RemoteAddr())
+		delete(h.clients, client)
+		close(client.send)
+	}
+}
+
+// nodeLabels returns the labels of the node, the nodes that never logged in have the labels of the config
+func (h *hub) nodeLabels(id string) map[string]string {
+	if node, ok := h.registry.Get(id); ok {
+		return node.Labels
+	}
+	return config.NodeLabels(id, nil)
+}
+
 func (h *hub) quit() {
 	h.logger.Info("Closing all registered clients")
 	for client := range h.clients {
-		client.Close()
-		delete(h.clients, client)
+		h.remove(client)
 	}
-	close(h.register)
-	close(h.close)
+}
+
+func nodeMetrics(node model.NodeState) model.NodeMetrics {
+	return model.NodeMetrics{ID: node.ID, Latency: node.Latency, Stats: node.Stats, StatsTime: node.StatsTime,
+		Custom: node.Custom}
 }
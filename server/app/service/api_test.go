@@ -0,0 +1,67 @@
+package service
+
+import (
+	"ethstats/server/app/model"
+	"ethstats/server/app/notifier"
+	"ethstats/server/config"
+	"fmt"
+	"github.com/bitxx/logger"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApiUpdatesDontBlockAfterClose(t *testing.T) {
+	config.EventConfig.Path = filepath.Join(t.TempDir(), "events.json")
+	log := logger.NewLogger()
+	events, err := NewEventStore(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	channel := &model.Channel{Alerts: make(chan *model.Alert, 64), LoginIDs: make(map[string]string)}
+	api := NewApi(channel, registry, events, nil, nil, log)
+	api.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2*cap(api.hub.publish); i++ {
+			registry.Login(fmt.Sprintf("geth-%d", i), "127.0.0.1:1", nil, nil)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the registry is blocked by the closed hub")
+	}
+	if api.hub.dropped.Load() == 0 {
+		t.Error("expected the updates to be dropped")
+	}
+}
+
+func TestSendAlertHidesNotifierUrl(t *testing.T) {
+	// a closed server, so the delivery fails with the error of the connection
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	ns, err := notifier.New([]config.Notifier{{Type: notifier.TypeTelegram, BaseUrl: server.URL, Token: "123:secret-bot-token",
+		ChatID: "-100", Timeout: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router, _ := notifier.NewRouter(nil, ns)
+	alerts := NewAlertLog()
+	h := &hub{publish: make(chan *update, 1), logger: logger.NewLogger(), router: router, alerts: alerts}
+	h.sendAlert(&model.Alert{Name: AlertNodeError, NodeID: "geth-01", Severity: model.SeverityWarning, Time: time.Now()})
+
+	records := alerts.List(AlertFilter{})
+	if len(records) != 1 || records[0].Error == "" {
+		t.Fatalf("expected 1 failed alert record, got %+v", records)
+	}
+	if strings.Contains(records[0].Error, "secret-bot-token") || strings.Contains(records[0].Error, "/bot") {
+		t.Errorf("expected the error without the bot url, got %s", records[0].Error)
+	}
+}
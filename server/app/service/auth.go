@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"github.com/bitxx/logger/logbase"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookie         = "osm_session"
+	defaultSessionTimeout = 12 * time.Hour
+
+	AuthRequired = "required" // every api request needs a token or a user session, the default
+	AuthDisabled = "disabled" // the api is open to everyone who can reach the server
+)
+
+type identityKey struct{}
+
+// identity is the authenticated caller of a request
+type identity struct {
+	Name   string              `json:"name"`
+	Kind   string              `json:"kind"` // token, user or anonymous
+	Role   string              `json:"role,omitempty"`
+	Scope  string              `json:"scope"`
+	Labels model.LabelSelector `json:"-"` // the caller only sees the nodes matching the selector
+}
+
+// anonymous is the caller when neither a token nor a user is created
+var anonymous = &identity{Name: "anonymous", Kind: "anonymous", Scope: model.ScopeAdmin}
+
+// identityOf returns the caller saved by Auth.Wrap
+func identityOf(req *http.Request) *identity {
+	if id, ok := req.Context().Value(identityKey{}).(*identity); ok {
+		return id
+	}
+	return anonymous
+}
+
+// session is a login of a dashboard user
+type session struct {
+	username string
+	expires  time.Time
+}
+
+// Auth checks the api tokens, the user sessions and the origins of the api requests.
+// The authentication is required unless api.auth is disabled, without a token or a user every request is rejected
+type Auth struct {
+	logger   *logbase.Helper
+	tokens   *TokenStore
+	users    *UserStore
+	stats    *Stats
+	disabled bool
+	timeout  time.Duration
+	lock     sync.Mutex
+	sessions map[string]*session
+}
+
+// NewAuth creates the auth with the token and user stores
+func NewAuth(tokens *TokenStore, users *UserStore, stats *Stats, logger *logbase.Helper) (*Auth, error) {
+	switch config.ApiConfig.Auth {
+	case "", AuthRequired, AuthDisabled:
+	default:
+		return nil, errors.New("unknown api.auth " + config.ApiConfig.Auth + ", expected required or disabled")
+	}
+	a := &Auth{
+		logger:   logger,
+		tokens:   tokens,
+		users:    users,
+		stats:    stats,
+		timeout:  defaultSessionTimeout,
+		disabled: config.ApiConfig.Auth == AuthDisabled,
+		sessions: make(map[string]*session),
+	}
+	if config.ApiConfig.SessionTimeout > 0 {
+		a.timeout = time.Duration(config.ApiConfig.SessionTimeout) * time.Second
+	}
+	switch {
+	case a.disabled:
+		logger.Warn("api.auth is disabled, the api can be used without authentication")
+	case len(tokens.List()) == 0 && len(users.List()) == 0:
+		logger.Warn("no api token or user is created, every api request is rejected, " +
+			"create one with `server token add` or `server user add`")
+	}
+	return a, nil
+}
+
+// Wrap returns a handler that requires a token or a user session with the scope,
+// the caller is saved in the request context
+func (a *Auth) Wrap(scope string, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !a.allowOrigin(w, req) {
+			return
+		}
+		if a.disabled {
+			fn(w, req)
+			return
+		}
+		id, msg := a.authenticate(req)
+		if id == nil {
+			a.stats.AuthFailure(AuthSourceApi)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, msg)
+			return
+		}
+		if !model.ScopeAllows(id.Scope, scope) {
+			writeError(w, http.StatusForbidden, id.Kind+" "+id.Name+" has no "+scope+" scope")
+			return
+		}
+		fn(w, req.WithContext(context.WithValue(req.Context(), identityKey{}, id)))
+	}
+}
+
+// authenticate returns the caller of the session cookie or the bearer token, or the reason of the failure
+func (a *Auth) authenticate(req *http.Request) (*identity, string) {
+	if cookie, err := req.Cookie(sessionCookie); err == nil {
+		if id := a.sessionIdentity(cookie.Value); id != nil {
+			return id, ""
+		}
+	}
+	secret := bearerToken(req)
+	if secret == "" {
+		return nil, "missing api token or session"
+	}
+	token, ok := a.tokens.Lookup(secret)
+	if !ok {
+		return nil, "invalid api token"
+	}
+	return &identity{Name: token.Name, Kind: "token", Scope: token.Scope}, ""
+}
+
+// sessionIdentity returns the user of the session, the current role and labels of the user are used
+func (a *Auth) sessionIdentity(id string) *identity {
+	a.lock.Lock()
+	s, ok := a.sessions[id]
+	if ok && time.Now().After(s.expires) {
+		delete(a.sessions, id)
+		ok = false
+	}
+	a.lock.Unlock()
+	if !ok {
+		return nil
+	}
+	user, ok := a.users.Get(s.username)
+	if !ok {
+		return nil
+	}
+	return userIdentity(user)
+}
+
+func userIdentity(user model.User) *identity {
+	scope, _ := model.RoleScope(user.Role)
+	selector, _ := model.ParseLabelSelector(user.Labels)
+	return &identity{Name: user.Username, Kind: "user", Role: user.Role, Scope: scope, Labels: selector}
+}
+
+// loginRequest is the body of the login api
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Login checks the password of the user and sets the session cookie
+func (a *Auth) Login(w http.ResponseWriter, req *http.Request) {
+	if !a.allowOrigin(w, req) {
+		return
+	}
+	var body loginRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	user, ok := a.users.Authenticate(body.Username, body.Password)
+	if !ok {
+		a.stats.AuthFailure(AuthSourceApi)
+		a.logger.Warnf("login of user %s from %s failed", body.Username, req.RemoteAddr)
+		writeError(w, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+	now := time.Now()
+	a.lock.Lock()
+	for k, s := range a.sessions {
+		if now.After(s.expires) {
+			delete(a.sessions, k)
+		}
+	}
+	a.sessions[id] = &session{username: user.Username, expires: now.Add(a.timeout)}
+	a.lock.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		Expires:  now.Add(a.timeout),
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	a.logger.Infof("user %s logged in from %s", user.Username, req.RemoteAddr)
+	writeJSON(w, http.StatusOK, userIdentity(user))
+}
+
+// Logout ends the session of the cookie
+func (a *Auth) Logout(w http.ResponseWriter, req *http.Request) {
+	if !a.allowOrigin(w, req) {
+		return
+	}
+	if cookie, err := req.Cookie(sessionCookie); err == nil {
+		a.lock.Lock()
+		delete(a.sessions, cookie.Value)
+		a.lock.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me returns the caller of the request
+func (a *Auth) Me(w http.ResponseWriter, req *http.Request) {
+	id := identityOf(req)
+	writeJSON(w, http.StatusOK, struct {
+		*identity
+		Labels string `json:"labels,omitempty"`
+	}{id, id.Labels.String()})
+}
+
+// Preflight answers the CORS preflight requests of the allowed origins
+func (a *Auth) Preflight(w http.ResponseWriter, req *http.Request) {
+	if !a.allowOrigin(w, req) {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	w.Header().Set("Access-Control-Max-Age", "600")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isLastAdmin reports whether removing the admin token or user, or taking its admin role, leaves no admin credential.
+// The api would be locked until an admin is created with the subcommands on the server
+func (a *Auth) isLastAdmin(tokenID, username string) bool {
+	removed := false
+	admins := 0
+	for _, t := range a.tokens.List() {
+		if t.Scope != model.ScopeAdmin {
+			continue
+		}
+		if t.ID == tokenID || t.Name == tokenID {
+			removed = true
+			continue
+		}
+		admins++
+	}
+	for _, u := range a.users.List() {
+		if scope, _ := model.RoleScope(u.Role); scope != model.ScopeAdmin {
+			continue
+		}
+		if u.Username == username {
+			removed = true
+			continue
+		}
+		admins++
+	}
+	return removed && admins == 0
+}
+
+// allowOrigin rejects the requests from other origins and adds the CORS headers for the allowed origins
+func (a *Auth) allowOrigin(w http.ResponseWriter, req *http.Request) bool {
+	if !CheckOrigin(req) {
+		a.logger.Warnf("reject the request of origin %s from %s", req.Header.Get("Origin"), req.RemoteAddr)
+		writeError(w, http.StatusForbidden, "origin not allowed")
+		return false
+	}
+	if origin := req.Header.Get("Origin"); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Add("Vary", "Origin")
+	}
+	return true
+}
+
+// CheckOrigin allows the requests without origin, e.g. the clients and curl, the requests from the same host
+// and the origins matching the allowedOrigins patterns of the config, e.g. https://*.example.com
+func CheckOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+	for _, pattern := range config.ApiConfig.AllowedOrigins {
+		if ok, _ := path.Match(pattern, origin); ok || pattern == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// bearerToken returns the token of the Authorization header,
+// the websocket requests of browsers can't set headers and pass it with ?token=
+func bearerToken(req *http.Request) string {
+	if value := req.Header.Get("Authorization"); value != "" {
+		if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return req.URL.Query().Get("token")
+	}
+	return ""
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"github.com/bitxx/logger"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAuth(t *testing.T, mode string) *Auth {
+	t.Helper()
+	saved := config.ApiConfig.Auth
+	config.ApiConfig.Auth = mode
+	t.Cleanup(func() { config.ApiConfig.Auth = saved })
+	dir := t.TempDir()
+	tokens, err := NewTokenStore(filepath.Join(dir, "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := NewUserStore(filepath.Join(dir, "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAuth(tokens, users, NewStats(), logger.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// login returns the session cookie of the user
+func login(t *testing.T, a *Auth, username, password string) *http.Cookie {
+	t.Helper()
+	w := httptest.NewRecorder()
+	body := `{"username":"` + username + `","password":"` + password + `"}`
+	a.Login(w, httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(body)))
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			return cookie
+		}
+	}
+	t.Fatalf("login of %s failed: %d %s", username, w.Code, w.Body)
+	return nil
+}
+
+func TestAuthWrap(t *testing.T) {
+	a := newTestAuth(t, "")
+	_, reader, _ := a.tokens.Add("grafana", model.ScopeRead, "")
+	_, admin, _ := a.tokens.Add("ops", model.ScopeAdmin, "")
+	if _, err := a.users.Add("alice", "password-1", model.RoleOperator, "region=eu-*"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.users.Add("bob", "password-2", model.RoleViewer, ""); err != nil {
+		t.Fatal(err)
+	}
+	alice := login(t, a, "alice", "password-1")
+	bob := login(t, a, "bob", "password-2")
+	expired := login(t, a, "bob", "password-2")
+	a.sessions[expired.Value].expires = time.Now().Add(-time.Second)
+
+	cases := []struct {
+		name   string
+		scope  string
+		header string
+		query  string
+		cookie *http.Cookie
+		status int
+		caller string
+		labels string
+	}{
+		{name: "no credential", scope: model.ScopeRead, status: http.StatusUnauthorized},
+		{name: "invalid token", scope: model.ScopeRead, header: "Bearer osm_invalid", status: http.StatusUnauthorized},
+		{name: "basic scheme", scope: model.ScopeRead, header: "Basic " + reader, status: http.StatusUnauthorized},
+		{name: "read token", scope: model.ScopeRead, header: "Bearer " + reader, status: http.StatusOK, caller: "grafana"},
+		{name: "read token on operate", scope: model.ScopeOperate, header: "Bearer " + reader, status: http.StatusForbidden},
+		{name: "admin token on operate", scope: model.ScopeOperate, header: "bearer " + admin, status: http.StatusOK, caller: "ops"},
+		{name: "token in query", scope: model.ScopeRead, query: "?token=" + reader, status: http.StatusUnauthorized},
+		{name: "scoped user", scope: model.ScopeOperate, cookie: alice, status: http.StatusOK, caller: "alice", labels: "region=eu-*"},
+		{name: "scoped user on admin", scope: model.ScopeAdmin, cookie: alice, status: http.StatusForbidden},
+		{name: "viewer on operate", scope: model.ScopeOperate, cookie: bob, status: http.StatusForbidden},
+		{name: "expired session", scope: model.ScopeRead, cookie: expired, status: http.StatusUnauthorized},
+		{name: "unknown session", scope: model.ScopeRead, cookie: &http.Cookie{Name: sessionCookie, Value: "abc"},
+			status: http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		var caller *identity
+		handler := a.Wrap(c.scope, func(w http.ResponseWriter, req *http.Request) {
+			caller = identityOf(req)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/api/nodes"+c.query, nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		if c.cookie != nil {
+			req.AddCookie(c.cookie)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.status, w.Code)
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		if caller == nil || caller.Name != c.caller || caller.Labels.String() != c.labels {
+			t.Errorf("%s: expected caller %s with labels %q, got %+v", c.name, c.caller, c.labels, caller)
+		}
+	}
+	if _, ok := a.sessions[expired.Value]; ok {
+		t.Error("expected the expired session to be removed")
+	}
+
+	// the session uses the current role and labels of the user
+	role, labels := model.RoleViewer, "region=us-*"
+	if err := a.users.Update("alice", UserUpdate{Role: &role, Labels: &labels}); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/nodes", nil)
+	req.AddCookie(alice)
+	w := httptest.NewRecorder()
+	var caller *identity
+	a.Wrap(model.ScopeRead, func(w http.ResponseWriter, req *http.Request) { caller = identityOf(req) })(w, req)
+	if w.Code != http.StatusOK || caller.Role != model.RoleViewer || caller.Labels.String() != labels {
+		t.Errorf("expected the changed role and labels of alice, got %d %+v", w.Code, caller)
+	}
+	if a.stats.AuthFailures()[AuthSourceApi] == 0 {
+		t.Error("expected the api auth failures to be counted")
+	}
+}
+
+func TestAuthWrapWebsocketToken(t *testing.T) {
+	a := newTestAuth(t, "")
+	_, reader, _ := a.tokens.Add("dashboard", model.ScopeRead, "")
+	req := httptest.NewRequest(http.MethodGet, "/api/ws?token="+reader, nil)
+	req.Header.Set("Upgrade", "websocket")
+	w := httptest.NewRecorder()
+	a.Wrap(model.ScopeRead, func(w http.ResponseWriter, req *http.Request) {})(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the token of the websocket query to be accepted, got %d", w.Code)
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	a := newTestAuth(t, AuthDisabled)
+	w := httptest.NewRecorder()
+	called := false
+	a.Wrap(model.ScopeAdmin, func(w http.ResponseWriter, req *http.Request) { called = true })(w,
+		httptest.NewRequest(http.MethodDelete, "/api/tokens/x", nil))
+	if !called || w.Code != http.StatusOK {
+		t.Errorf("expected the request to pass without auth, got %d", w.Code)
+	}
+
+	config.ApiConfig.Auth = "off"
+	if _, err := NewAuth(a.tokens, a.users, NewStats(), logger.NewLogger()); err == nil {
+		t.Error("expected an error for an unknown api.auth")
+	}
+}
+
+func TestAuthLastAdmin(t *testing.T) {
+	a := newTestAuth(t, "")
+	token, _, _ := a.tokens.Add("ops", model.ScopeAdmin, "")
+	if !a.isLastAdmin(token.ID, "") || !a.isLastAdmin("ops", "") {
+		t.Error("expected ops to be the last admin")
+	}
+	if _, err := a.users.Add("root", "password-1", model.RoleAdmin, ""); err != nil {
+		t.Fatal(err)
+	}
+	if a.isLastAdmin(token.ID, "") || a.isLastAdmin("", "root") {
+		t.Error("expected two admins")
+	}
+	if a.isLastAdmin("", "nobody") {
+		t.Error("expected an unknown user not to be the last admin")
+	}
+}
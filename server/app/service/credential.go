@@ -0,0 +1,268 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"ethstats/common/util/authutil"
+	"ethstats/server/app/model"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCredentialPath = "files/credentials.json"
+	DefaultJoinTokenTTL   = 24 * time.Hour
+
+	joinTokenPrefix = "osmj_"
+)
+
+var ErrInvalidJoinToken = errors.New("invalid join token")
+
+// credentialFile is the content of the credential file
+type credentialFile struct {
+	Credentials []*model.Credential `json:"credentials"`
+	JoinTokens  []*model.JoinToken  `json:"joinTokens"`
+}
+
+// CredentialStore keeps the node credentials and the join tokens in a json file shared with the credential subcommand
+type CredentialStore struct {
+	file jsonFile
+	lock sync.Mutex
+	data credentialFile
+}
+
+// NewCredentialStore creates a store backed by the given file, the file is created on first write
+func NewCredentialStore(path string) (*CredentialStore, error) {
+	if path == "" {
+		path = DefaultCredentialPath
+	}
+	s := &CredentialStore{file: jsonFile{path: path, perm: 0600}}
+	if err := s.file.Load(&s.data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// AddJoinToken creates a one-time join token for the nodes matching the pattern and returns its secret,
+// the secret can't be read again later
+func (s *CredentialStore) AddJoinToken(node string, ttl time.Duration, createdBy string) (*model.JoinToken, string, error) {
+	if _, err := path.Match(node, ""); err != nil {
+		return nil, "", errors.New("invalid node pattern " + node)
+	}
+	if ttl <= 0 {
+		ttl = DefaultJoinTokenTTL
+	}
+	secret := joinTokenPrefix + newKey()
+	now := time.Now()
+	token := &model.JoinToken{
+		ID:        NewID(),
+		Hash:      hashToken(secret),
+		Node:      node,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		CreatedBy: createdBy,
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.file.Update(&s.data, func() error {
+		s.data.JoinTokens = append(s.data.JoinTokens, token)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return token, secret, nil
+}
+
+// Enroll uses the join token and creates the credential of the node. A node with a credential can't enroll again
+// until the credential is revoked
+func (s *CredentialStore) Enroll(nodeID, secret, addr string) (*model.Credential, error) {
+	if nodeID == "" {
+		return nil, errors.New("the node id can't be empty")
+	}
+	hash := hashToken(secret)
+	var result model.Credential
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.file.Update(&s.data, func() error {
+		var token *model.JoinToken
+		for _, t := range s.data.JoinTokens {
+			if authutil.Equal(hash, t.Hash) {
+				token = t
+			}
+		}
+		now := time.Now()
+		if token == nil || token.UsedAt != nil || now.After(token.ExpiresAt) {
+			return ErrInvalidJoinToken
+		}
+		if ok, _ := path.Match(token.Node, nodeID); token.Node != "" && !ok {
+			return errors.New("the join token isn't allowed for node " + nodeID)
+		}
+		cred := s.find(nodeID)
+		if cred != nil && cred.RevokedAt == nil {
+			return errors.New("node " + nodeID + " is enrolled, revoke its credential before enrolling again")
+		}
+		if cred == nil {
+			cred = &model.Credential{NodeID: nodeID}
+			s.data.Credentials = append(s.data.Credentials, cred)
+		}
+		*cred = model.Credential{
+			NodeID:     nodeID,
+			Key:        newKey(),
+			CreatedAt:  now,
+			JoinToken:  token.ID,
+			EnrolledBy: addr,
+		}
+		token.UsedAt = &now
+		token.UsedBy = nodeID
+		result = *cred
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Verify checks the login signature of the node with its key, or with the pending key of a rotation. The pending key
+// replaces the key after the node used it. found is false when the node has no credential
+func (s *CredentialStore) Verify(nodeID, nonce string, timestamp int64, signature string) (found bool, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err = s.file.Load(&s.data); err != nil {
+		return true, err
+	}
+	cred := s.find(nodeID)
+	if cred == nil {
+		return false, nil
+	}
+	if cred.RevokedAt != nil {
+		return true, errors.New("the credential of the node is revoked")
+	}
+	if signature == "" {
+		return true, errors.New("the node has a credential and must sign the login with it")
+	}
+	now := time.Now()
+	err = authutil.Verify(cred.Key, nonce, nodeID, timestamp, signature, now)
+	if err == nil || cred.PendingKey == "" {
+		return true, err
+	}
+	pending := cred.PendingKey
+	if authutil.Verify(pending, nonce, nodeID, timestamp, signature, now) != nil {
+		return true, err
+	}
+	return true, s.file.Update(&s.data, func() error {
+		cred := s.find(nodeID)
+		if cred == nil || cred.RevokedAt != nil || cred.PendingKey != pending {
+			return errors.New("the credential of the node changed during the login")
+		}
+		cred.Key = cred.PendingKey
+		cred.PendingKey = ""
+		cred.RotatedAt = &now
+		return nil
+	})
+}
+
+// PendingKey returns the new key of a rotation of the node
+func (s *CredentialStore) PendingKey(nodeID string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_ = s.file.Load(&s.data)
+	cred := s.find(nodeID)
+	if cred == nil || cred.RevokedAt != nil || cred.PendingKey == "" {
+		return "", false
+	}
+	return cred.PendingKey, true
+}
+
+// Revoked returns whether the node has a revoked credential
+func (s *CredentialStore) Revoked(nodeID string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_ = s.file.Load(&s.data)
+	cred := s.find(nodeID)
+	return cred != nil && cred.RevokedAt != nil
+}
+
+// Rotate creates a new key for the node, the node gets it on the next login and the old key works until the node
+// used the new one
+func (s *CredentialStore) Rotate(nodeID string) error {
+	return s.update(nodeID, func(cred *model.Credential) error {
+		if cred.RevokedAt != nil {
+			return errors.New("the credential of node " + nodeID + " is revoked")
+		}
+		cred.PendingKey = newKey()
+		return nil
+	})
+}
+
+// Revoke disables the credential of the node, the node can't login until it enrolls again with a new join token
+func (s *CredentialStore) Revoke(nodeID string) error {
+	return s.update(nodeID, func(cred *model.Credential) error {
+		if cred.RevokedAt != nil {
+			return errors.New("the credential of node " + nodeID + " is already revoked")
+		}
+		now := time.Now()
+		cred.RevokedAt = &now
+		cred.PendingKey = ""
+		return nil
+	})
+}
+
+// List returns the credentials sorted by node id
+func (s *CredentialStore) List() []model.Credential {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_ = s.file.Load(&s.data)
+	result := make([]model.Credential, 0, len(s.data.Credentials))
+	for _, cred := range s.data.Credentials {
+		result = append(result, *cred)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NodeID < result[j].NodeID
+	})
+	return result
+}
+
+// JoinTokens returns the join tokens, the oldest first
+func (s *CredentialStore) JoinTokens() []model.JoinToken {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_ = s.file.Load(&s.data)
+	result := make([]model.JoinToken, 0, len(s.data.JoinTokens))
+	for _, token := range s.data.JoinTokens {
+		result = append(result, *token)
+	}
+	return result
+}
+
+func (s *CredentialStore) update(nodeID string, fn func(cred *model.Credential) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Update(&s.data, func() error {
+		cred := s.find(nodeID)
+		if cred == nil {
+			return errors.New("credential not found: " + nodeID)
+		}
+		return fn(cred)
+	})
+}
+
+func (s *CredentialStore) find(nodeID string) *model.Credential {
+	for _, cred := range s.data.Credentials {
+		if cred.NodeID == nodeID {
+			return cred
+		}
+	}
+	return nil
+}
+
+// newKey returns a random hex key of 32 bytes
+func newKey() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
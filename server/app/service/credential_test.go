@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"ethstats/common/util/authutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCredentialStore(t *testing.T) *CredentialStore {
+	t.Helper()
+	s, err := NewCredentialStore(filepath.Join(t.TempDir(), "credentials.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCredentialEnroll(t *testing.T) {
+	s := newTestCredentialStore(t)
+	_, open, _ := s.AddJoinToken("", 0, "admin")
+	_, geth, _ := s.AddJoinToken("geth-*", 0, "admin")
+	_, reuse, _ := s.AddJoinToken("", 0, "admin")
+	_, again, _ := s.AddJoinToken("", 0, "admin")
+	expired, expiredSecret, _ := s.AddJoinToken("", time.Hour, "admin")
+	if err := s.file.Update(&s.data, func() error {
+		for _, token := range s.data.JoinTokens {
+			if token.ID == expired.ID {
+				token.ExpiresAt = time.Now().Add(-time.Second)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		node   string
+		secret string
+		err    error // nil accepts any error when ok is false
+		ok     bool
+	}{
+		{name: "token without pattern", node: "erigon-01", secret: open, ok: true},
+		{name: "token used again", node: "erigon-02", secret: open, err: ErrInvalidJoinToken},
+		{name: "pattern mismatch", node: "erigon-03", secret: geth},
+		{name: "pattern match", node: "geth-01", secret: geth, ok: true},
+		{name: "unknown token", node: "geth-02", secret: "osmj_unknown", err: ErrInvalidJoinToken},
+		{name: "expired token", node: "geth-02", secret: expiredSecret, err: ErrInvalidJoinToken},
+		{name: "empty node", node: "", secret: reuse},
+		{name: "enrolled node", node: "geth-01", secret: reuse},
+	}
+	for _, c := range cases {
+		cred, err := s.Enroll(c.node, c.secret, "127.0.0.1:1")
+		switch {
+		case c.ok && err != nil:
+			t.Errorf("%s: expected the enrollment to succeed, got %s", c.name, err)
+		case c.ok && (cred.NodeID != c.node || len(cred.Key) != 64):
+			t.Errorf("%s: unexpected credential %+v", c.name, cred)
+		case !c.ok && err == nil:
+			t.Errorf("%s: expected the enrollment to fail", c.name)
+		case !c.ok && c.err != nil && !errors.Is(err, c.err):
+			t.Errorf("%s: expected %s, got %s", c.name, c.err, err)
+		}
+	}
+
+	// the failed enrollments don't use the token, a revoked node enrolls again with a new key
+	old := s.List()
+	if len(old) != 2 || old[1].NodeID != "geth-01" {
+		t.Fatalf("expected the credentials of erigon-01 and geth-01, got %+v", old)
+	}
+	if err := s.Revoke("geth-01"); err != nil {
+		t.Fatal(err)
+	}
+	cred, err := s.Enroll("geth-01", reuse, "127.0.0.1:2")
+	if err != nil {
+		t.Fatalf("expected the revoked node to enroll again, got %s", err)
+	}
+	if cred.RevokedAt != nil || cred.Key == old[1].Key || cred.EnrolledBy != "127.0.0.1:2" {
+		t.Errorf("expected a new credential, got %+v", cred)
+	}
+	if len(s.List()) != 2 {
+		t.Errorf("expected the credential to be replaced, got %+v", s.List())
+	}
+	if _, err = s.Enroll("geth-02", again, ""); err != nil {
+		t.Errorf("expected the unused token to work, got %s", err)
+	}
+}
+
+func TestCredentialVerifyPendingKey(t *testing.T) {
+	s := newTestCredentialStore(t)
+	_, secret, _ := s.AddJoinToken("", 0, "")
+	cred, err := s.Enroll("geth-01", secret, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Rotate("geth-01"); err != nil {
+		t.Fatal(err)
+	}
+	pending, ok := s.PendingKey("geth-01")
+	if !ok || pending == cred.Key {
+		t.Fatal("expected a new pending key")
+	}
+	nonce, now := authutil.NewNonce(), time.Now().Unix()
+
+	// the old key works until the pending key is used
+	if found, err := s.Verify("geth-01", nonce, now, authutil.Sign(cred.Key, nonce, "geth-01", now)); !found || err != nil {
+		t.Fatalf("expected the old key to be accepted, got %t %v", found, err)
+	}
+	if _, ok = s.PendingKey("geth-01"); !ok {
+		t.Fatal("expected the pending key to be kept")
+	}
+	if found, err := s.Verify("geth-01", nonce, now, authutil.Sign(pending, nonce, "geth-01", now)); !found || err != nil {
+		t.Fatalf("expected the pending key to be accepted, got %t %v", found, err)
+	}
+	if _, ok = s.PendingKey("geth-01"); ok {
+		t.Error("expected the pending key to replace the key")
+	}
+	if list := s.List(); list[0].Key != pending || list[0].RotatedAt == nil {
+		t.Errorf("expected the rotated key, got %+v", list[0])
+	}
+	if _, err = s.Verify("geth-01", nonce, now, authutil.Sign(cred.Key, nonce, "geth-01", now)); err == nil {
+		t.Error("expected the old key to be rejected after the rotation")
+	}
+	if found, _ := s.Verify("geth-02", nonce, now, ""); found {
+		t.Error("expected no credential of geth-02")
+	}
+}
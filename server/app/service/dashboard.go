@@ -0,0 +1,188 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"ethstats/common/util/connutil"
+	"ethstats/server/app/model"
+	"fmt"
+	"path"
+)
+
+const (
+	messageSubscribe   = "subscribe"
+	messageUnsubscribe = "unsubscribe"
+	messageSnapshot    = "snapshot"
+	messageDelta       = "delta"
+	messageError       = "error"
+
+	dashboardSendBuffer = 256
+	alertSnapshotSize   = 50
+)
+
+// dashboard is a client of the /api websocket. A dashboard without subscriptions receives
+// the raw node-ping and latency frames, as before
+type dashboard struct {
+	conn  *connutil.ConnWrapper
+	send  chan []byte
+	subs  map[string]*subscription // topic => filter
+	scope model.LabelSelector      // the labels of the nodes the caller can see, empty means all nodes
+}
+
+// subscription is the node filter of a subscribed topic
+type subscription struct {
+	nodes  []string
+	labels model.LabelSelector
+	scope  model.LabelSelector
+}
+
+// dashboardRequest is a parsed subscribe or unsubscribe message of a dashboard
+type dashboardRequest struct {
+	dashboard *dashboard
+	action    string
+	sub       model.Subscription
+}
+
+// update is a delta of a topic for the dashboards
+type update struct {
+	topic  string
+	node   string
+	labels map[string]string
+	item   interface{}
+}
+
+func newDashboard(conn *connutil.ConnWrapper, scope model.LabelSelector) *dashboard {
+	return &dashboard{
+		conn:  conn,
+		send:  make(chan []byte, dashboardSendBuffer),
+		subs:  make(map[string]*subscription),
+		scope: scope,
+	}
+}
+
+// readLoop passes the requests of the dashboard to the hub, the dashboard is unregistered when the connection is closed
+func (d *dashboard) readLoop(h *hub) {
+	defer func() {
+		h.unregister <- d
+	}()
+	for {
+		_, content, err := d.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		req, err := parseDashboardRequest(content)
+		if err != nil {
+			d.write(messageError, err.Error())
+			continue
+		}
+		req.dashboard = d
+		h.requests <- req
+	}
+}
+
+// writeLoop writes the queued messages until the hub closes the send channel,
+// a failed write closes the connection and so ends the read loop
+func (d *dashboard) writeLoop() {
+	defer func() {
+		_ = d.conn.Close()
+	}()
+	for msg := range d.send {
+		if err := d.conn.WriteMessage(1, msg); err != nil {
+			return
+		}
+	}
+}
+
+// write writes a message directly, only used by the read loop to report invalid requests
+func (d *dashboard) write(msgType string, value interface{}) {
+	_ = d.conn.WriteJSON(map[string][]interface{}{"emit": {msgType, value}})
+}
+
+// enqueue queues the message, it returns false when the dashboard is too slow to keep up
+func (d *dashboard) enqueue(msgType string, value interface{}) bool {
+	content, err := json.Marshal(map[string][]interface{}{"emit": {msgType, value}})
+	if err != nil {
+		return true
+	}
+	return d.enqueueRaw(content)
+}
+
+func (d *dashboard) enqueueRaw(content []byte) bool {
+	select {
+	case d.send <- content:
+		return true
+	default:
+		return false
+	}
+}
+
+// subscribe replaces the filter of the topics
+func (d *dashboard) subscribe(sub model.Subscription) error {
+	selector, err := model.ParseLabelSelector(sub.Labels)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range sub.Nodes {
+		if _, err = path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid node pattern %s: %s", pattern, err)
+		}
+	}
+	for _, topic := range sub.Topics {
+		switch topic {
+		case model.TopicNodes, model.TopicMetrics, model.TopicEvents, model.TopicAlerts:
+		default:
+			return fmt.Errorf("unknown topic %s", topic)
+		}
+	}
+	for _, topic := range sub.Topics {
+		d.subs[topic] = &subscription{nodes: sub.Nodes, labels: selector, scope: d.scope}
+	}
+	return nil
+}
+
+// matches reports whether the dashboard wants the delta
+func (d *dashboard) matches(u *update) bool {
+	sub, ok := d.subs[u.topic]
+	return ok && sub.matches(u.node, u.labels)
+}
+
+func (s *subscription) matches(node string, labels map[string]string) bool {
+	if !s.labels.Matches(labels) || !s.scope.Matches(labels) {
+		return false
+	}
+	if len(s.nodes) == 0 {
+		return true
+	}
+	for _, pattern := range s.nodes {
+		if ok, _ := path.Match(pattern, node); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDashboardRequest parses {"emit": ["subscribe", {"topics": ["nodes"], "nodes": ["geth-*"], "labels": "region=eu"}]}
+func parseDashboardRequest(content []byte) (dashboardRequest, error) {
+	var msg struct {
+		Emit []json.RawMessage `json:"emit"`
+	}
+	if err := json.Unmarshal(content, &msg); err != nil {
+		return dashboardRequest{}, fmt.Errorf("invalid message: %s", err)
+	}
+	if len(msg.Emit) == 0 {
+		return dashboardRequest{}, errors.New("invalid message: emit is empty")
+	}
+	var req dashboardRequest
+	if err := json.Unmarshal(msg.Emit[0], &req.action); err != nil {
+		return req, fmt.Errorf("invalid message type: %s", err)
+	}
+	if req.action != messageSubscribe && req.action != messageUnsubscribe {
+		return req, fmt.Errorf("unknown message type %s", req.action)
+	}
+	if len(msg.Emit) > 1 {
+		if err := json.Unmarshal(msg.Emit[1], &req.sub); err != nil {
+			return req, fmt.Errorf("invalid %s message: %s", req.action, err)
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"github.com/bitxx/logger/logbase"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultEventPath      = "files/events.json"
+	defaultEventRetention = 30 //day
+
+	eventSaveInterval = 10 * time.Second
+)
+
+// EventFilter selects events, empty fields match all events
+type EventFilter struct {
+	Node  string
+	Kind  string
+	State string
+	Since time.Time // last seen not before
+	Until time.Time // first seen before
+}
+
+// EventStore aggregates the node events by node, kind and subject and persists them in a json file
+type EventStore struct {
+	path      string
+	retention time.Duration
+	logger    *logbase.Helper
+	lock      sync.RWMutex
+	events    map[string]*model.Event
+	dirty     bool
+	watchers  []func(event model.Event)
+}
+
+// NewEventStore creates the store and loads the events of the last run
+func NewEventStore(logger *logbase.Helper) (*EventStore, error) {
+	s := &EventStore{
+		path:      config.EventConfig.Path,
+		retention: time.Duration(config.EventConfig.Retention) * 24 * time.Hour,
+		logger:    logger,
+		events:    make(map[string]*model.Event),
+	}
+	if s.path == "" {
+		s.path = DefaultEventPath
+	}
+	if s.retention <= 0 {
+		s.retention = defaultEventRetention * 24 * time.Hour
+	}
+	content, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(content) > 0 {
+		var events []*model.Event
+		if err = json.Unmarshal(content, &events); err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			s.events[e.ID] = e
+		}
+	}
+	return s, nil
+}
+
+// Start saves the changed events periodically
+func (s *EventStore) Start() {
+	go func() {
+		ticker := time.NewTicker(eventSaveInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := s.save(); err != nil {
+				s.logger.Errorf("save events error: %s", err)
+			}
+		}
+	}()
+}
+
+// Watch adds a function that receives the event after every change, it must be called before the store is used
+func (s *EventStore) Watch(fn func(event model.Event)) {
+	s.watchers = append(s.watchers, fn)
+}
+
+// notify passes the events to the watchers, it's called without the lock
+func (s *EventStore) notify(events ...model.Event) {
+	for _, e := range events {
+		for _, fn := range s.watchers {
+			fn(e)
+		}
+	}
+}
+
+// Record adds an occurrence of the event and returns a copy of it,
+// firing is true when the event was not firing before, which is the moment to alert
+func (s *EventStore) Record(node, addr, kind, tag, subject, content, silenced string) (event model.Event, firing bool) {
+	s.lock.Lock()
+	defer func() {
+		s.lock.Unlock()
+		s.notify(event)
+	}()
+	now := time.Now()
+	id := EventID(node, kind, subject)
+	e, ok := s.events[id]
+	if !ok {
+		e = &model.Event{
+			ID:        id,
+			Node:      node,
+			Kind:      kind,
+			Tag:       tag,
+			Subject:   subject,
+			FirstSeen: now,
+		}
+		s.events[id] = e
+	}
+	firing = e.State != model.EventFiring
+	if firing {
+		e.AckedBy = ""
+		e.AckedAt = nil
+	}
+	e.Addr = addr
+	e.Content = content
+	e.LastSeen = now
+	e.Count++
+	e.State = model.EventFiring
+	e.ResolvedAt = nil
+	e.Silenced = silenced
+	s.dirty = true
+	return *e, firing
+}
+
+// Resolve marks the firing event resolved, it returns false when the event isn't firing
+func (s *EventStore) Resolve(node, kind, subject string) bool {
+	s.lock.Lock()
+	e, ok := s.events[EventID(node, kind, subject)]
+	if !ok || e.State != model.EventFiring {
+		s.lock.Unlock()
+		return false
+	}
+	now := time.Now()
+	e.State = model.EventResolved
+	e.ResolvedAt = &now
+	s.dirty = true
+	event := *e
+	s.lock.Unlock()
+	s.notify(event)
+	return true
+}
+
+// ResolveKind resolves all firing events of the node with the kind
+func (s *EventStore) ResolveKind(node, kind string) {
+	s.lock.Lock()
+	now := time.Now()
+	var resolved []model.Event
+	for _, e := range s.events {
+		if e.Node == node && e.Kind == kind && e.State == model.EventFiring {
+			e.State = model.EventResolved
+			e.ResolvedAt = &now
+			s.dirty = true
+			resolved = append(resolved, *e)
+		}
+	}
+	s.lock.Unlock()
+	s.notify(resolved...)
+}
+
+// Acknowledge marks the firing event acknowledged by the user or token
+func (s *EventStore) Acknowledge(id, by string) (model.Event, error) {
+	s.lock.Lock()
+	e, ok := s.events[id]
+	if !ok {
+		s.lock.Unlock()
+		return model.Event{}, errors.New("event not found")
+	}
+	if e.State != model.EventFiring {
+		s.lock.Unlock()
+		return model.Event{}, errors.New("the event is resolved")
+	}
+	now := time.Now()
+	e.AckedBy = by
+	e.AckedAt = &now
+	s.dirty = true
+	event := *e
+	s.lock.Unlock()
+	s.notify(event)
+	return event, nil
+}
+
+// Get returns a copy of the event
+func (s *EventStore) Get(id string) (model.Event, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	e, ok := s.events[id]
+	if !ok {
+		return model.Event{}, false
+	}
+	return *e, true
+}
+
+// List returns copies of the events matching the filter, sorted by first seen
+func (s *EventStore) List(filter EventFilter) []model.Event {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	result := make([]model.Event, 0)
+	for _, e := range s.events {
+		if filter.Node != "" && filter.Node != e.Node ||
+			filter.Kind != "" && filter.Kind != e.Kind ||
+			filter.State != "" && filter.State != e.State ||
+			!filter.Since.IsZero() && e.LastSeen.Before(filter.Since) ||
+			!filter.Until.IsZero() && !e.FirstSeen.Before(filter.Until) {
+			continue
+		}
+		result = append(result, *e)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].FirstSeen.Equal(result[j].FirstSeen) {
+			return result[i].FirstSeen.Before(result[j].FirstSeen)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// save drops the expired resolved events and writes the file when something changed
+func (s *EventStore) save() error {
+	s.lock.Lock()
+	expire := time.Now().Add(-s.retention)
+	for id, e := range s.events {
+		if e.State == model.EventResolved && e.LastSeen.Before(expire) {
+			delete(s.events, id)
+			s.dirty = true
+		}
+	}
+	if !s.dirty {
+		s.lock.Unlock()
+		return nil
+	}
+	events := make([]*model.Event, 0, len(s.events))
+	for _, e := range s.events {
+		events = append(events, e)
+	}
+	content, err := json.Marshal(events)
+	s.dirty = false
+	s.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// EventID is the stable id of the node, kind and subject
+func EventID(node, kind, subject string) string {
+	sum := sha1.Sum([]byte(node + "\x00" + kind + "\x00" + subject))
+	return hex.EncodeToString(sum[:8])
+}
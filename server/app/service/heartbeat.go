@@ -0,0 +1,261 @@
+package service
+
+import (
+	"errors"
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"fmt"
+	"github.com/bitxx/logger/logbase"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AlertHeartbeatMissed = "heartbeat-missed" //event kind and alert name of a missed heartbeat, the subject is the check name
+	AlertHeartbeatFailed = "heartbeat-failed" //event kind and alert name of a heartbeat reporting a failure
+
+	TagHeartbeat = "heartbeat" //use for tag event
+
+	heartbeatCheckInterval = 5               //second
+	maxHeartbeatMessage    = 1024            //bytes of the message kept for a heartbeat
+	maxHeartbeatPeriod     = 366 * 24 * 3600 //second
+)
+
+var (
+	// ErrUnknownHeartbeat is returned for a heartbeat of a check that isn't configured
+	ErrUnknownHeartbeat = errors.New("unknown heartbeat check")
+	// ErrHeartbeatNode is returned for a heartbeat sent by another node than the node of the check
+	ErrHeartbeatNode = errors.New("the heartbeat check belongs to another node")
+)
+
+// heartbeatCheck is a check of the heartbeats config with its state
+type heartbeatCheck struct {
+	config.Heartbeat
+	state    string
+	lastPing time.Time
+	status   string
+	message  string
+	lastNode string // the node that sent the last heartbeat
+	deadline time.Time
+}
+
+// HeartbeatMonitor is a dead man's switch: every check expects a heartbeat within its period and grace time.
+// A missed heartbeat or a heartbeat reporting a failure fires an event and alerts, the next successful heartbeat resolves it.
+// After the server started, every check has a full period and grace time for its first heartbeat
+type HeartbeatMonitor struct {
+	logger   *logbase.Helper
+	channel  *model.Channel
+	registry *Registry
+	events   *EventStore
+	silences *SilenceStore
+	lock     sync.Mutex
+	checks   map[string]*heartbeatCheck
+}
+
+// NewHeartbeatMonitor validates the checks
+func NewHeartbeatMonitor(items []config.Heartbeat, channel *model.Channel, registry *Registry, events *EventStore,
+	silences *SilenceStore, logger *logbase.Helper) (*HeartbeatMonitor, error) {
+	m := &HeartbeatMonitor{
+		logger:   logger,
+		channel:  channel,
+		registry: registry,
+		events:   events,
+		silences: silences,
+		checks:   make(map[string]*heartbeatCheck),
+	}
+	now := time.Now()
+	for i, item := range items {
+		if item.Name == "" || strings.ContainsAny(item.Name, "/?#") {
+			return nil, fmt.Errorf("heartbeat #%d: the name can't be empty or contain /, ? and #", i+1)
+		}
+		if m.checks[item.Name] != nil {
+			return nil, fmt.Errorf("heartbeat [%s]: duplicate name", item.Name)
+		}
+		if item.Period <= 0 || item.Period > maxHeartbeatPeriod || item.Grace < 0 {
+			return nil, fmt.Errorf("heartbeat [%s]: the period must be positive and the grace can't be negative", item.Name)
+		}
+		item.Severity = strings.ToLower(item.Severity)
+		switch item.Severity {
+		case "":
+			item.Severity = model.SeverityCritical
+		case model.SeverityCritical, model.SeverityWarning, model.SeverityInfo:
+		default:
+			return nil, fmt.Errorf("heartbeat [%s]: unknown severity %s", item.Name, item.Severity)
+		}
+		check := &heartbeatCheck{Heartbeat: item, state: model.HeartbeatWaiting}
+		check.deadline = now.Add(check.timeout())
+		m.checks[item.Name] = check
+	}
+	return m, nil
+}
+
+// Start runs the loop that checks the deadlines
+func (m *HeartbeatMonitor) Start() {
+	if len(m.checks) == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(heartbeatCheckInterval * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.checkDeadlines()
+		}
+	}()
+}
+
+// Ping records a heartbeat of the check sent by the node, the node is empty when the heartbeat was sent to the server directly.
+// The status is success, the default, or fail. A check pinned to a node only accepts the heartbeats of that node
+func (m *HeartbeatMonitor) Ping(name, node, status, message string) error {
+	if status == "" {
+		status = model.HeartbeatSuccess
+	}
+	if status != model.HeartbeatSuccess && status != model.HeartbeatFail {
+		return errors.New("unknown status " + status + ", expected success or fail")
+	}
+	if len(message) > maxHeartbeatMessage {
+		message = message[:maxHeartbeatMessage]
+	}
+	m.lock.Lock()
+	check, ok := m.checks[name]
+	if !ok {
+		m.lock.Unlock()
+		return ErrUnknownHeartbeat
+	}
+	if check.Node != "" && node != "" && node != check.Node {
+		m.lock.Unlock()
+		return ErrHeartbeatNode
+	}
+	now := time.Now()
+	// the events of an unpinned check are recorded under the node of its last heartbeat
+	previousNode := check.node()
+	check.lastPing = now
+	check.status = status
+	check.message = message
+	if node != "" {
+		check.lastNode = node
+	}
+	check.deadline = now.Add(check.timeout())
+	previous := check.state
+	check.state = model.HeartbeatUp
+	if status == model.HeartbeatFail {
+		check.state = model.HeartbeatFailed
+	}
+	eventNode := check.node()
+	severity := check.Severity
+	m.lock.Unlock()
+
+	if previous == model.HeartbeatMissed {
+		m.logger.Infof("heartbeat %s is back", name)
+	}
+	if previousNode != eventNode {
+		m.events.Resolve(previousNode, AlertHeartbeatMissed, name)
+		m.events.Resolve(previousNode, AlertHeartbeatFailed, name)
+	}
+	m.events.Resolve(eventNode, AlertHeartbeatMissed, name)
+	if status == model.HeartbeatSuccess {
+		m.events.Resolve(eventNode, AlertHeartbeatFailed, name)
+		return nil
+	}
+	content := "heartbeat " + name + " reported a failure"
+	if message != "" {
+		content += ": " + message
+	}
+	m.record(eventNode, AlertHeartbeatFailed, name, severity, content)
+	return nil
+}
+
+// List returns the state of all checks sorted by name
+func (m *HeartbeatMonitor) List() []model.Heartbeat {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	result := make([]model.Heartbeat, 0, len(m.checks))
+	for _, check := range m.checks {
+		item := model.Heartbeat{
+			Name:        check.Name,
+			Node:        check.node(),
+			Period:      check.Period,
+			Grace:       check.Grace,
+			State:       check.state,
+			LastStatus:  check.status,
+			LastMessage: check.message,
+			Deadline:    check.deadline,
+		}
+		if !check.lastPing.IsZero() {
+			lastPing := check.lastPing
+			item.LastPing = &lastPing
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// checkDeadlines fires the missed event of the checks after their deadline
+func (m *HeartbeatMonitor) checkDeadlines() {
+	type missed struct{ name, node, severity, content string }
+	var result []missed
+	now := time.Now()
+	m.lock.Lock()
+	for _, check := range m.checks {
+		if check.state == model.HeartbeatMissed || now.Before(check.deadline) {
+			continue
+		}
+		check.state = model.HeartbeatMissed
+		content := fmt.Sprintf("heartbeat %s missed, expected every %ds with %ds grace", check.Name, check.Period, check.Grace)
+		if !check.lastPing.IsZero() {
+			content += ", last heartbeat at " + check.lastPing.Format("2006-01-02 15:04:05")
+		}
+		result = append(result, missed{check.Name, check.node(), check.Severity, content})
+	}
+	m.lock.Unlock()
+	for _, item := range result {
+		m.logger.Warn(item.content)
+		m.record(item.node, AlertHeartbeatMissed, item.name, item.severity, item.content)
+	}
+}
+
+// record records an occurrence of the event, alert when the event starts firing and isn't silenced.
+// The silences match the check name with the proc matcher
+func (m *HeartbeatMonitor) record(node, kind, name, severity, content string) {
+	target := model.SilenceTarget{NodeID: node, Tag: TagHeartbeat, Proc: name, Alert: kind}
+	silenced := ""
+	if silence := m.silences.Silenced(target, time.Now()); silence != nil {
+		silenced = silence.ID
+	}
+	state, ok := m.registry.Get(node)
+	if !ok {
+		state.Labels = config.NodeLabels(node, nil)
+	}
+	_, firing := m.events.Record(node, state.Addr, kind, TagHeartbeat, name, content, silenced)
+	if !firing || silenced != "" {
+		return
+	}
+	queueAlert(m.channel, m.logger, &model.Alert{
+		Name:       kind,
+		NodeID:     node,
+		NodeTags:   config.NodeTags(node),
+		NodeLabels: state.Labels,
+		Addr:       state.Addr,
+		Tag:        TagHeartbeat,
+		Severity:   severity,
+		Content:    content,
+		Time:       time.Now(),
+	})
+}
+
+func (c *heartbeatCheck) timeout() time.Duration {
+	return time.Duration(c.Period+c.Grace) * time.Second
+}
+
+// node returns the node of the events of the check, the check name is used when the check has no node
+func (c *heartbeatCheck) node() string {
+	if c.Node != "" {
+		return c.Node
+	}
+	if c.lastNode != "" {
+		return c.lastNode
+	}
+	return c.Name
+}
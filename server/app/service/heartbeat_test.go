@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"github.com/bitxx/logger"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestHeartbeatMonitor(t *testing.T, items ...config.Heartbeat) (*HeartbeatMonitor, *EventStore) {
+	t.Helper()
+	dir := t.TempDir()
+	config.EventConfig.Path = filepath.Join(dir, "events.json")
+	log := logger.NewLogger()
+	events, err := NewEventStore(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	silences, err := NewSilenceStore(filepath.Join(dir, "silences.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel := &model.Channel{Alerts: make(chan *model.Alert, 64), LoginIDs: make(map[string]string)}
+	m, err := NewHeartbeatMonitor(items, channel, NewRegistry(), events, silences, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, events
+}
+
+func TestHeartbeatPingOfAnotherNode(t *testing.T) {
+	m, events := newTestHeartbeatMonitor(t, config.Heartbeat{Name: "backup", Node: "db-01", Period: 60})
+	m.checks["backup"].deadline = time.Now().Add(-time.Second)
+	m.checkDeadlines()
+	missed := EventFilter{Node: "db-01", Kind: AlertHeartbeatMissed, State: model.EventFiring}
+	if len(events.List(missed)) != 1 {
+		t.Fatal("expected the missed event of db-01")
+	}
+
+	// another node can't hide the missed heartbeat of db-01
+	if err := m.Ping("backup", "web-01", "", ""); !errors.Is(err, ErrHeartbeatNode) {
+		t.Fatalf("expected ErrHeartbeatNode, got %v", err)
+	}
+	if len(events.List(missed)) != 1 || m.List()[0].State != model.HeartbeatMissed {
+		t.Fatal("the heartbeat of another node changed the check")
+	}
+
+	cases := []struct {
+		name string
+		node string
+	}{
+		{"the node of the check", "db-01"},
+		{"sent to the server directly", ""},
+	}
+	for _, c := range cases {
+		if err := m.Ping("backup", c.node, "", ""); err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		if len(events.List(missed)) != 0 || m.List()[0].State != model.HeartbeatUp {
+			t.Fatalf("%s: expected the check to be up", c.name)
+		}
+	}
+}
+
+func TestHeartbeatPingOfUnpinnedCheck(t *testing.T) {
+	m, events := newTestHeartbeatMonitor(t, config.Heartbeat{Name: "backup", Period: 60})
+	firing := func(node, kind string) int {
+		return len(events.List(EventFilter{Node: node, Kind: kind, State: model.EventFiring}))
+	}
+	if err := m.Ping("backup", "db-01", model.HeartbeatFail, "disk full"); err != nil {
+		t.Fatal(err)
+	}
+	m.checks["backup"].deadline = time.Now().Add(-time.Second)
+	m.checkDeadlines()
+	if firing("db-01", AlertHeartbeatFailed) != 1 || firing("db-01", AlertHeartbeatMissed) != 1 {
+		t.Fatal("expected the failed and missed events of db-01")
+	}
+
+	// the next heartbeat comes from another node, the events of the previous node are resolved
+	if err := m.Ping("backup", "db-02", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if firing("db-01", AlertHeartbeatFailed) != 0 || firing("db-01", AlertHeartbeatMissed) != 0 {
+		t.Error("expected the events of db-01 to be resolved")
+	}
+	if m.List()[0].Node != "db-02" {
+		t.Errorf("expected the check to follow db-02, got %s", m.List()[0].Node)
+	}
+}
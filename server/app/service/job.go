@@ -0,0 +1,272 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"fmt"
+	"github.com/bitxx/logger/logbase"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultJobPath    = "files/jobs.json"
+	defaultJobHistory = 1000
+
+	AlertJobFailed  = "job-failed"   //event kind and alert name of a failed job run, the subject is the job name
+	AlertJobTooLong = "job-too-long" //event kind and alert name of a job run longer than its max duration
+
+	TagJob = "job" //use for tag event
+
+	jobCheckInterval = 5    //second
+	maxJobOutput     = 4096 //bytes of the stdout and stderr tail kept for a run
+)
+
+// JobFilter selects job runs, empty fields match all runs
+type JobFilter struct {
+	Name  string
+	Node  string
+	State string
+}
+
+// JobStore records the job runs reported by `client wrap` in a json file. A failed run fires the job-failed event
+// and the next successful run resolves it, a run longer than the max duration of its check fires the job-too-long event
+type JobStore struct {
+	path     string
+	history  int
+	logger   *logbase.Helper
+	channel  *model.Channel
+	registry *Registry
+	events   *EventStore
+	silences *SilenceStore
+	checks   []config.Job
+	lock     sync.Mutex
+	runs     []*model.JobRun // the oldest first
+}
+
+// NewJobStore validates the checks and loads the runs of the last run of the server
+func NewJobStore(channel *model.Channel, registry *Registry, events *EventStore, silences *SilenceStore,
+	logger *logbase.Helper) (*JobStore, error) {
+	s := &JobStore{
+		path:     config.JobsConfig.Path,
+		history:  config.JobsConfig.History,
+		logger:   logger,
+		channel:  channel,
+		registry: registry,
+		events:   events,
+		silences: silences,
+	}
+	if s.path == "" {
+		s.path = DefaultJobPath
+	}
+	if s.history <= 0 {
+		s.history = defaultJobHistory
+	}
+	for i, check := range config.JobsConfig.Checks {
+		if _, err := path.Match(check.Name, ""); err != nil || check.Name == "" {
+			return nil, fmt.Errorf("job check #%d: invalid name pattern %s", i+1, check.Name)
+		}
+		if check.MaxDuration < 0 {
+			return nil, fmt.Errorf("job check [%s]: the max duration can't be negative", check.Name)
+		}
+		check.Severity = strings.ToLower(check.Severity)
+		switch check.Severity {
+		case "":
+			check.Severity = model.SeverityWarning
+		case model.SeverityCritical, model.SeverityWarning, model.SeverityInfo:
+		default:
+			return nil, fmt.Errorf("job check [%s]: unknown severity %s", check.Name, check.Severity)
+		}
+		s.checks = append(s.checks, check)
+	}
+	content, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &s.runs); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Start runs the loop that checks the duration of the running jobs
+func (s *JobStore) Start() {
+	go func() {
+		ticker := time.NewTicker(jobCheckInterval * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.checkRunning()
+		}
+	}()
+}
+
+// Report saves the run sent by the node, a finished run replaces the running report with the same id
+func (s *JobStore) Report(node string, run model.JobRun) error {
+	if run.ID == "" || run.Name == "" {
+		return errors.New("the id and the name of the job run can't be empty")
+	}
+	if run.State != model.JobRunning && run.State != model.JobSucceeded && run.State != model.JobFailed {
+		return errors.New("unknown job state " + run.State)
+	}
+	run.Node = node
+	run.Stdout = tail(run.Stdout, maxJobOutput)
+	run.Stderr = tail(run.Stderr, maxJobOutput)
+	if run.StartedAt.IsZero() || run.StartedAt.After(time.Now()) {
+		run.StartedAt = time.Now()
+	}
+
+	s.lock.Lock()
+	replaced := false
+	for i, r := range s.runs {
+		if r.ID == run.ID && r.Node == node {
+			s.runs[i] = &run
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		s.runs = append(s.runs, &run)
+		if len(s.runs) > s.history {
+			s.runs = s.runs[len(s.runs)-s.history:]
+		}
+	}
+	err := s.save()
+	s.lock.Unlock()
+	if err != nil {
+		s.logger.Errorf("save job runs error: %s", err)
+	}
+
+	if run.State == model.JobRunning {
+		s.logger.Infof("job %s of node %s started", run.Name, node)
+		return nil
+	}
+	s.logger.Infof("job %s of node %s %s in %.1fs with exit code %d", run.Name, node, run.State, run.Duration, run.ExitCode)
+	check := s.check(run.Name)
+	if check.MaxDuration > 0 && run.Duration > float64(check.MaxDuration) {
+		s.record(node, AlertJobTooLong, run.Name, check.Severity,
+			fmt.Sprintf("job %s took %.0fs, longer than %ds", run.Name, run.Duration, check.MaxDuration))
+	} else {
+		s.events.Resolve(node, AlertJobTooLong, run.Name)
+	}
+	if run.State == model.JobSucceeded {
+		s.events.Resolve(node, AlertJobFailed, run.Name)
+		return nil
+	}
+	content := fmt.Sprintf("job %s failed with exit code %d", run.Name, run.ExitCode)
+	if run.Error != "" {
+		content += ": " + run.Error
+	}
+	if stderr := strings.TrimSpace(tail(run.Stderr, 512)); stderr != "" {
+		content += "\n" + stderr
+	}
+	s.record(node, AlertJobFailed, run.Name, check.Severity, content)
+	return nil
+}
+
+// List returns the runs matching the filter, the newest first
+func (s *JobStore) List(filter JobFilter) []model.JobRun {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	result := make([]model.JobRun, 0)
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		r := *s.runs[i]
+		if filter.Name != "" && filter.Name != r.Name ||
+			filter.Node != "" && filter.Node != r.Node ||
+			filter.State != "" && filter.State != r.State {
+			continue
+		}
+		if r.State == model.JobRunning {
+			r.Duration = now.Sub(r.StartedAt).Seconds()
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// checkRunning fires the job-too-long event of the running jobs longer than the max duration
+func (s *JobStore) checkRunning() {
+	now := time.Now()
+	for _, run := range s.List(JobFilter{State: model.JobRunning}) {
+		check := s.check(run.Name)
+		if check.MaxDuration <= 0 || now.Sub(run.StartedAt) <= time.Duration(check.MaxDuration)*time.Second {
+			continue
+		}
+		if e, ok := s.events.Get(EventID(run.Node, AlertJobTooLong, run.Name)); ok && e.State == model.EventFiring {
+			continue
+		}
+		s.record(run.Node, AlertJobTooLong, run.Name, check.Severity,
+			fmt.Sprintf("job %s is running for %.0fs, longer than %ds", run.Name, run.Duration, check.MaxDuration))
+	}
+}
+
+// check returns the first check matching the job name
+func (s *JobStore) check(name string) config.Job {
+	for _, check := range s.checks {
+		if ok, _ := path.Match(check.Name, name); ok {
+			return check
+		}
+	}
+	return config.Job{Name: name, Severity: model.SeverityWarning}
+}
+
+// record records an occurrence of the event, alert when the event starts firing and isn't silenced.
+// The silences match the job name with the proc matcher
+func (s *JobStore) record(node, kind, name, severity, content string) {
+	target := model.SilenceTarget{NodeID: node, Tag: TagJob, Proc: name, Alert: kind}
+	silenced := ""
+	if silence := s.silences.Silenced(target, time.Now()); silence != nil {
+		silenced = silence.ID
+	}
+	state, ok := s.registry.Get(node)
+	if !ok {
+		state.Labels = config.NodeLabels(node, nil)
+	}
+	_, firing := s.events.Record(node, state.Addr, kind, TagJob, name, content, silenced)
+	if !firing || silenced != "" {
+		return
+	}
+	queueAlert(s.channel, s.logger, &model.Alert{
+		Name:       kind,
+		NodeID:     node,
+		NodeTags:   config.NodeTags(node),
+		NodeLabels: state.Labels,
+		Addr:       state.Addr,
+		Tag:        TagJob,
+		Severity:   severity,
+		Content:    content,
+		Time:       time.Now(),
+	})
+}
+
+// save writes all runs to a temporary file and renames it
+func (s *JobStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(s.runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// tail returns the last max bytes of s, a broken utf-8 character at the start is dropped
+func tail(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[len(s)-max:], "")
+}
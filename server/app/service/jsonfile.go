@@ -0,0 +1,134 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+const (
+	fileLockRetry   = 10 * time.Millisecond
+	fileLockTimeout = 10 * time.Second
+	// fileLockStale is the age of a lock file left by a killed process
+	fileLockStale = 5 * time.Second
+)
+
+// jsonFile is a json file shared by the server and the subcommands. It is read again only when it changed since the
+// last read, and it is changed while holding a lock file, so the changes of two processes don't overwrite each other.
+// It isn't safe for concurrent use, the stores guard it with their own mutex
+type jsonFile struct {
+	path    string
+	perm    os.FileMode
+	modTime time.Time
+	size    int64
+}
+
+// Load decodes the file into v, a pointer, when the file changed since the last Load or Save.
+// v is reset to its zero value when the file doesn't exist, and is kept when the file can't be decoded
+func (f *jsonFile) Load(v any) error {
+	info, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		reflect.ValueOf(v).Elem().SetZero()
+		f.modTime, f.size = time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return nil
+	}
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	fresh := reflect.New(reflect.TypeOf(v).Elem())
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, fresh.Interface()); err != nil {
+			return err
+		}
+	}
+	reflect.ValueOf(v).Elem().Set(fresh.Elem())
+	f.modTime, f.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// Update locks the file, reads it into v and calls fn, v is saved when fn returns no error
+func (f *jsonFile) Update(v any, fn func() error) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// the modification time may not change within its resolution, so the file is always read under the lock
+	f.modTime = time.Time{}
+	if err = f.Load(v); err != nil {
+		return err
+	}
+	if err = fn(); err != nil {
+		return err
+	}
+	return f.save(v)
+}
+
+// save writes v to a temporary file in the same directory and renames it, so readers never see a partial file
+func (f *jsonFile) save(v any) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), f.perm); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), f.path); err != nil {
+		return err
+	}
+	if info, err := os.Stat(f.path); err == nil {
+		f.modTime, f.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+// lock creates the lock file next to the file and returns the function removing it.
+// A lock file older than fileLockStale was left by a killed process and is taken over
+func (f *jsonFile) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return nil, err
+	}
+	name := f.path + ".lock"
+	deadline := time.Now().Add(fileLockTimeout)
+	for {
+		file, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_ = file.Close()
+			return func() { _ = os.Remove(name) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > fileLockStale {
+			_ = os.Remove(name)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("the file " + f.path + " is locked by another process, remove " + name +
+				" when no process is running")
+		}
+		time.Sleep(fileLockRetry)
+	}
+}
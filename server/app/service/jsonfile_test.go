@@ -0,0 +1,46 @@
+package service
+
+import (
+	"ethstats/server/app/model"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestJsonFileConcurrentStores(t *testing.T) {
+	// the server and a subcommand open their own store of the same file
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	stores := make([]*TokenStore, 2)
+	for i := range stores {
+		store, err := NewTokenStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stores[i] = store
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for j, store := range stores {
+			wg.Add(1)
+			go func(store *TokenStore, name string) {
+				defer wg.Done()
+				if _, _, err := store.Add(name, model.ScopeRead, ""); err != nil {
+					t.Error(err)
+				}
+			}(store, fmt.Sprintf("token-%d-%d", j, i))
+		}
+	}
+	wg.Wait()
+
+	for i, store := range stores {
+		if got := len(store.List()); got != 40 {
+			t.Errorf("expected store %d to list 40 tokens, got %d", i, got)
+		}
+	}
+	matches, _ := filepath.Glob(path + ".*")
+	if len(matches) != 0 {
+		t.Errorf("expected no temporary or lock file left, got %v", matches)
+	}
+}
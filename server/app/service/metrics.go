@@ -0,0 +1,231 @@
+package service
+
+import (
+	"bytes"
+	"ethstats/common/util/promutil"
+	"ethstats/server/app/model"
+	"ethstats/server/app/notifier"
+	"github.com/bitxx/logger/logbase"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Metrics exposes the state of the nodes and the server in the Prometheus text format
+type Metrics struct {
+	logger   *logbase.Helper
+	registry *Registry
+	outbox   *notifier.Outbox
+	stats    *Stats
+}
+
+// NewMetrics creates the metrics handler
+func NewMetrics(registry *Registry, outbox *notifier.Outbox, stats *Stats, logger *logbase.Helper) *Metrics {
+	return &Metrics{logger: logger, registry: registry, outbox: outbox, stats: stats}
+}
+
+// HandleRequest writes the metrics, the callers scoped to labels only get the metrics of their nodes
+func (m *Metrics) HandleRequest(w http.ResponseWriter, req *http.Request) {
+	selector := identityOf(req).Labels
+	nodes := make([]model.NodeState, 0)
+	for _, node := range m.registry.List() {
+		if selector.Matches(node.Labels) {
+			nodes = append(nodes, node)
+		}
+	}
+
+	var buf bytes.Buffer
+	pw := promutil.NewWriter(&buf)
+	m.writeNodes(pw, nodes)
+	m.writeScraped(pw, nodes)
+	if len(selector) == 0 {
+		m.writeServer(pw, nodes)
+	}
+	if err := pw.Err(); err != nil {
+		m.logger.Errorf("write metrics error: %s", err)
+		writeError(w, http.StatusInternalServerError, "write metrics error")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// writeNodes writes the metrics of the nodes, all samples of a metric are written together
+func (m *Metrics) writeNodes(pw *promutil.Writer, nodes []model.NodeState) {
+	now := time.Now()
+	labels := make([]map[string]string, len(nodes))
+	for i, node := range nodes {
+		labels[i] = nodeMetricLabels(node)
+	}
+
+	for i, node := range nodes {
+		up := 0.0
+		if node.Online {
+			up = 1
+		}
+		pw.Write("osmonitor_node_up", promutil.TypeGauge, "Whether the node is connected to the server.", labels[i], up)
+	}
+	for i, node := range nodes {
+		if node.Latency >= 0 {
+			pw.Write("osmonitor_node_latency_milliseconds", promutil.TypeGauge,
+				"The latest latency between the node and the server.", labels[i], float64(node.Latency))
+		}
+	}
+	for i, node := range nodes {
+		if !node.LastSeen.IsZero() {
+			pw.Write("osmonitor_node_last_seen_seconds", promutil.TypeGauge,
+				"Seconds since the last message of the node.", labels[i], now.Sub(node.LastSeen).Seconds())
+		}
+	}
+	for i, node := range nodes {
+		for _, name := range sortedKeys(node.Procs) {
+			up := 0.0
+			if node.Procs[name] {
+				up = 1
+			}
+			pw.Write("osmonitor_node_process_up", promutil.TypeGauge, "Whether the configured process is running.",
+				withLabel(labels[i], "proc", name), up)
+		}
+	}
+
+	// the host stats, e.g. cpu.percent => osmonitor_node_cpu_percent. The stats named like a metric above, e.g. up,
+	// or like another stat after the sanitizing are skipped, a family can't be written twice
+	names := make(map[string]bool)
+	for _, node := range nodes {
+		for name := range node.Stats {
+			names[name] = true
+		}
+	}
+	for _, name := range sortedKeys(names) {
+		metric := "osmonitor_node_" + promutil.SanitizeName(name)
+		if pw.Has(metric) {
+			continue
+		}
+		for i, node := range nodes {
+			if value, ok := node.Stats[name]; ok {
+				pw.Write(metric, promutil.TypeGauge, "The host stat "+name+" of the node.", labels[i], value)
+			}
+		}
+	}
+
+	// the statsd metrics, e.g. api.requests.rate => osmonitor_custom_api_requests_rate
+	names = make(map[string]bool)
+	for _, node := range nodes {
+		for name := range node.Custom {
+			names[name] = true
+		}
+	}
+	for _, name := range sortedKeys(names) {
+		metric := "osmonitor_custom_" + promutil.SanitizeName(name)
+		if pw.Has(metric) {
+			continue
+		}
+		for i, node := range nodes {
+			if value, ok := node.Custom[name]; ok && node.Online {
+				pw.Write(metric, promutil.TypeGauge, "The statsd metric "+name+" of the node.", labels[i], value)
+			}
+		}
+	}
+}
+
+// writeScraped writes the samples scraped by the online nodes with the node label, a node label of a sample
+// is renamed to exported_node. The scraped metrics with the names of the osmonitor metrics are skipped
+func (m *Metrics) writeScraped(pw *promutil.Writer, nodes []model.NodeState) {
+	var samples []promutil.Sample
+	for _, node := range nodes {
+		if !node.Online {
+			continue
+		}
+		for _, s := range m.registry.Samples(node.ID) {
+			if pw.Has(s.Name) || reservedMetric(s.Name) {
+				continue
+			}
+			labels := make(map[string]string, len(s.Labels)+1)
+			for k, v := range s.Labels {
+				if k == "node" {
+					k = "exported_node"
+				}
+				labels[k] = v
+			}
+			labels["node"] = node.ID
+			samples = append(samples, promutil.Sample{Name: s.Name, Labels: labels, Value: s.Value, Type: s.Type})
+		}
+	}
+	// all samples of a metric must be written together
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Name < samples[j].Name })
+	types := make(map[string]string)
+	for _, s := range samples {
+		typ, ok := types[s.Name]
+		if !ok {
+			typ = s.Type
+			types[s.Name] = typ
+		}
+		pw.Write(s.Name, typ, "", s.Labels, s.Value)
+	}
+}
+
+// reservedMetric reports whether the name is used by the metrics of the server
+func reservedMetric(name string) bool {
+	return strings.HasPrefix(name, "osmonitor_node_") || strings.HasPrefix(name, "osmonitor_server_") ||
+		strings.HasPrefix(name, "osmonitor_custom_")
+}
+
+// writeServer writes the metrics of the server itself
+func (m *Metrics) writeServer(pw *promutil.Writer, nodes []model.NodeState) {
+	connected := 0
+	for _, node := range nodes {
+		if node.Online {
+			connected++
+		}
+	}
+	pw.Write("osmonitor_server_connected_nodes", promutil.TypeGauge, "Count of the connected nodes.", nil, float64(connected))
+
+	messages := m.stats.Messages()
+	for _, typ := range sortedKeys(messages) {
+		pw.Write("osmonitor_server_messages_total", promutil.TypeCounter, "Count of the messages received from the nodes.",
+			map[string]string{"type": typ}, float64(messages[typ]))
+	}
+	failures := m.stats.AuthFailures()
+	for _, source := range []string{AuthSourceNode, AuthSourceApi} {
+		pw.Write("osmonitor_server_auth_failures_total", promutil.TypeCounter, "Count of the failed authentications.",
+			map[string]string{"source": source}, float64(failures[source]))
+	}
+	sendFailures := m.outbox.Failures()
+	for _, name := range sortedKeys(sendFailures) {
+		pw.Write("osmonitor_server_email_send_failures_total", promutil.TypeCounter,
+			"Count of the failed attempts to send an alert message.",
+			map[string]string{"notifier": name}, float64(sendFailures[name]))
+	}
+}
+
+// nodeMetricLabels returns the node id and the labels of the node, the reserved label names are skipped
+func nodeMetricLabels(node model.NodeState) map[string]string {
+	labels := map[string]string{"node": node.ID}
+	for k, v := range node.Labels {
+		name := promutil.SanitizeLabel(k)
+		if name == "node" || name == "proc" || len(name) > 1 && name[:2] == "__" {
+			continue
+		}
+		labels[name] = v
+	}
+	return labels
+}
+
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[name] = value
+	return result
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"ethstats/server/app/notifier"
+	"ethstats/server/config"
+	"github.com/bitxx/logger"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHostStatsCollision(t *testing.T) {
+	saved := config.OutboxConfig.Path
+	t.Cleanup(func() { config.OutboxConfig.Path = saved })
+	config.OutboxConfig.Path = filepath.Join(t.TempDir(), "outbox")
+	outbox, err := notifier.NewOutbox(logger.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.Login("geth-01", "127.0.0.1:1", nil, nil)
+	registry.SetStats("geth-01", map[string]bool{"geth": true},
+		map[string]float64{"up": 0, "process_up": 0, "cpu.percent": 12, "cpu_percent": 13})
+	m := NewMetrics(registry, outbox, NewStats(), logger.NewLogger())
+
+	w := httptest.NewRecorder()
+	m.HandleRequest(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, series := range []string{`osmonitor_node_up{node="geth-01"}`, `osmonitor_node_process_up{node="geth-01"}`,
+		`osmonitor_node_cpu_percent{node="geth-01"}`} {
+		if n := strings.Count(body, "\n"+series+" "); n > 1 {
+			t.Errorf("expected the series %s once, got %d", series, n)
+		}
+	}
+	if strings.Contains(body, `osmonitor_node_process_up{node="geth-01"} 0`) {
+		t.Error("expected the stat process_up to be skipped")
+	}
+	if !strings.Contains(body, `osmonitor_node_up{node="geth-01"} 1`) ||
+		!strings.Contains(body, `osmonitor_node_cpu_percent{node="geth-01"} 12`) {
+		t.Errorf("expected the node metrics and the first of the colliding stats, got\n%s", body)
+	}
+}
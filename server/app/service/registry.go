@@ -0,0 +1,210 @@
+package service
+
+import (
+	"ethstats/common/util/promutil"
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Registry keeps the latest state of every node that has logged in since the server started
+type Registry struct {
+	lock     sync.RWMutex
+	nodes    map[string]*model.NodeState
+	samples  map[string][]promutil.Sample // node => the latest samples scraped by the node
+	watchers []func(topic string, node model.NodeState)
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{nodes: make(map[string]*model.NodeState), samples: make(map[string][]promutil.Sample)}
+}
+
+// Watch adds a function that receives the new state of a node after every change of the topic,
+// the topic is model.TopicNodes or model.TopicMetrics. It must be called before the registry is used
+func (r *Registry) Watch(fn func(topic string, node model.NodeState)) {
+	r.watchers = append(r.watchers, fn)
+}
+
+// Login marks the node online and saves its labels and inventory, the node repeats the login on the same connection periodically
+func (r *Registry) Login(id, addr string, labels, inventory map[string]string) {
+	r.lock.Lock()
+	now := time.Now()
+	node := r.node(id)
+	node.Labels = config.NodeLabels(id, labels)
+	node.Inventory = inventory
+	if !node.Online || node.Addr != addr {
+		node.LoginTime = now
+	}
+	node.Addr = addr
+	node.Online = true
+	node.LastSeen = now
+	state := copyNode(node)
+	r.lock.Unlock()
+	r.notify(state, model.TopicNodes)
+}
+
+// Logout marks the node offline, unless it has logged in again from another connection
+func (r *Registry) Logout(id, addr string) {
+	r.lock.Lock()
+	node, ok := r.nodes[id]
+	if !ok || node.Addr != addr {
+		r.lock.Unlock()
+		return
+	}
+	node.Online = false
+	node.Latency = -1
+	delete(r.samples, id)
+	state := copyNode(node)
+	r.lock.Unlock()
+	r.notify(state, model.TopicNodes, model.TopicMetrics)
+}
+
+// Touch updates the last seen time of the node
+func (r *Registry) Touch(id string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if node, ok := r.nodes[id]; ok {
+		node.LastSeen = time.Now()
+	}
+}
+
+// SetLatency saves the latency of the node in millisecond
+func (r *Registry) SetLatency(id string, latency int64) {
+	r.lock.Lock()
+	node, ok := r.nodes[id]
+	if !ok {
+		r.lock.Unlock()
+		return
+	}
+	node.Latency = latency
+	node.LastSeen = time.Now()
+	state := copyNode(node)
+	r.lock.Unlock()
+	r.notify(state, model.TopicMetrics)
+}
+
+// SetStats saves the process state and host metrics of the node
+func (r *Registry) SetStats(id string, procs map[string]bool, stats map[string]float64) {
+	r.lock.Lock()
+	node, ok := r.nodes[id]
+	if !ok {
+		r.lock.Unlock()
+		return
+	}
+	node.Procs = procs
+	node.Stats = stats
+	node.StatsTime = time.Now()
+	node.LastSeen = node.StatsTime
+	state := copyNode(node)
+	r.lock.Unlock()
+	r.notify(state, model.TopicNodes, model.TopicMetrics)
+}
+
+// SetCustom saves the custom metrics of the node
+func (r *Registry) SetCustom(id string, metrics map[string]float64) {
+	r.lock.Lock()
+	node, ok := r.nodes[id]
+	if !ok {
+		r.lock.Unlock()
+		return
+	}
+	node.Custom = metrics
+	node.LastSeen = time.Now()
+	state := copyNode(node)
+	r.lock.Unlock()
+	r.notify(state, model.TopicMetrics)
+}
+
+// SetSamples replaces the scraped samples of the node
+func (r *Registry) SetSamples(id string, samples []promutil.Sample) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	node, ok := r.nodes[id]
+	if !ok {
+		return
+	}
+	node.LastSeen = time.Now()
+	r.samples[id] = samples
+}
+
+// Samples returns the latest scraped samples of the node, they are dropped when the node goes offline.
+// The samples must not be modified
+func (r *Registry) Samples(id string) []promutil.Sample {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.samples[id]
+}
+
+// notify passes the state to the watchers, it's called without the lock, so the watchers can read the registry
+func (r *Registry) notify(state model.NodeState, topics ...string) {
+	for _, topic := range topics {
+		for _, fn := range r.watchers {
+			fn(topic, state)
+		}
+	}
+}
+
+// Get returns a copy of the node state
+func (r *Registry) Get(id string) (model.NodeState, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	node, ok := r.nodes[id]
+	if !ok {
+		return model.NodeState{}, false
+	}
+	return copyNode(node), true
+}
+
+// List returns copies of all node states sorted by id
+func (r *Registry) List() []model.NodeState {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	result := make([]model.NodeState, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		result = append(result, copyNode(node))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (r *Registry) node(id string) *model.NodeState {
+	node, ok := r.nodes[id]
+	if !ok {
+		node = &model.NodeState{ID: id, Latency: -1}
+		r.nodes[id] = node
+	}
+	return node
+}
+
+// copyNode copies the maps too, so callers can't race with the relay
+func copyNode(node *model.NodeState) model.NodeState {
+	result := *node
+	result.Labels = make(map[string]string, len(node.Labels))
+	for k, v := range node.Labels {
+		result.Labels[k] = v
+	}
+	result.Inventory = make(map[string]string, len(node.Inventory))
+	for k, v := range node.Inventory {
+		result.Inventory[k] = v
+	}
+	result.Procs = make(map[string]bool, len(node.Procs))
+	for k, v := range node.Procs {
+		result.Procs[k] = v
+	}
+	result.Stats = make(map[string]float64, len(node.Stats))
+	for k, v := range node.Stats {
+		result.Stats[k] = v
+	}
+	if node.Custom != nil {
+		result.Custom = make(map[string]float64, len(node.Custom))
+		for k, v := range node.Custom {
+			result.Custom[k] = v
+		}
+	}
+	return result
+}
@@ -2,14 +2,18 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
+	"ethstats/common/util/authutil"
 	"ethstats/common/util/connutil"
-	"ethstats/common/util/dateutil"
+	"ethstats/common/util/tlsutil"
 	"ethstats/server/app/model"
 	"ethstats/server/config"
 	"fmt"
 	"github.com/bitxx/logger/logbase"
 	"github.com/gorilla/websocket"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,25 +22,59 @@ const (
 	messagePing       string = "node-ping"
 	messageProcReport string = "proc-report"
 	messageLatency    string = "latency"
+	messageNodeStats  string = "node-stats"
+	messageScrape     string = "scrape-report"
+	messageCustom     string = "custom-metrics"
+	messageHeartbeat  string = "heartbeat"
+	messageJobReport  string = "job-report"
+	messageEnroll     string = "enroll"
 
-	TagErr        = "error info"  //use for tag poolInfo key
-	TagProcReport = "proc report" //use for tag poolInfo key
+	TagErr        = "error info"  //use for tag event
+	TagProcReport = "proc report" //use for tag event
+
+	AlertNodeError = "node-error" //event kind and alert name of a broken node connection
+	AlertProcDown  = "proc-down"  //event kind and alert name of a stopped process, the subject is the proc name
+
+	subjectConnection = "connection" //subject of the node-error event
+
+	maxScrapeSamples = 50000 //max count of the scraped samples kept for a node
 )
 
+// alertSeverities is the severity of the alerts raised by the relay, used by the alert routes
+var alertSeverities = map[string]string{
+	AlertNodeError: model.SeverityWarning,
+	AlertProcDown:  model.SeverityCritical,
+}
+
 // NodeRelay contains the secret used to authenticate the communication between
 // the Ethereum node and this server
 type NodeRelay struct {
-	secret  string
-	logger  *logbase.Helper
-	channel *model.Channel
+	secret   string
+	logger   *logbase.Helper
+	channel  *model.Channel
+	registry *Registry
+	events   *EventStore
+	silences *SilenceStore
+	stats    *Stats
+	beats    *HeartbeatMonitor
+	jobs     *JobStore
+	creds    *CredentialStore
 }
 
 // NewRelay creates a new NodeRelay struct with required fields
-func NewRelay(channel *model.Channel, logger *logbase.Helper) *NodeRelay {
+func NewRelay(channel *model.Channel, registry *Registry, events *EventStore, silences *SilenceStore, stats *Stats,
+	beats *HeartbeatMonitor, jobs *JobStore, creds *CredentialStore, logger *logbase.Helper) *NodeRelay {
 	return &NodeRelay{
-		channel: channel,
-		secret:  config.ApplicationConfig.Secret,
-		logger:  logger,
+		channel:  channel,
+		secret:   config.ApplicationConfig.Secret,
+		logger:   logger,
+		registry: registry,
+		events:   events,
+		silences: silences,
+		stats:    stats,
+		beats:    beats,
+		jobs:     jobs,
+		creds:    creds,
 	}
 }
 
@@ -49,10 +87,16 @@ func (n *NodeRelay) Close() {
 // HandleRequest is the function to handle all server requests that came from
 // Ethereum nodes
 func (n *NodeRelay) HandleRequest(w http.ResponseWriter, r *http.Request) {
+	// the common name of the verified client certificate, the node must login with it as the id
+	certName := tlsutil.PeerName(r.TLS)
+	if certName == "" && config.TLSConfig.RequireClientCert {
+		n.stats.AuthFailure(AuthSourceNode)
+		n.logger.Warnf("reject the node connection without client certificate (addr=%s)", r.RemoteAddr)
+		http.Error(w, "client certificate required", http.StatusForbidden)
+		return
+	}
 	upgradeConn := websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
+		CheckOrigin: CheckOrigin,
 	}
 	conn, err := connutil.NewUpgradeConn(upgradeConn, w, r)
 	if err != nil {
@@ -60,21 +104,22 @@ func (n *NodeRelay) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	n.logger.Infof("new node connected! (addr=%s, host=%s)", r.RemoteAddr, r.Host)
-	go n.loop(conn)
+	go n.loop(conn, certName)
 }
 
 // loop loops as long as the connection is alive and retrieves node packages
-func (n *NodeRelay) loop(c *connutil.ConnWrapper) {
+func (n *NodeRelay) loop(c *connutil.ConnWrapper, certName string) {
 	errMsg := ""
 	// Close connection if an unexpected error occurs and delete the node
 	// from the map of connected nodes...
 	defer func(c *connutil.ConnWrapper) {
 		if n.channel.LoginIDs[c.RemoteAddr().String()] != "" && errMsg != "" {
-			n.savePoolInfo(c, TagErr, errMsg)
+			n.recordEvent(c, model.SilenceTarget{Tag: TagErr, Alert: AlertNodeError}, subjectConnection, errMsg)
 		}
 
 		//remove error node
 		if n.channel.LoginIDs[c.RemoteAddr().String()] != "" {
+			n.registry.Logout(n.channel.LoginIDs[c.RemoteAddr().String()], c.RemoteAddr().String())
 			delete(n.channel.LoginIDs, c.RemoteAddr().String())
 		}
 
@@ -82,6 +127,16 @@ func (n *NodeRelay) loop(c *connutil.ConnWrapper) {
 		n.logger.Warnf("connection with node closed, there are %d connected nodes", len(n.channel.LoginIDs))
 	}(c)
 
+	// every login must sign a new challenge, so a captured hello message can't be used again
+	nonce, err := n.challenge(c)
+	if err != nil {
+		errMsg = fmt.Sprintf("error sending challenge to node, error: %s", err)
+		return
+	}
+
+	// the rotated key sent on this connection
+	sentKey := ""
+
 	// Client loop
 	for {
 		_, content, err := c.ReadMessage()
@@ -97,6 +152,36 @@ func (n *NodeRelay) loop(c *connutil.ConnWrapper) {
 			return
 		}
 		switch msgType {
+		case messageHello, messagePing, messageProcReport, messageLatency, messageNodeStats, messageScrape, messageCustom,
+			messageHeartbeat, messageJobReport, messageEnroll:
+			n.stats.Message(msgType)
+		default:
+			n.stats.Message("unknown")
+		}
+		// only the login, the enrollment and the signed job report of the wrap command are accepted before the login
+		if n.channel.LoginIDs[c.RemoteAddr().String()] == "" && msgType != messageHello && msgType != messageEnroll &&
+			msgType != messageJobReport {
+			n.logger.Warnf("ignore the %s message of the connection %s before the login", msgType, c.RemoteAddr())
+			continue
+		}
+		// the credential is checked again on every message, so a revoked node is dropped and a rotated key is sent
+		// without waiting for the next login
+		if id := n.channel.LoginIDs[c.RemoteAddr().String()]; id != "" {
+			if n.creds.Revoked(id) {
+				n.stats.AuthFailure(AuthSourceNode)
+				n.logger.Warnf("close the connection of node %s, its credential is revoked", id)
+				_ = c.WriteJSON(map[string][]interface{}{"emit": {"un-authorization", "authorization error,the credential of the node is revoked"}})
+				return
+			}
+			if key, ok := n.creds.PendingKey(id); ok && key != sentKey {
+				if err = sendCredential(c, key); err != nil {
+					errMsg = fmt.Sprintf("error sending the rotated credential to node[%s], error: %s", id, err)
+					return
+				}
+				sentKey = key
+			}
+		}
+		switch msgType {
 		case messageHello:
 			authMsg, parseError := n.parseAuthMessage(msg)
 			if parseError != nil {
@@ -108,16 +193,32 @@ func (n *NodeRelay) loop(c *connutil.ConnWrapper) {
 				}
 				return
 			}
-			// first check if the secret is correct
-			if authMsg.Secret != n.secret {
-				errMsg = fmt.Sprintf("authorization error,invalid secret")
-				loginErr := authMsg.SendLoginErrResponse(c, "authorization error,invalid secret")
+			// first check if the signature or the secret is correct
+			authErr := n.verify(nonce, authMsg.ID, authMsg.Timestamp, authMsg.Signature, authMsg.Secret)
+			if nonce, err = n.challenge(c); err != nil {
+				errMsg = fmt.Sprintf("error sending challenge to node[%s], error: %s", authMsg.ID, err)
+				return
+			}
+			if authErr != nil {
+				n.stats.AuthFailure(AuthSourceNode)
+				errMsg = fmt.Sprintf("authorization error,%s", authErr)
+				loginErr := authMsg.SendLoginErrResponse(c, errMsg)
 				if loginErr != nil {
 					errMsg = fmt.Sprintf("error sending authorization response [invalid secret] to node[%s], error: %s", authMsg.ID, loginErr)
 					return
 				}
 				return
 			}
+			if certName != "" && authMsg.ID != certName {
+				n.stats.AuthFailure(AuthSourceNode)
+				errMsg = fmt.Sprintf("the id [%s] doesn't match the client certificate [%s]", authMsg.ID, certName)
+				loginErr := authMsg.SendLoginErrResponse(c, "authorization error,the id doesn't match the client certificate")
+				if loginErr != nil {
+					errMsg = fmt.Sprintf("error sending authorization response [certificate mismatch] to node[%s], error: %s", authMsg.ID, loginErr)
+					return
+				}
+				return
+			}
 			//判断节点名称是否重复，遍历效率有点低，有时间了在考虑怎么优化，或者伙计们可以帮忙想个简单的法子
 			for k, v := range n.channel.LoginIDs {
 				if v == authMsg.ID && k != c.RemoteAddr().String() {
@@ -136,7 +237,17 @@ func (n *NodeRelay) loop(c *connutil.ConnWrapper) {
 				errMsg = fmt.Sprintf("error sending authorization response to node[%s], error: %s", authMsg.ID, sendError)
 				return
 			}
+			// a rotated key is sent after every login until the node uses it
+			if key, ok := n.creds.PendingKey(authMsg.ID); ok {
+				if err = sendCredential(c, key); err != nil {
+					errMsg = fmt.Sprintf("error sending the rotated credential to node[%s], error: %s", authMsg.ID, err)
+					return
+				}
+				sentKey = key
+			}
 			n.channel.LoginIDs[c.RemoteAddr().String()] = authMsg.ID
+			n.registry.Login(authMsg.ID, c.RemoteAddr().String(), authMsg.Labels, authMsg.Inventory)
+			n.events.ResolveKind(authMsg.ID, AlertNodeError)
 			n.logger.Infof("node %s login, now %d nodes connected", authMsg.ID, len(n.channel.LoginIDs))
 		case messagePing:
 			// When the node emit a ping message, we need to respond with pong
@@ -153,6 +264,7 @@ func (n *NodeRelay) loop(c *connutil.ConnWrapper) {
 				return
 			}
 			n.logger.Trace("response message type: pong")
+			n.registry.Touch(n.channel.LoginIDs[c.RemoteAddr().String()])
 			n.channel.MsgPing <- content
 		case messageProcReport:
 			procReport, err := n.parseProcReportMessage(msg)
@@ -160,27 +272,175 @@ func (n *NodeRelay) loop(c *connutil.ConnWrapper) {
 				errMsg = fmt.Sprintf("get error proc report from node[%s] is wrong, error: %s", procReport.ID, err)
 				return
 			}
-			n.savePoolInfo(c, TagProcReport, "these processes are stopped: "+procReport.Data)
+			for _, proc := range strings.Split(procReport.Data, ",") {
+				if proc == "" {
+					continue
+				}
+				target := model.SilenceTarget{Tag: TagProcReport, Proc: proc, Alert: AlertProcDown}
+				n.recordEvent(c, target, proc, "this process is stopped: "+proc)
+			}
 		case messageLatency:
+			if latency, err := n.parseLatencyMessage(msg); err == nil {
+				if ms, err := strconv.ParseInt(latency.Latency, 10, 64); err == nil {
+					n.registry.SetLatency(n.channel.LoginIDs[c.RemoteAddr().String()], ms)
+				}
+			}
 			n.channel.MsgLatency <- content
+		case messageNodeStats:
+			stats, err := n.parseNodeStatsMessage(msg)
+			if err != nil {
+				errMsg = fmt.Sprintf("can't parse stats message sent by node[%s], error: %s", stats.ID, err)
+				return
+			}
+			id := n.channel.LoginIDs[c.RemoteAddr().String()]
+			n.registry.SetStats(id, stats.Procs, stats.Stats)
+			for proc, running := range stats.Procs {
+				if running {
+					n.events.Resolve(id, AlertProcDown, proc)
+				}
+			}
+		case messageCustom:
+			custom, err := n.parseCustomMessage(msg)
+			if err != nil {
+				errMsg = fmt.Sprintf("can't parse custom metrics sent by node[%s], error: %s", custom.ID, err)
+				return
+			}
+			n.registry.SetCustom(n.channel.LoginIDs[c.RemoteAddr().String()], custom.Metrics)
+		case messageHeartbeat:
+			ping, err := n.parseHeartbeatMessage(msg)
+			if err != nil {
+				errMsg = fmt.Sprintf("can't parse heartbeat sent by node[%s], error: %s", ping.ID, err)
+				return
+			}
+			id := n.channel.LoginIDs[c.RemoteAddr().String()]
+			if err = n.beats.Ping(ping.Name, id, ping.Status, ping.Message); errors.Is(err, ErrHeartbeatNode) {
+				n.stats.AuthFailure(AuthSourceNode)
+				n.logger.Warnf("reject heartbeat %s of node %s from %s: %s", ping.Name, id, c.RemoteAddr(), err)
+			} else if err != nil {
+				n.logger.Warnf("heartbeat %s of node %s error: %s", ping.Name, id, err)
+			}
+		case messageEnroll:
+			enroll, err := n.parseEnrollMessage(msg)
+			if err != nil {
+				errMsg = fmt.Sprintf("can't parse enrollment sent by node[%s], error: %s", enroll.ID, err)
+				return
+			}
+			var cred *model.Credential
+			if certName != "" && enroll.ID != certName {
+				err = errors.New("the id doesn't match the client certificate")
+			} else {
+				cred, err = n.creds.Enroll(enroll.ID, enroll.Token, c.RemoteAddr().String())
+			}
+			if err != nil {
+				n.stats.AuthFailure(AuthSourceNode)
+				n.logger.Warnf("enrollment of node[%s] from %s error: %s", enroll.ID, c.RemoteAddr(), err)
+				_ = c.WriteJSON(map[string][]interface{}{"emit": {"un-authorization", "enrollment error," + err.Error()}})
+				return
+			}
+			n.logger.Infof("node %s enrolled from %s", enroll.ID, c.RemoteAddr())
+			if err = sendCredential(c, cred.Key); err != nil {
+				errMsg = fmt.Sprintf("error sending the credential to node[%s], error: %s", enroll.ID, err)
+				return
+			}
+		case messageJobReport:
+			report, err := n.parseJobReportMessage(msg)
+			if err != nil {
+				errMsg = fmt.Sprintf("can't parse job report sent by node[%s], error: %s", report.ID, err)
+				return
+			}
+			// the wrap command connects without login and signs the challenge with the report
+			id := n.channel.LoginIDs[c.RemoteAddr().String()]
+			if id == "" {
+				authErr := n.verify(nonce, report.ID, report.Timestamp, report.Signature, report.Secret)
+				if authErr == nil && (report.ID == "" || certName != "" && report.ID != certName) {
+					authErr = errors.New("the id doesn't match the client certificate")
+				}
+				if authErr != nil {
+					n.stats.AuthFailure(AuthSourceNode)
+					n.logger.Warnf("job report of node[%s] from %s error: %s", report.ID, c.RemoteAddr(), authErr)
+					_ = c.WriteJSON(map[string][]interface{}{"emit": {"un-authorization", "authorization error," + authErr.Error()}})
+					return
+				}
+				if nonce, err = n.challenge(c); err != nil {
+					errMsg = fmt.Sprintf("error sending challenge to node[%s], error: %s", report.ID, err)
+					return
+				}
+				id = report.ID
+			}
+			if err = n.jobs.Report(id, report.Run); err != nil {
+				n.logger.Warnf("job report of node %s error: %s", id, err)
+				_ = c.WriteJSON(map[string][]interface{}{"emit": {"job-error", err.Error()}})
+				break
+			}
+			_ = c.WriteJSON(map[string][]interface{}{"emit": {"job-ack", report.Run.ID}})
+		case messageScrape:
+			report, err := n.parseScrapeMessage(msg)
+			if err != nil {
+				errMsg = fmt.Sprintf("can't parse scrape report sent by node[%s], error: %s", report.ID, err)
+				return
+			}
+			id := n.channel.LoginIDs[c.RemoteAddr().String()]
+			if len(report.Samples) > maxScrapeSamples {
+				n.logger.Warnf("node %s sent %d scraped samples, only the first %d are kept", id, len(report.Samples), maxScrapeSamples)
+				report.Samples = report.Samples[:maxScrapeSamples]
+			}
+			n.registry.SetSamples(id, report.Samples)
 		}
 	}
 }
 
-// savePoolInfo
+// recordEvent
 //
-//	@Description: save pool info
+//	@Description: record an occurrence of the event, alert when the event starts firing and isn't silenced
 //	@receiver n
 //	@param c
+//	@param target silence target of the event, the node id is filled here
+//	@param subject
+//	@param content
+func (n *NodeRelay) recordEvent(c *connutil.ConnWrapper, target model.SilenceTarget, subject, content string) {
+	target.NodeID = n.channel.LoginIDs[c.RemoteAddr().String()]
+	silenced := ""
+	if silence := n.silences.Silenced(target, time.Now()); silence != nil {
+		silenced = silence.ID
+	}
+	_, firing := n.events.Record(target.NodeID, c.RemoteAddr().String(), target.Alert, target.Tag, subject, content, silenced)
+	if firing && silenced == "" {
+		n.raiseAlert(c, target.Alert, target.Tag, content)
+	}
+}
+
+// raiseAlert
+//
+//	@Description: send a real-time alert to the hub, the alert is dropped when the hub is busy
+//	@receiver n
+//	@param c
+//	@param name
 //	@param tag
 //	@param content
-func (n *NodeRelay) savePoolInfo(c *connutil.ConnWrapper, tag, content string) {
-	now := dateutil.ConvertToStr(time.Now(), -1)
-	content = "node: [" + n.channel.LoginIDs[c.RemoteAddr().String()] + "-" + c.RemoteAddr().String() + "] " + content
-	if len(n.channel.InfoPool[tag]) <= 0 {
-		n.channel.InfoPool[tag] = make(map[string]string)
+func (n *NodeRelay) raiseAlert(c *connutil.ConnWrapper, name, tag, content string) {
+	id := n.channel.LoginIDs[c.RemoteAddr().String()]
+	node, _ := n.registry.Get(id)
+	alert := &model.Alert{
+		Name:       name,
+		NodeID:     id,
+		NodeTags:   config.NodeTags(id),
+		NodeLabels: node.Labels,
+		Addr:       c.RemoteAddr().String(),
+		Tag:        tag,
+		Severity:   alertSeverities[name],
+		Content:    content,
+		Time:       time.Now(),
+	}
+	queueAlert(n.channel, n.logger, alert)
+}
+
+// queueAlert sends the alert to the hub, the alert is dropped when the hub is busy
+func queueAlert(channel *model.Channel, logger *logbase.Helper, alert *model.Alert) {
+	select {
+	case channel.Alerts <- alert:
+	default:
+		logger.Warnf("alert channel is full, drop alert %s of node %s", alert.Name, alert.NodeID)
 	}
-	n.channel.InfoPool[tag][content] = now
 }
 
 // parseProcReportMessage
@@ -199,6 +459,72 @@ func (n *NodeRelay) parseProcReportMessage(msg model.Message) (*model.ProcReport
 	return &report, err
 }
 
+// parseNodeStatsMessage parse the status report with the process state and host metrics
+func (n *NodeRelay) parseNodeStatsMessage(msg model.Message) (*model.NodeStats, error) {
+	value, err := msg.GetValue()
+	if err != nil {
+		return &model.NodeStats{}, err
+	}
+	var stats model.NodeStats
+	err = json.Unmarshal(value, &stats)
+	return &stats, err
+}
+
+// parseCustomMessage parse the statsd metrics aggregated by the node
+func (n *NodeRelay) parseCustomMessage(msg model.Message) (*model.CustomMetrics, error) {
+	value, err := msg.GetValue()
+	if err != nil {
+		return &model.CustomMetrics{}, err
+	}
+	var custom model.CustomMetrics
+	err = json.Unmarshal(value, &custom)
+	return &custom, err
+}
+
+// parseHeartbeatMessage parse the heartbeat of a check relayed by the node
+func (n *NodeRelay) parseHeartbeatMessage(msg model.Message) (*model.HeartbeatPing, error) {
+	value, err := msg.GetValue()
+	if err != nil {
+		return &model.HeartbeatPing{}, err
+	}
+	var ping model.HeartbeatPing
+	err = json.Unmarshal(value, &ping)
+	return &ping, err
+}
+
+// parseJobReportMessage parse a job run sent by the node or the wrap command
+func (n *NodeRelay) parseJobReportMessage(msg model.Message) (*model.JobReport, error) {
+	value, err := msg.GetValue()
+	if err != nil {
+		return &model.JobReport{}, err
+	}
+	var report model.JobReport
+	err = json.Unmarshal(value, &report)
+	return &report, err
+}
+
+// parseScrapeMessage parse the samples of the local exporters scraped by the node
+func (n *NodeRelay) parseScrapeMessage(msg model.Message) (*model.ScrapeReport, error) {
+	value, err := msg.GetValue()
+	if err != nil {
+		return &model.ScrapeReport{}, err
+	}
+	var report model.ScrapeReport
+	err = json.Unmarshal(value, &report)
+	return &report, err
+}
+
+// parseLatencyMessage parse the latency measured by the node
+func (n *NodeRelay) parseLatencyMessage(msg model.Message) (*model.NodeLatency, error) {
+	value, err := msg.GetValue()
+	if err != nil {
+		return &model.NodeLatency{}, err
+	}
+	var latency model.NodeLatency
+	err = json.Unmarshal(value, &latency)
+	return &latency, err
+}
+
 // parseNodePingMessage parse the current ping message sent bu the Ethereum node
 // and creates a message.NodePing struct with that info
 func (n *NodeRelay) parseNodePingMessage(msg model.Message) (*model.NodePing, error) {
@@ -211,6 +537,49 @@ func (n *NodeRelay) parseNodePingMessage(msg model.Message) (*model.NodePing, er
 	return &ping, err
 }
 
+// challenge sends a new nonce to the node, the next login of the connection must sign it
+func (n *NodeRelay) challenge(c *connutil.ConnWrapper) (string, error) {
+	nonce := authutil.NewNonce()
+	return nonce, c.WriteJSON(map[string][]interface{}{"emit": {"challenge", map[string]string{"nonce": nonce}}})
+}
+
+// verify checks the signature of the nonce with the credential of the node. The nodes without a credential sign with
+// the shared secret, or send the secret when they are legacy clients and the legacy login is enabled
+func (n *NodeRelay) verify(nonce, id string, timestamp int64, signature, secret string) error {
+	if found, err := n.creds.Verify(id, nonce, timestamp, signature); found {
+		return err
+	}
+	if config.CredentialsConfig.Required {
+		return errors.New("the node has no credential, enroll it with a join token")
+	}
+	if signature != "" {
+		return authutil.Verify(n.secret, nonce, id, timestamp, signature, time.Now())
+	}
+	if !config.ApplicationConfig.LegacyAuth {
+		return errors.New("the secret login is disabled, please upgrade the client")
+	}
+	if !authutil.Equal(secret, n.secret) {
+		return errors.New("invalid secret")
+	}
+	return nil
+}
+
+// sendCredential sends the key of the node, the node saves it and uses it on the next login
+func sendCredential(c *connutil.ConnWrapper, key string) error {
+	return c.WriteJSON(map[string][]interface{}{"emit": {"credential", map[string]string{"key": key}}})
+}
+
+// parseEnrollMessage parse the join token sent by a node without a credential
+func (n *NodeRelay) parseEnrollMessage(msg model.Message) (*model.EnrollMessage, error) {
+	value, err := msg.GetValue()
+	if err != nil {
+		return &model.EnrollMessage{}, err
+	}
+	var detail model.EnrollMessage
+	err = json.Unmarshal(value, &detail)
+	return &detail, err
+}
+
 // parseAuthMessage parse the current byte array and transforms it to an AuthMessage struct.
 // If an error occurs when json unmarshal, an error is returned
 func (n *NodeRelay) parseAuthMessage(msg model.Message) (*model.AuthMessage, error) {
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"ethstats/common/util/authutil"
+	"ethstats/server/config"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRelayVerify(t *testing.T) {
+	savedRequired, savedLegacy := config.CredentialsConfig.Required, config.ApplicationConfig.LegacyAuth
+	t.Cleanup(func() {
+		config.CredentialsConfig.Required, config.ApplicationConfig.LegacyAuth = savedRequired, savedLegacy
+	})
+	creds, err := NewCredentialStore(filepath.Join(t.TempDir(), "credentials.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enroll := func(node string) string {
+		_, secret, err := creds.AddJoinToken(node, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cred, err := creds.Enroll(node, secret, "127.0.0.1:1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cred.Key
+	}
+	key := enroll("geth-01")
+	revokedKey := enroll("geth-02")
+	if err = creds.Revoke("geth-02"); err != nil {
+		t.Fatal(err)
+	}
+	n := &NodeRelay{secret: "123456", creds: creds}
+	nonce := authutil.NewNonce()
+	now := time.Now().Unix()
+
+	cases := []struct {
+		name      string
+		id        string
+		timestamp int64
+		signature string
+		secret    string
+		required  bool
+		legacy    bool
+		ok        bool
+	}{
+		{name: "credential", id: "geth-01", signature: authutil.Sign(key, nonce, "geth-01", now), ok: true},
+		{name: "credential required", id: "geth-01", signature: authutil.Sign(key, nonce, "geth-01", now), required: true,
+			ok: true},
+		{name: "shared secret of a node with credential", id: "geth-01",
+			signature: authutil.Sign("123456", nonce, "geth-01", now)},
+		{name: "legacy secret of a node with credential", id: "geth-01", secret: "123456", legacy: true},
+		{name: "revoked credential", id: "geth-02", signature: authutil.Sign(revokedKey, nonce, "geth-02", now)},
+		{name: "shared secret", id: "geth-03", signature: authutil.Sign("123456", nonce, "geth-03", now), ok: true},
+		{name: "shared secret of another node", id: "geth-03", signature: authutil.Sign("123456", nonce, "geth-04", now)},
+		{name: "shared secret expired", id: "geth-03", timestamp: now - 3600,
+			signature: authutil.Sign("123456", nonce, "geth-03", now-3600)},
+		{name: "shared secret required", id: "geth-03", signature: authutil.Sign("123456", nonce, "geth-03", now),
+			required: true},
+		{name: "wrong secret", id: "geth-03", signature: authutil.Sign("654321", nonce, "geth-03", now)},
+		{name: "legacy secret", id: "geth-03", secret: "123456", legacy: true, ok: true},
+		{name: "legacy secret disabled", id: "geth-03", secret: "123456"},
+		{name: "wrong legacy secret", id: "geth-03", secret: "654321", legacy: true},
+		{name: "legacy secret required", id: "geth-03", secret: "123456", legacy: true, required: true},
+	}
+	for _, c := range cases {
+		config.CredentialsConfig.Required, config.ApplicationConfig.LegacyAuth = c.required, c.legacy
+		timestamp := c.timestamp
+		if timestamp == 0 {
+			timestamp = now
+		}
+		err := n.verify(nonce, c.id, timestamp, c.signature, c.secret)
+		if c.ok && err != nil {
+			t.Errorf("%s: expected the login to be accepted, got %s", c.name, err)
+		}
+		if !c.ok && err == nil {
+			t.Errorf("%s: expected the login to be rejected", c.name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"ethstats/common/util/cronutil"
+	"ethstats/server/app/digest"
+	"ethstats/server/app/model"
+	"ethstats/server/app/notifier"
+	"ethstats/server/config"
+	"fmt"
+	"github.com/bitxx/logger/logbase"
+	"time"
+)
+
+// Reporter sends the digests of the report definitions on their schedules
+type Reporter struct {
+	logger   *logbase.Helper
+	registry *Registry
+	events   *EventStore
+	reports  []*scheduledReport
+}
+
+// scheduledReport is a report definition with its parsed schedule
+type scheduledReport struct {
+	config.Report
+	schedule  cronutil.Schedule
+	location  *time.Location
+	notifiers notifier.Notifiers
+	last      time.Time // the events seen since the last sent report are in the next report
+}
+
+// NewReporter creates the reports of the reports config.
+// When no report is configured, one report with all nodes is sent every email delayTime seconds, as before
+func NewReporter(registry *Registry, events *EventStore, notifiers notifier.Notifiers, logger *logbase.Helper) (*Reporter, error) {
+	items := *config.ReportsConfig
+	if len(items) == 0 && config.EmailConfig.DelayTime > 0 {
+		items = []config.Report{{Cron: fmt.Sprintf("@every %ds", config.EmailConfig.DelayTime)}}
+	}
+	r := &Reporter{
+		logger:   logger,
+		registry: registry,
+		events:   events,
+	}
+	names := make(map[string]bool)
+	for _, item := range items {
+		if names[item.Name] {
+			return nil, fmt.Errorf("report name [%s] is repeated", item.Name)
+		}
+		names[item.Name] = true
+		switch item.Scope {
+		case "", digest.ScopeFull, digest.ScopeEvents, digest.ScopeSummary:
+		default:
+			return nil, fmt.Errorf("report [%s]: unknown scope %s", item.Name, item.Scope)
+		}
+		schedule, err := cronutil.Parse(item.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("report [%s]: %s", item.Name, err)
+		}
+		location := time.Local
+		if item.Timezone != "" {
+			if location, err = time.LoadLocation(item.Timezone); err != nil {
+				return nil, fmt.Errorf("report [%s]: %s", item.Name, err)
+			}
+		}
+		selected, err := notifiers.Select(item.Notifiers)
+		if err != nil {
+			return nil, fmt.Errorf("report [%s]: %s", item.Name, err)
+		}
+		r.reports = append(r.reports, &scheduledReport{
+			Report:    item,
+			schedule:  schedule,
+			location:  location,
+			notifiers: selected,
+			last:      time.Now(),
+		})
+	}
+	return r, nil
+}
+
+// Start runs the schedule of every report
+func (r *Reporter) Start() {
+	for _, report := range r.reports {
+		go r.loop(report)
+	}
+}
+
+func (r *Reporter) loop(report *scheduledReport) {
+	for {
+		next := report.schedule.Next(time.Now().In(report.location))
+		if next.IsZero() {
+			r.logger.Errorf("report [%s] schedule %s never fires", report.Name, report.Cron)
+			return
+		}
+		time.Sleep(time.Until(next))
+		r.send(report)
+	}
+}
+
+// send renders and delivers the report, the errors are only logged
+func (r *Reporter) send(report *scheduledReport) {
+	now := time.Now()
+	title := fmt.Sprintf("%s-monitor report", now.In(report.location).Format("2006-01-02 15:04:05"))
+	if report.Name != "" {
+		title = fmt.Sprintf("%s-%s report", now.In(report.location).Format("2006-01-02 15:04:05"), report.Name)
+	}
+	data := digest.Build(title, r.registry.List(), r.events.List(EventFilter{Since: report.last}),
+		digest.Options{Scope: report.Scope, Nodes: report.Nodes, Labels: model.SelectorFromMap(report.Labels), GroupBy: report.GroupBy})
+	if len(data.Events) <= 0 && !report.SendEmpty {
+		return
+	}
+	text, html, err := digest.Render(data)
+	if err != nil {
+		r.logger.Errorf("render report [%s] error: %s", report.Name, err)
+		return
+	}
+	report.last = now
+	msg := &notifier.Message{
+		Kind:    notifier.KindReport,
+		Subject: title,
+		Content: text,
+		Html:    html,
+		To:      report.To,
+	}
+	if err = report.notifiers.Send(msg); err != nil {
+		r.logger.Errorf("send report [%s] error: %s", report.Name, err)
+	}
+}
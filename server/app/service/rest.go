@@ -0,0 +1,296 @@
+package service
+
+import (
+	"encoding/json"
+	"ethstats/server/app/model"
+	"ethstats/server/app/notifier"
+	"fmt"
+	"github.com/bitxx/logger/logbase"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// Rest serves the json http api of the server
+type Rest struct {
+	logger   *logbase.Helper
+	registry *Registry
+	events   *EventStore
+	alerts   *AlertLog
+	silences *SilenceStore
+	outbox   *notifier.Outbox
+	auth     *Auth
+	beats    *HeartbeatMonitor
+	jobs     *JobStore
+	started  time.Time
+}
+
+// NewRest creates a new Rest struct with the required service
+func NewRest(registry *Registry, events *EventStore, alerts *AlertLog, silences *SilenceStore, outbox *notifier.Outbox,
+	auth *Auth, beats *HeartbeatMonitor, jobs *JobStore, logger *logbase.Helper) *Rest {
+	return &Rest{
+		logger:   logger,
+		registry: registry,
+		events:   events,
+		alerts:   alerts,
+		silences: silences,
+		outbox:   outbox,
+		auth:     auth,
+		beats:    beats,
+		jobs:     jobs,
+		started:  time.Now(),
+	}
+}
+
+// Register adds all api routes to the mux with the scope they require
+func (r *Rest) Register(mux *http.ServeMux) {
+	handle := func(pattern, scope string, fn http.HandlerFunc) {
+		mux.HandleFunc(pattern, r.auth.Wrap(scope, fn))
+	}
+	mux.HandleFunc("POST /api/v1/login", r.auth.Login)
+	mux.HandleFunc("POST /api/v1/logout", r.auth.Logout)
+	handle("GET /api/v1/me", model.ScopeRead, r.auth.Me)
+	handle("GET /api/v1/silences", model.ScopeRead, r.listSilences)
+	handle("POST /api/v1/silences", model.ScopeOperate, r.createSilence)
+	handle("DELETE /api/v1/silences/{id}", model.ScopeOperate, r.deleteSilence)
+	handle("GET /api/v1/outbox", model.ScopeRead, r.listOutbox)
+	handle("GET /api/v1/events", model.ScopeRead, r.listEvents)
+	handle("GET /api/v1/events/{id}", model.ScopeRead, r.getEvent)
+	handle("POST /api/v1/events/{id}/ack", model.ScopeOperate, r.ackEvent)
+	handle("GET /api/v1/groups", model.ScopeRead, r.listGroups)
+	handle("GET /api/v1/nodes", model.ScopeRead, r.listNodes)
+	handle("GET /api/v1/nodes/{id}", model.ScopeRead, r.getNode)
+	handle("GET /api/v1/alerts", model.ScopeRead, r.listAlerts)
+	handle("GET /api/v1/summary", model.ScopeRead, r.getSummary)
+	handle("GET /api/v1/heartbeats", model.ScopeRead, r.listHeartbeats)
+	handle("POST /api/v1/heartbeats/{name}", model.ScopeOperate, r.pingHeartbeat)
+	handle("GET /api/v1/jobs", model.ScopeRead, r.listJobs)
+	handle("GET /api/v1/tokens", model.ScopeAdmin, r.listTokens)
+	handle("POST /api/v1/tokens", model.ScopeAdmin, r.createToken)
+	handle("DELETE /api/v1/tokens/{id}", model.ScopeAdmin, r.deleteToken)
+	handle("GET /api/v1/users", model.ScopeAdmin, r.listUsers)
+	handle("POST /api/v1/users", model.ScopeAdmin, r.createUser)
+	handle("PATCH /api/v1/users/{name}", model.ScopeAdmin, r.updateUser)
+	handle("DELETE /api/v1/users/{name}", model.ScopeAdmin, r.deleteUser)
+	mux.HandleFunc("OPTIONS /api/v1/", r.auth.Preflight)
+	mux.HandleFunc("/api/v1/", func(w http.ResponseWriter, req *http.Request) {
+		writeError(w, http.StatusNotFound, "unknown api "+req.Method+" "+req.URL.Path)
+	})
+}
+
+// silenceRequest is the body of a new silence, Duration can be used instead of EndsAt
+type silenceRequest struct {
+	model.Silence
+	Duration string `json:"duration"`
+}
+
+func (r *Rest) listSilences(w http.ResponseWriter, req *http.Request) {
+	now := time.Now()
+	type item struct {
+		*model.Silence
+		Active bool `json:"active"`
+	}
+	result := make([]item, 0)
+	for _, silence := range r.silences.List() {
+		// the callers scoped to labels only see the silences of their own nodes
+		if !r.silenceAllowed(req, silence) {
+			continue
+		}
+		result = append(result, item{Silence: silence, Active: silence.ActiveAt(now)})
+	}
+	writePage(w, req, result)
+}
+
+func (r *Rest) createSilence(w http.ResponseWriter, req *http.Request) {
+	var body silenceRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	silence := body.Silence
+	silence.ID = ""
+	silence.CreatedAt = time.Time{}
+	silence.CreatedBy = identityOf(req).Name
+	if !r.silenceAllowed(req, &silence) {
+		writeError(w, http.StatusForbidden, "the silence must select one of your nodes by its node id")
+		return
+	}
+	if body.Duration != "" {
+		duration, err := time.ParseDuration(body.Duration)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid duration: "+err.Error())
+			return
+		}
+		if silence.StartsAt.IsZero() {
+			silence.StartsAt = time.Now()
+		}
+		silence.EndsAt = silence.StartsAt.Add(duration)
+	}
+	if err := r.silences.Add(&silence); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	r.logger.Infof("silence %s created by %s from %s", silence.ID, silence.CreatedBy, req.RemoteAddr)
+	writeJSON(w, http.StatusCreated, silence)
+}
+
+func (r *Rest) deleteSilence(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	for _, silence := range r.silences.List() {
+		if silence.ID == id && !r.silenceAllowed(req, silence) {
+			writeError(w, http.StatusForbidden, "the silence selects nodes that are not yours")
+			return
+		}
+	}
+	if err := r.silences.Remove(id); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	r.logger.Infof("silence %s removed by %s from %s", id, identityOf(req).Name, req.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listOutbox returns the delivery state of the queued and recently sent messages,
+// the state can be filtered with ?state=queued or ?state=sent
+func (r *Rest) listOutbox(w http.ResponseWriter, req *http.Request) {
+	if len(identityOf(req).Labels) > 0 {
+		writeError(w, http.StatusForbidden, "the outbox isn't available to the users scoped to labels")
+		return
+	}
+	state := req.URL.Query().Get("state")
+	result := make([]notifier.OutboxItem, 0)
+	for _, item := range r.outbox.Items() {
+		if state == "" || item.State == state {
+			result = append(result, item)
+		}
+	}
+	writePage(w, req, result)
+}
+
+// listEvents returns the aggregated events, filtered with ?node=, ?kind=, ?state=firing|resolved,
+// the RFC3339 time range ?since= and ?until= and the node label selector ?labels=region=eu,role!=archive
+func (r *Rest) listEvents(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	selector, err := model.ParseLabelSelector(query.Get("labels"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := EventFilter{
+		Node:  query.Get("node"),
+		Kind:  query.Get("kind"),
+		State: query.Get("state"),
+	}
+	if filter.Since, filter.Until, err = parseTimeRange(query); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	labels := r.nodeLabels()
+	visible := r.visibleNodes(req)
+	result := make([]model.Event, 0)
+	for _, e := range r.events.List(filter) {
+		if visible(e.Node) && selector.Matches(labels(e.Node)) {
+			result = append(result, e)
+		}
+	}
+	writePage(w, req, result)
+}
+
+func (r *Rest) getEvent(w http.ResponseWriter, req *http.Request) {
+	event, ok := r.events.Get(req.PathValue("id"))
+	if !ok || !r.visibleNodes(req)(event.Node) {
+		writeError(w, http.StatusNotFound, "event not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, event)
+}
+
+// ackEvent acknowledges the firing event, the acknowledgement is cleared when the event fires again
+func (r *Rest) ackEvent(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	if event, ok := r.events.Get(id); !ok || !r.visibleNodes(req)(event.Node) {
+		writeError(w, http.StatusNotFound, "event not found")
+		return
+	}
+	event, err := r.events.Acknowledge(id, identityOf(req).Name)
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	r.logger.Infof("event %s acknowledged by %s from %s", id, event.AckedBy, req.RemoteAddr)
+	writeJSON(w, http.StatusOK, event)
+}
+
+// writeJSON writes the value as json response with the status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// apiError is the error of every failed api request
+type apiError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"` // snake case status text, e.g. not_found
+	Message string `json:"message"`
+}
+
+// writeError writes an error response like {"error": {"status": 404, "code": "not_found", "message": "..."}}
+func writeError(w http.ResponseWriter, status int, msg string) {
+	code := strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+	writeJSON(w, status, map[string]apiError{"error": {Status: status, Code: code, Message: msg}})
+}
+
+// page is the response of every list api
+type page[T any] struct {
+	Items  []T `json:"items"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// writePage writes the part of the items selected by ?limit= and ?offset=
+func writePage[T any](w http.ResponseWriter, req *http.Request, items []T) {
+	query := req.URL.Query()
+	limit, offset := defaultPageLimit, 0
+	if value := query.Get("limit"); value != "" {
+		v, err := strconv.Atoi(value)
+		if err != nil || v <= 0 || v > maxPageLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit %s, expected 1-%d", value, maxPageLimit))
+			return
+		}
+		limit = v
+	}
+	if value := query.Get("offset"); value != "" {
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 0 {
+			writeError(w, http.StatusBadRequest, "invalid offset "+value)
+			return
+		}
+		offset = v
+	}
+	result := page[T]{Items: make([]T, 0), Total: len(items), Limit: limit, Offset: offset}
+	if offset < len(items) {
+		result.Items = items[offset:min(offset+limit, len(items))]
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// parseTimeRange parses the RFC3339 times of ?since= and ?until=
+func parseTimeRange(query url.Values) (since, until time.Time, err error) {
+	for name, t := range map[string]*time.Time{"since": &since, "until": &until} {
+		if value := query.Get(name); value != "" {
+			if *t, err = time.Parse(time.RFC3339, value); err != nil {
+				return since, until, fmt.Errorf("invalid %s: %s", name, err)
+			}
+		}
+	}
+	return since, until, nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"encoding/json"
+	"ethstats/server/app/model"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// visibleNodes returns whether the caller can see the node, the callers scoped to labels only see the matching nodes
+func (r *Rest) visibleNodes(req *http.Request) func(id string) bool {
+	selector := identityOf(req).Labels
+	if len(selector) == 0 {
+		return func(string) bool { return true }
+	}
+	labels := r.nodeLabels()
+	return func(id string) bool {
+		return selector.Matches(labels(id))
+	}
+}
+
+// silenceAllowed reports whether the caller can create or remove the silence,
+// the callers scoped to labels can only silence one of their nodes by its exact id
+func (r *Rest) silenceAllowed(req *http.Request, silence *model.Silence) bool {
+	if len(identityOf(req).Labels) == 0 {
+		return true
+	}
+	if silence.NodeID == "" || strings.ContainsAny(silence.NodeID, `*?[\`) {
+		return false
+	}
+	return r.visibleNodes(req)(silence.NodeID)
+}
+
+// requireUnscoped rejects the callers scoped to labels. The tokens have no labels and a user could be given other labels,
+// so managing them would let the caller leave its scope
+func requireUnscoped(w http.ResponseWriter, req *http.Request) bool {
+	if len(identityOf(req).Labels) > 0 {
+		writeError(w, http.StatusForbidden, "the callers scoped to labels can't manage tokens and users")
+		return false
+	}
+	return true
+}
+
+// tokenRequest is the body of a new token
+type tokenRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+func (r *Rest) listTokens(w http.ResponseWriter, req *http.Request) {
+	if !requireUnscoped(w, req) {
+		return
+	}
+	type item struct {
+		ID        string    `json:"id"`
+		Name      string    `json:"name"`
+		Scope     string    `json:"scope"`
+		CreatedAt time.Time `json:"createdAt"`
+		CreatedBy string    `json:"createdBy,omitempty"`
+	}
+	result := make([]item, 0)
+	for _, t := range r.auth.tokens.List() {
+		result = append(result, item{ID: t.ID, Name: t.Name, Scope: t.Scope, CreatedAt: t.CreatedAt, CreatedBy: t.CreatedBy})
+	}
+	writePage(w, req, result)
+}
+
+// createToken returns the secret of the new token, it can't be read again later
+func (r *Rest) createToken(w http.ResponseWriter, req *http.Request) {
+	if !requireUnscoped(w, req) {
+		return
+	}
+	var body tokenRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	token, secret, err := r.auth.tokens.Add(body.Name, body.Scope, identityOf(req).Name)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	r.logger.Infof("token %s created by %s from %s", token.Name, token.CreatedBy, req.RemoteAddr)
+	writeJSON(w, http.StatusCreated, map[string]string{"id": token.ID, "name": token.Name, "scope": token.Scope, "token": secret})
+}
+
+func (r *Rest) deleteToken(w http.ResponseWriter, req *http.Request) {
+	if !requireUnscoped(w, req) {
+		return
+	}
+	id := req.PathValue("id")
+	if r.auth.isLastAdmin(id, "") {
+		writeError(w, http.StatusConflict, "can't remove the last admin token or user")
+		return
+	}
+	if err := r.auth.tokens.Remove(id); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	r.logger.Infof("token %s removed by %s from %s", id, identityOf(req).Name, req.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userView is a user without the password hash
+type userView struct {
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Labels    string    `json:"labels,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// userRequest is the body of a new or changed user, the nil fields are not changed
+type userRequest struct {
+	Username string  `json:"username"`
+	Password *string `json:"password"`
+	Role     *string `json:"role"`
+	Labels   *string `json:"labels"`
+}
+
+func (r *Rest) listUsers(w http.ResponseWriter, req *http.Request) {
+	if !requireUnscoped(w, req) {
+		return
+	}
+	result := make([]userView, 0)
+	for _, u := range r.auth.users.List() {
+		result = append(result, userView{Username: u.Username, Role: u.Role, Labels: u.Labels, CreatedAt: u.CreatedAt})
+	}
+	writePage(w, req, result)
+}
+
+func (r *Rest) createUser(w http.ResponseWriter, req *http.Request) {
+	if !requireUnscoped(w, req) {
+		return
+	}
+	var body userRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	password, role, labels := "", model.RoleViewer, ""
+	if body.Password != nil {
+		password = *body.Password
+	}
+	if body.Role != nil {
+		role = *body.Role
+	}
+	if body.Labels != nil {
+		labels = *body.Labels
+	}
+	user, err := r.auth.users.Add(body.Username, password, role, labels)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	r.logger.Infof("user %s created by %s from %s", user.Username, identityOf(req).Name, req.RemoteAddr)
+	writeJSON(w, http.StatusCreated, userView{Username: user.Username, Role: user.Role, Labels: user.Labels, CreatedAt: user.CreatedAt})
+}
+
+func (r *Rest) updateUser(w http.ResponseWriter, req *http.Request) {
+	if !requireUnscoped(w, req) {
+		return
+	}
+	name := req.PathValue("name")
+	var body userRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if _, ok := r.auth.users.Get(name); !ok {
+		writeError(w, http.StatusNotFound, "user not found: "+name)
+		return
+	}
+	if body.Role != nil {
+		if scope, _ := model.RoleScope(*body.Role); scope != model.ScopeAdmin && r.auth.isLastAdmin("", name) {
+			writeError(w, http.StatusConflict, "can't take the admin role of the last admin token or user")
+			return
+		}
+	}
+	if err := r.auth.users.Update(name, UserUpdate{Password: body.Password, Role: body.Role, Labels: body.Labels}); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, _ := r.auth.users.Get(name)
+	r.logger.Infof("user %s changed by %s from %s", name, identityOf(req).Name, req.RemoteAddr)
+	writeJSON(w, http.StatusOK, userView{Username: user.Username, Role: user.Role, Labels: user.Labels, CreatedAt: user.CreatedAt})
+}
+
+func (r *Rest) deleteUser(w http.ResponseWriter, req *http.Request) {
+	if !requireUnscoped(w, req) {
+		return
+	}
+	name := req.PathValue("name")
+	if r.auth.isLastAdmin("", name) {
+		writeError(w, http.StatusConflict, "can't remove the last admin token or user")
+		return
+	}
+	if err := r.auth.users.Remove(name); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	r.logger.Infof("user %s removed by %s from %s", name, identityOf(req).Name, req.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
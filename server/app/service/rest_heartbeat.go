@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"ethstats/server/app/model"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// listHeartbeats returns the state of the heartbeat checks
+func (r *Rest) listHeartbeats(w http.ResponseWriter, req *http.Request) {
+	visible := r.visibleNodes(req)
+	result := make([]model.Heartbeat, 0)
+	for _, check := range r.beats.List() {
+		if visible(check.Node) {
+			result = append(result, check)
+		}
+	}
+	writePage(w, req, result)
+}
+
+// pingHeartbeat records a heartbeat of the check, the status ?status=success|fail and the message ?msg= are optional,
+// the request body is used as the message when ?msg= is missing, e.g. the output of the job
+func (r *Rest) pingHeartbeat(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("name")
+	if len(identityOf(req).Labels) > 0 {
+		if check, ok := r.heartbeat(name); !ok || !r.visibleNodes(req)(check.Node) {
+			writeError(w, http.StatusNotFound, ErrUnknownHeartbeat.Error()+": "+name)
+			return
+		}
+	}
+	query := req.URL.Query()
+	message := query.Get("msg")
+	if message == "" && req.Body != nil {
+		body, _ := io.ReadAll(io.LimitReader(req.Body, maxHeartbeatMessage))
+		message = strings.TrimSpace(string(body))
+	}
+	err := r.beats.Ping(name, query.Get("node"), query.Get("status"), message)
+	if errors.Is(err, ErrUnknownHeartbeat) {
+		writeError(w, http.StatusNotFound, err.Error()+": "+name)
+		return
+	}
+	if errors.Is(err, ErrHeartbeatNode) {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	check, _ := r.heartbeat(name)
+	writeJSON(w, http.StatusOK, check)
+}
+
+func (r *Rest) heartbeat(name string) (model.Heartbeat, bool) {
+	for _, check := range r.beats.List() {
+		if check.Name == name {
+			return check, true
+		}
+	}
+	return model.Heartbeat{}, false
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"ethstats/server/app/model"
+	"net/http"
+)
+
+// listJobs returns the job runs, the newest first, filtered with ?name=, ?node= and ?state=running|succeeded|failed
+func (r *Rest) listJobs(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	visible := r.visibleNodes(req)
+	result := make([]model.JobRun, 0)
+	for _, run := range r.jobs.List(JobFilter{Name: query.Get("name"), Node: query.Get("node"), State: query.Get("state")}) {
+		if visible(run.Node) {
+			result = append(result, run)
+		}
+	}
+	writePage(w, req, result)
+}
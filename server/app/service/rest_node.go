@@ -0,0 +1,272 @@
+package service
+
+import (
+	"ethstats/server/app/model"
+	"ethstats/server/app/notifier"
+	"ethstats/server/config"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// nodeView is a node of the nodes api
+type nodeView struct {
+	model.NodeState
+	Tags   []string `json:"tags"`
+	Firing int      `json:"firing"` // count of the firing events
+}
+
+// nodeDetail is the full state of a node
+type nodeDetail struct {
+	nodeView
+	Processes []process     `json:"processes"`
+	Events    []model.Event `json:"events"` // the firing events
+}
+
+type process struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+}
+
+// listNodes returns the nodes sorted by id, filtered with the node id pattern ?node=geth-*, ?online=true|false,
+// ?tag= and the label selector ?labels=region=eu,role!=archive
+func (r *Rest) listNodes(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	selector, err := model.ParseLabelSelector(query.Get("labels"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	pattern := query.Get("node")
+	if _, err = path.Match(pattern, ""); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid node pattern: "+err.Error())
+		return
+	}
+	var online *bool
+	if value := query.Get("online"); value != "" {
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid online: "+value)
+			return
+		}
+		online = &v
+	}
+	tag := query.Get("tag")
+
+	firing := r.firingCount()
+	visible := r.visibleNodes(req)
+	result := make([]nodeView, 0)
+	for _, node := range r.registry.List() {
+		if ok, _ := path.Match(pattern, node.ID); pattern != "" && !ok || !visible(node.ID) {
+			continue
+		}
+		if online != nil && node.Online != *online || !selector.Matches(node.Labels) {
+			continue
+		}
+		view := nodeView{NodeState: node, Tags: config.NodeTags(node.ID), Firing: firing[node.ID]}
+		if tag != "" && !containsString(view.Tags, tag) {
+			continue
+		}
+		result = append(result, view)
+	}
+	writePage(w, req, result)
+}
+
+func (r *Rest) getNode(w http.ResponseWriter, req *http.Request) {
+	node, ok := r.registry.Get(req.PathValue("id"))
+	if !ok || !r.visibleNodes(req)(node.ID) {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+	events := r.events.List(EventFilter{Node: node.ID, State: model.EventFiring})
+	detail := nodeDetail{
+		nodeView:  nodeView{NodeState: node, Tags: config.NodeTags(node.ID), Firing: len(events)},
+		Processes: make([]process, 0, len(node.Procs)),
+		Events:    events,
+	}
+	for name, running := range node.Procs {
+		detail.Processes = append(detail.Processes, process{Name: name, Running: running})
+	}
+	sort.Slice(detail.Processes, func(i, j int) bool { return detail.Processes[i].Name < detail.Processes[j].Name })
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// listAlerts returns the latest sent alerts, the newest first, filtered with ?node=, ?name=, ?severity=
+// and the RFC3339 time range ?since= and ?until=
+func (r *Rest) listAlerts(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	filter := AlertFilter{
+		Node:     query.Get("node"),
+		Name:     query.Get("name"),
+		Severity: query.Get("severity"),
+	}
+	var err error
+	if filter.Since, filter.Until, err = parseTimeRange(query); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	visible := r.visibleNodes(req)
+	result := make([]model.AlertRecord, 0)
+	for _, record := range r.alerts.List(filter) {
+		if visible(record.NodeID) {
+			result = append(result, record)
+		}
+	}
+	writePage(w, req, result)
+}
+
+// summary is the fleet summary of the summary api
+type summary struct {
+	Server    string    `json:"server"`
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"startedAt"`
+	Nodes     struct {
+		Total    int      `json:"total"`
+		Online   int      `json:"online"`
+		Offline  int      `json:"offline"`
+		Expected int      `json:"expected"`
+		Missing  []string `json:"missing"` // expected nodes that never logged in
+	} `json:"nodes"`
+	Events struct {
+		Firing int            `json:"firing"`
+		Kinds  map[string]int `json:"kinds"` // count of the firing events by kind
+	} `json:"events"`
+	Silences int `json:"silences"` // count of the active silences visible to the caller
+	Outbox   int `json:"outbox"`   // count of the queued messages
+}
+
+func (r *Rest) getSummary(w http.ResponseWriter, req *http.Request) {
+	result := summary{
+		Server:    config.ApplicationConfig.Name,
+		Version:   config.ApplicationConfig.Version,
+		StartedAt: r.started,
+	}
+	visible := r.visibleNodes(req)
+	known := make(map[string]bool)
+	for _, node := range r.registry.List() {
+		if !visible(node.ID) {
+			continue
+		}
+		known[node.ID] = true
+		if node.Online {
+			result.Nodes.Online++
+		} else {
+			result.Nodes.Offline++
+		}
+	}
+	result.Nodes.Missing = make([]string, 0)
+	for _, id := range config.DigestConfig.ExpectedNodes {
+		if !visible(id) {
+			continue
+		}
+		result.Nodes.Expected++
+		if !known[id] {
+			result.Nodes.Missing = append(result.Nodes.Missing, id)
+		}
+	}
+	result.Nodes.Offline += len(result.Nodes.Missing)
+	result.Nodes.Total = len(known) + len(result.Nodes.Missing)
+
+	result.Events.Kinds = make(map[string]int)
+	for _, e := range r.events.List(EventFilter{State: model.EventFiring}) {
+		if !visible(e.Node) {
+			continue
+		}
+		result.Events.Firing++
+		result.Events.Kinds[e.Kind]++
+	}
+	now := time.Now()
+	for _, silence := range r.silences.List() {
+		if silence.ActiveAt(now) && r.silenceAllowed(req, silence) {
+			result.Silences++
+		}
+	}
+	for _, item := range r.outbox.Items() {
+		if item.State == notifier.StateQueued && len(identityOf(req).Labels) == 0 {
+			result.Outbox++
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// firingCount returns the count of the firing events of every node
+func (r *Rest) firingCount() map[string]int {
+	result := make(map[string]int)
+	for _, e := range r.events.List(EventFilter{State: model.EventFiring}) {
+		result[e.Node]++
+	}
+	return result
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeGroup is the state of the nodes with the same label value
+type nodeGroup struct {
+	Value   string   `json:"value"`
+	Total   int      `json:"total"`
+	Online  int      `json:"online"`
+	Offline int      `json:"offline"`
+	Nodes   []string `json:"nodes"`
+}
+
+// listGroups groups the nodes by the value of the label ?by=region, the nodes can be filtered with ?labels=
+func (r *Rest) listGroups(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	key := query.Get("by")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "the label key of by is required")
+		return
+	}
+	selector, err := model.ParseLabelSelector(query.Get("labels"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	visible := r.visibleNodes(req)
+	index := make(map[string]int)
+	result := make([]nodeGroup, 0)
+	for _, node := range r.registry.List() {
+		if !selector.Matches(node.Labels) || !visible(node.ID) {
+			continue
+		}
+		value := node.Labels[key]
+		i, ok := index[value]
+		if !ok {
+			i = len(result)
+			index[value] = i
+			result = append(result, nodeGroup{Value: value, Nodes: make([]string, 0)})
+		}
+		result[i].Total++
+		if node.Online {
+			result[i].Online++
+		} else {
+			result[i].Offline++
+		}
+		result[i].Nodes = append(result[i].Nodes, node.ID)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Value < result[j].Value })
+	writePage(w, req, result)
+}
+
+// nodeLabels returns a lookup of the node labels, the nodes that never logged in have the labels of the config
+func (r *Rest) nodeLabels() func(id string) map[string]string {
+	labels := make(map[string]map[string]string)
+	for _, node := range r.registry.List() {
+		labels[node.ID] = node.Labels
+	}
+	return func(id string) map[string]string {
+		if l, ok := labels[id]; ok {
+			return l
+		}
+		return config.NodeLabels(id, nil)
+	}
+}
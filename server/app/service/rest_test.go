@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"ethstats/server/app/model"
+	"ethstats/server/app/notifier"
+	"ethstats/server/config"
+	"github.com/bitxx/logger"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSummaryOfScopedCaller(t *testing.T) {
+	dir := t.TempDir()
+	config.EventConfig.Path = filepath.Join(dir, "events.json")
+	events, err := NewEventStore(logger.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	silences, err := NewSilenceStore(filepath.Join(dir, "silences.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+	registry.Login("geth-eu", "127.0.0.1:1", map[string]string{"region": "eu-west"}, nil)
+	registry.Login("geth-us", "127.0.0.1:2", map[string]string{"region": "us-east"}, nil)
+	for _, node := range []string{"geth-eu", "geth-us", "geth-*"} {
+		if err = silences.Add(&model.Silence{NodeID: node, EndsAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config.OutboxConfig.Path = filepath.Join(dir, "outbox")
+	outbox, err := notifier.NewOutbox(logger.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRest(registry, events, NewAlertLog(), silences, outbox, nil, nil, nil, logger.NewLogger())
+
+	selector, _ := model.ParseLabelSelector("region=eu-*")
+	cases := []struct {
+		name     string
+		caller   *identity
+		nodes    int
+		silences int
+	}{
+		{"unscoped", anonymous, 2, 3},
+		{"scoped", &identity{Name: "alice", Kind: "user", Scope: model.ScopeRead, Labels: selector}, 1, 1},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/summary", nil)
+		req = req.WithContext(context.WithValue(req.Context(), identityKey{}, c.caller))
+		w := httptest.NewRecorder()
+		r.getSummary(w, req)
+		var result summary
+		if err = json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Nodes.Total != c.nodes || result.Silences != c.silences {
+			t.Errorf("%s: expected %d nodes and %d silences, got %d and %d", c.name, c.nodes, c.silences,
+				result.Nodes.Total, result.Silences)
+		}
+	}
+}
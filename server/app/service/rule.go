@@ -0,0 +1,170 @@
+package service
+
+import (
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"fmt"
+	"github.com/bitxx/logger/logbase"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AlertMetricRule = "metric-rule" //event kind and alert name of a metric rule, the subject is the rule name
+
+	TagMetricRule = "metric rule" //use for tag event
+)
+
+// metricRule is a rule of the rules config with the parsed label selector
+type metricRule struct {
+	config.Rule
+	labels model.LabelSelector
+}
+
+// RuleEngine evaluates the metric rules after every metrics update of a node,
+// an event fires when the condition holds for the duration of the rule and resolves when it doesn't hold
+type RuleEngine struct {
+	logger   *logbase.Helper
+	channel  *model.Channel
+	events   *EventStore
+	silences *SilenceStore
+	rules    []*metricRule
+	lock     sync.Mutex
+	pending  map[string]time.Time // event id => the time the condition started to hold
+}
+
+// NewRuleEngine validates the rules and evaluates them on the metrics updates of the registry
+func NewRuleEngine(items []config.Rule, channel *model.Channel, registry *Registry, events *EventStore,
+	silences *SilenceStore, logger *logbase.Helper) (*RuleEngine, error) {
+	e := &RuleEngine{
+		logger:   logger,
+		channel:  channel,
+		events:   events,
+		silences: silences,
+		pending:  make(map[string]time.Time),
+	}
+	names := make(map[string]bool)
+	for i, item := range items {
+		if item.Name == "" {
+			return nil, fmt.Errorf("rule #%d: the name can't be empty", i+1)
+		}
+		if names[item.Name] {
+			return nil, fmt.Errorf("rule [%s]: duplicate name", item.Name)
+		}
+		names[item.Name] = true
+		if item.Metric == "" {
+			return nil, fmt.Errorf("rule [%s]: the metric can't be empty", item.Name)
+		}
+		if !model.ValidRuleOp(item.Op) {
+			return nil, fmt.Errorf("rule [%s]: unknown op %s, expected >, >=, <, <=, == or !=", item.Name, item.Op)
+		}
+		for _, pattern := range item.Nodes {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("rule [%s]: invalid node pattern %s", item.Name, pattern)
+			}
+		}
+		item.Severity = strings.ToLower(item.Severity)
+		switch item.Severity {
+		case "":
+			item.Severity = model.SeverityWarning
+		case model.SeverityCritical, model.SeverityWarning, model.SeverityInfo:
+		default:
+			return nil, fmt.Errorf("rule [%s]: unknown severity %s", item.Name, item.Severity)
+		}
+		e.rules = append(e.rules, &metricRule{Rule: item, labels: model.SelectorFromMap(item.Labels)})
+	}
+	if len(e.rules) > 0 {
+		registry.Watch(func(topic string, node model.NodeState) {
+			if topic == model.TopicMetrics && node.Online {
+				e.evaluate(node)
+			}
+		})
+	}
+	return e, nil
+}
+
+// evaluate checks all rules of the node, a missing metric doesn't match
+func (e *RuleEngine) evaluate(node model.NodeState) {
+	now := time.Now()
+	for _, rule := range e.rules {
+		if !rule.matchesNode(node) {
+			continue
+		}
+		id := EventID(node.ID, AlertMetricRule, rule.Name)
+		value, ok := node.Custom[rule.Metric]
+		if !ok {
+			value, ok = node.Stats[rule.Metric]
+		}
+		if !ok || !model.RuleMatches(rule.Op, value, rule.Value) {
+			e.lock.Lock()
+			delete(e.pending, id)
+			e.lock.Unlock()
+			e.events.Resolve(node.ID, AlertMetricRule, rule.Name)
+			continue
+		}
+
+		e.lock.Lock()
+		since, ok := e.pending[id]
+		if !ok {
+			since = now
+			e.pending[id] = now
+		}
+		e.lock.Unlock()
+		if now.Sub(since) < time.Duration(rule.For)*time.Second {
+			continue
+		}
+		content := fmt.Sprintf("rule %s: %s = %s %s %s", rule.Name, rule.Metric, formatRuleValue(value), rule.Op,
+			formatRuleValue(rule.Value))
+		if rule.For > 0 {
+			content += fmt.Sprintf(" for %s", now.Sub(since).Truncate(time.Second))
+		}
+		e.record(node, rule, content)
+	}
+}
+
+// record records an occurrence of the rule event, alert when the event starts firing and isn't silenced.
+// The silences match the rule name with the proc matcher
+func (e *RuleEngine) record(node model.NodeState, rule *metricRule, content string) {
+	target := model.SilenceTarget{NodeID: node.ID, Tag: TagMetricRule, Proc: rule.Name, Alert: AlertMetricRule}
+	silenced := ""
+	if silence := e.silences.Silenced(target, time.Now()); silence != nil {
+		silenced = silence.ID
+	}
+	_, firing := e.events.Record(node.ID, node.Addr, AlertMetricRule, TagMetricRule, rule.Name, content, silenced)
+	if !firing || silenced != "" {
+		return
+	}
+	queueAlert(e.channel, e.logger, &model.Alert{
+		Name:       AlertMetricRule,
+		NodeID:     node.ID,
+		NodeTags:   config.NodeTags(node.ID),
+		NodeLabels: node.Labels,
+		Addr:       node.Addr,
+		Tag:        TagMetricRule,
+		Severity:   rule.Severity,
+		Content:    content,
+		Time:       time.Now(),
+	})
+}
+
+func (r *metricRule) matchesNode(node model.NodeState) bool {
+	if len(r.Nodes) > 0 {
+		matched := false
+		for _, pattern := range r.Nodes {
+			if ok, _ := path.Match(pattern, node.ID); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return r.labels.Matches(node.Labels)
+}
+
+func formatRuleValue(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.3f", v), "0"), ".")
+}
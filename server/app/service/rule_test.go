@@ -0,0 +1,65 @@
+package service
+
+import (
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"github.com/bitxx/logger"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRuleSilencedByName(t *testing.T) {
+	dir := t.TempDir()
+	config.EventConfig.Path = filepath.Join(dir, "events.json")
+	log := logger.NewLogger()
+	events, err := NewEventStore(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	silences, err := NewSilenceStore(filepath.Join(dir, "silences.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = silences.Add(&model.Silence{NodeID: "geth-01", Proc: "disk-full", Alert: AlertMetricRule,
+		EndsAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel := &model.Channel{Alerts: make(chan *model.Alert, 64), LoginIDs: make(map[string]string)}
+	rules := []config.Rule{
+		{Name: "disk-full", Metric: "disk_used_percent", Op: ">", Value: 90},
+		{Name: "api-errors", Metric: "api.errors", Op: ">", Value: 100},
+	}
+	e, err := NewRuleEngine(rules, channel, NewRegistry(), events, silences, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.evaluate(model.NodeState{ID: "geth-01", Online: true, Stats: map[string]float64{"disk_used_percent": 95},
+		Custom: map[string]float64{"api.errors": 500}})
+
+	for _, c := range []struct {
+		rule     string
+		silenced bool
+	}{
+		{"disk-full", true},
+		{"api-errors", false},
+	} {
+		list := events.List(EventFilter{Node: "geth-01", Kind: AlertMetricRule, State: model.EventFiring})
+		found := false
+		for _, event := range list {
+			if event.Subject == c.rule {
+				found = true
+				if (event.Silenced != "") != c.silenced {
+					t.Errorf("%s: expected silenced=%t, got %q", c.rule, c.silenced, event.Silenced)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: expected a firing event", c.rule)
+		}
+	}
+	if len(channel.Alerts) != 1 {
+		t.Errorf("expected only the alert of api-errors, got %d alerts", len(channel.Alerts))
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultSilencePath = "files/silences.json"
+
+	windowIDPrefix = "window:"
+)
+
+// SilenceStore keeps the silences in a json file shared with the silence subcommand
+type SilenceStore struct {
+	file     jsonFile
+	lock     sync.Mutex
+	silences []*model.Silence
+}
+
+// NewSilenceStore creates a store backed by the given file, the file is created on first write
+func NewSilenceStore(path string) (*SilenceStore, error) {
+	if path == "" {
+		path = DefaultSilencePath
+	}
+	for _, window := range windowSilences() {
+		if err := validateSilence(window); err != nil {
+			return nil, fmt.Errorf("maintenance window [%s]: %s", strings.TrimPrefix(window.ID, windowIDPrefix), err)
+		}
+	}
+	s := &SilenceStore{file: jsonFile{path: path, perm: 0644}}
+	if err := s.file.Load(&s.silences); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add saves a new silence, an id is generated when the silence has none
+func (s *SilenceStore) Add(silence *model.Silence) error {
+	if err := validateSilence(silence); err != nil {
+		return err
+	}
+	if silence.ID == "" {
+		silence.ID = NewID()
+	}
+	if silence.CreatedAt.IsZero() {
+		silence.CreatedAt = time.Now()
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Update(&s.silences, func() error {
+		s.silences = append(s.silences, silence)
+		return nil
+	})
+}
+
+// Remove deletes the silence with the given id
+func (s *SilenceStore) Remove(id string) error {
+	if strings.HasPrefix(id, windowIDPrefix) {
+		return errors.New("maintenance windows are declared in settings.yml and can't be removed")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Update(&s.silences, func() error {
+		for i, silence := range s.silences {
+			if silence.ID == id {
+				s.silences = append(s.silences[:i], s.silences[i+1:]...)
+				return nil
+			}
+		}
+		return errors.New("silence not found: " + id)
+	})
+}
+
+// List returns the persisted silences followed by the maintenance windows from settings.yml
+func (s *SilenceStore) List() []*model.Silence {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_ = s.file.Load(&s.silences)
+	result := make([]*model.Silence, 0, len(s.silences)+len(config.SilenceConfig.Windows))
+	result = append(result, s.silences...)
+	result = append(result, windowSilences()...)
+	return result
+}
+
+// Silenced returns the first silence active at the given time that matches the target, or nil
+func (s *SilenceStore) Silenced(target model.SilenceTarget, t time.Time) *model.Silence {
+	for _, silence := range s.List() {
+		if silence.ActiveAt(t) && silence.Matches(target) {
+			return silence
+		}
+	}
+	return nil
+}
+
+// validateSilence checks the matchers and the times of the silence. A silence needs at least one matcher,
+// so a typo can't mute the whole fleet, "*" matches all nodes explicitly
+func validateSilence(silence *model.Silence) error {
+	matchers := []string{silence.NodeID, silence.Tag, silence.Proc, silence.Alert}
+	if strings.Join(matchers, "") == "" {
+		return errors.New("a silence needs at least one of the node, tag, proc and alert matchers, use node * to silence all nodes")
+	}
+	for _, pattern := range matchers {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.New("invalid pattern " + pattern)
+		}
+	}
+	if silence.IsRecurring() {
+		if _, err := time.Parse("15:04", silence.DailyStart); err != nil {
+			return errors.New("invalid daily start, use the format 15:04")
+		}
+		if _, err := time.Parse("15:04", silence.DailyEnd); err != nil {
+			return errors.New("invalid daily end, use the format 15:04")
+		}
+		if !model.ValidWeekdays(silence.Weekdays) {
+			return errors.New("invalid weekdays " + silence.Weekdays + ", e.g. sun,sat")
+		}
+	} else if silence.DailyStart != "" || silence.DailyEnd != "" {
+		return errors.New("a recurring silence needs both the daily start and the daily end")
+	} else if silence.EndsAt.IsZero() {
+		return errors.New("the end time of a silence can't be empty")
+	}
+	if !silence.StartsAt.IsZero() && !silence.EndsAt.IsZero() && !silence.EndsAt.After(silence.StartsAt) {
+		return errors.New("the end time must be after the start time")
+	}
+	if silence.Timezone != "" {
+		if _, err := time.LoadLocation(silence.Timezone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// windowSilences converts the maintenance windows of settings.yml to silences
+func windowSilences() []*model.Silence {
+	result := make([]*model.Silence, 0, len(config.SilenceConfig.Windows))
+	for _, w := range config.SilenceConfig.Windows {
+		result = append(result, &model.Silence{
+			ID:         windowIDPrefix + w.Name,
+			NodeID:     w.NodeID,
+			Tag:        w.Tag,
+			Proc:       w.Proc,
+			Alert:      w.Alert,
+			Weekdays:   w.Weekdays,
+			DailyStart: w.Start,
+			DailyEnd:   w.End,
+			Timezone:   w.Timezone,
+			Comment:    "maintenance window from settings.yml",
+		})
+	}
+	return result
+}
+
+// NewID returns a random hex id
+func NewID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"ethstats/server/app/model"
+	"ethstats/server/config"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSilenceValidation(t *testing.T) {
+	s, err := NewSilenceStore(filepath.Join(t.TempDir(), "silences.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := time.Now().Add(time.Hour)
+	cases := []struct {
+		name    string
+		silence model.Silence
+		ok      bool
+	}{
+		{"node", model.Silence{NodeID: "geth-01", EndsAt: end}, true},
+		{"all nodes", model.Silence{NodeID: "*", EndsAt: end}, true},
+		{"alert", model.Silence{Alert: AlertProcDown, EndsAt: end}, true},
+		{"no matcher", model.Silence{EndsAt: end, Comment: "upgrade"}, false},
+		{"invalid pattern", model.Silence{NodeID: "geth-[", EndsAt: end}, false},
+		{"no end", model.Silence{NodeID: "geth-01"}, false},
+		{"window", model.Silence{Proc: "geth", Weekdays: "sun", DailyStart: "02:00", DailyEnd: "04:00"}, true},
+		{"window without end", model.Silence{Proc: "geth", DailyStart: "02:00"}, false},
+		{"window with bad time", model.Silence{Proc: "geth", DailyStart: "2am", DailyEnd: "04:00"}, false},
+		{"window with bad weekdays", model.Silence{Proc: "geth", Weekdays: "weekend", DailyStart: "02:00", DailyEnd: "04:00"}, false},
+		{"window with bad time zone", model.Silence{Proc: "geth", DailyStart: "02:00", DailyEnd: "04:00", Timezone: "Mars/Base"}, false},
+	}
+	for _, c := range cases {
+		silence := c.silence
+		err := s.Add(&silence)
+		if c.ok && err != nil {
+			t.Errorf("%s: expected the silence to be added, got %s", c.name, err)
+		}
+		if !c.ok && err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
+
+func TestSilenceWindowsOfConfig(t *testing.T) {
+	saved := config.SilenceConfig.Windows
+	t.Cleanup(func() { config.SilenceConfig.Windows = saved })
+	path := filepath.Join(t.TempDir(), "silences.json")
+
+	config.SilenceConfig.Windows = []config.Window{{Name: "upgrade", NodeID: "geth-*", Start: "02:00", End: "04:00",
+		Timezone: "Asia/Shanghai"}}
+	if _, err := NewSilenceStore(path); err != nil {
+		t.Fatalf("expected a valid window, got %s", err)
+	}
+	for _, w := range []config.Window{
+		{Name: "no matcher", Start: "02:00", End: "04:00"},
+		{Name: "bad start", NodeID: "geth-*", Start: "25:00", End: "04:00"},
+		{Name: "no end", NodeID: "geth-*", Start: "02:00"},
+		{Name: "bad time zone", NodeID: "geth-*", Start: "02:00", End: "04:00", Timezone: "Asia/Nowhere"},
+	} {
+		config.SilenceConfig.Windows = []config.Window{w}
+		if _, err := NewSilenceStore(path); err == nil {
+			t.Errorf("%s: expected an error", w.Name)
+		}
+	}
+}
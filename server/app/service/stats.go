@@ -0,0 +1,58 @@
+package service
+
+import "sync"
+
+// sources of the authentication failures
+const (
+	AuthSourceNode = "node" // a client logged in with an invalid secret
+	AuthSourceApi  = "api"  // an api request with an invalid token, session or password
+)
+
+// Stats counts the messages and the authentication failures of the server
+type Stats struct {
+	lock         sync.Mutex
+	messages     map[string]uint64 // message type => count
+	authFailures map[string]uint64 // source => count
+}
+
+// NewStats creates empty stats
+func NewStats() *Stats {
+	return &Stats{
+		messages:     make(map[string]uint64),
+		authFailures: make(map[string]uint64),
+	}
+}
+
+// Message counts a message received from a node
+func (s *Stats) Message(msgType string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.messages[msgType]++
+}
+
+// AuthFailure counts an authentication failure
+func (s *Stats) AuthFailure(source string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.authFailures[source]++
+}
+
+// Messages returns a copy of the message counts by type
+func (s *Stats) Messages() map[string]uint64 {
+	return s.copy(s.messages)
+}
+
+// AuthFailures returns a copy of the authentication failure counts by source
+func (s *Stats) AuthFailures() map[string]uint64 {
+	return s.copy(s.authFailures)
+}
+
+func (s *Stats) copy(m map[string]uint64) map[string]uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make(map[string]uint64, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"ethstats/server/app/model"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTokenPath = "files/tokens.json"
+
+	tokenPrefix = "osm_"
+)
+
+// TokenStore keeps the api tokens in a json file shared with the token subcommand
+type TokenStore struct {
+	file   jsonFile
+	lock   sync.Mutex
+	tokens []*model.Token
+}
+
+// NewTokenStore creates a store backed by the given file, the file is created on first write
+func NewTokenStore(path string) (*TokenStore, error) {
+	if path == "" {
+		path = DefaultTokenPath
+	}
+	s := &TokenStore{file: jsonFile{path: path, perm: 0600}}
+	if err := s.file.Load(&s.tokens); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add creates a token and returns its secret, the secret can't be read again later
+func (s *TokenStore) Add(name, scope, createdBy string) (*model.Token, string, error) {
+	if name == "" {
+		return nil, "", errors.New("the name of a token can't be empty")
+	}
+	if !model.ValidScope(scope) {
+		return nil, "", errors.New("unknown scope " + scope)
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	secret := tokenPrefix + hex.EncodeToString(b)
+	token := &model.Token{
+		ID:        NewID(),
+		Name:      name,
+		Scope:     scope,
+		Hash:      hashToken(secret),
+		CreatedAt: time.Now(),
+		CreatedBy: createdBy,
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.file.Update(&s.tokens, func() error {
+		for _, t := range s.tokens {
+			if t.Name == name {
+				return errors.New("token name is repeated: " + name)
+			}
+		}
+		s.tokens = append(s.tokens, token)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return token, secret, nil
+}
+
+// Remove deletes the token with the given id or name
+func (s *TokenStore) Remove(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Update(&s.tokens, func() error {
+		for i, t := range s.tokens {
+			if t.ID == id || t.Name == id {
+				s.tokens = append(s.tokens[:i], s.tokens[i+1:]...)
+				return nil
+			}
+		}
+		return errors.New("token not found: " + id)
+	})
+}
+
+// List returns all tokens
+func (s *TokenStore) List() []*model.Token {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_ = s.file.Load(&s.tokens)
+	return append([]*model.Token(nil), s.tokens...)
+}
+
+// Lookup returns the token of the secret, the hashes are compared in constant time
+func (s *TokenStore) Lookup(secret string) (*model.Token, bool) {
+	hash := []byte(hashToken(secret))
+	var found *model.Token
+	for _, t := range s.List() {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) == 1 {
+			found = t
+		}
+	}
+	return found, found != nil
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"ethstats/server/app/model"
+	"path/filepath"
+	"testing"
+)
+
+func TestTokenLookup(t *testing.T) {
+	s, err := NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, readSecret, _ := s.Add("grafana", model.ScopeRead, "")
+	_, adminSecret, _ := s.Add("ops", model.ScopeAdmin, "")
+	if _, _, err = s.Add("ops", model.ScopeRead, ""); err == nil {
+		t.Error("expected an error for a repeated name")
+	}
+	if _, _, err = s.Add("ci", "write", ""); err == nil {
+		t.Error("expected an error for an unknown scope")
+	}
+
+	cases := []struct {
+		secret string
+		name   string
+	}{
+		{readSecret, "grafana"},
+		{adminSecret, "ops"},
+		{"", ""},
+		{read.Hash, ""},
+		{readSecret[:len(readSecret)-1], ""},
+		{"osm_unknown", ""},
+	}
+	for _, c := range cases {
+		token, ok := s.Lookup(c.secret)
+		if ok != (c.name != "") || ok && token.Name != c.name {
+			t.Errorf("%q: expected token %q, got %+v", c.secret, c.name, token)
+		}
+	}
+
+	if err = s.Remove("grafana"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Lookup(readSecret); ok {
+		t.Error("expected the removed token to be rejected")
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"errors"
+	"ethstats/server/app/model"
+	"golang.org/x/crypto/bcrypt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultUserPath = "files/users.json"
+
+	minPasswordLength = 8
+)
+
+// dummyHash is compared when the user doesn't exist, so the response time doesn't tell whether the username exists
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("osmonitor-dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// UserUpdate is the change of a user, the nil fields are not changed
+type UserUpdate struct {
+	Password *string
+	Role     *string
+	Labels   *string
+}
+
+// UserStore keeps the dashboard users in a json file shared with the user subcommand
+type UserStore struct {
+	file  jsonFile
+	lock  sync.Mutex
+	users []*model.User
+}
+
+// NewUserStore creates a store backed by the given file, the file is created on first write
+func NewUserStore(path string) (*UserStore, error) {
+	if path == "" {
+		path = DefaultUserPath
+	}
+	s := &UserStore{file: jsonFile{path: path, perm: 0600}}
+	if err := s.file.Load(&s.users); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add creates a user
+func (s *UserStore) Add(username, password, role, labels string) (*model.User, error) {
+	if username == "" {
+		return nil, errors.New("the username can't be empty")
+	}
+	user := &model.User{Username: username, CreatedAt: time.Now()}
+	if err := applyUserUpdate(user, UserUpdate{Password: &password, Role: &role, Labels: &labels}); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.file.Update(&s.users, func() error {
+		if s.find(username) != nil {
+			return errors.New("user already exists: " + username)
+		}
+		s.users = append(s.users, user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Update changes the password, role or labels of the user
+func (s *UserStore) Update(username string, update UserUpdate) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Update(&s.users, func() error {
+		user := s.find(username)
+		if user == nil {
+			return errors.New("user not found: " + username)
+		}
+		changed := *user
+		if err := applyUserUpdate(&changed, update); err != nil {
+			return err
+		}
+		*user = changed
+		return nil
+	})
+}
+
+// Remove deletes the user
+func (s *UserStore) Remove(username string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.file.Update(&s.users, func() error {
+		for i, u := range s.users {
+			if u.Username == username {
+				s.users = append(s.users[:i], s.users[i+1:]...)
+				return nil
+			}
+		}
+		return errors.New("user not found: " + username)
+	})
+}
+
+// List returns all users
+func (s *UserStore) List() []*model.User {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_ = s.file.Load(&s.users)
+	return append([]*model.User(nil), s.users...)
+}
+
+// Get returns a copy of the user
+func (s *UserStore) Get(username string) (model.User, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_ = s.file.Load(&s.users)
+	if u := s.find(username); u != nil {
+		return *u, true
+	}
+	return model.User{}, false
+}
+
+// Authenticate returns the user when the password is correct
+func (s *UserStore) Authenticate(username, password string) (model.User, bool) {
+	user, ok := s.Get(username)
+	hash := dummyHash()
+	if ok {
+		hash = []byte(user.PasswordHash)
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !ok {
+		return model.User{}, false
+	}
+	return user, true
+}
+
+func (s *UserStore) find(username string) *model.User {
+	for _, u := range s.users {
+		if u.Username == username {
+			return u
+		}
+	}
+	return nil
+}
+
+// applyUserUpdate validates and applies the update
+func applyUserUpdate(user *model.User, update UserUpdate) error {
+	if update.Role != nil {
+		if _, ok := model.RoleScope(*update.Role); !ok {
+			return errors.New("unknown role " + *update.Role + ", expected viewer, operator or admin")
+		}
+		user.Role = *update.Role
+	}
+	if update.Labels != nil {
+		selector, err := model.ParseLabelSelector(*update.Labels)
+		if err != nil {
+			return err
+		}
+		user.Labels = selector.String()
+	}
+	if update.Password != nil {
+		if len(*update.Password) < minPasswordLength {
+			return errors.New("the password must have at least 8 characters")
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(*update.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = string(hash)
+	}
+	return nil
+}
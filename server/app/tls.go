@@ -0,0 +1,61 @@
+package app
+
+import (
+	"crypto/tls"
+	"errors"
+	"ethstats/common/util/tlsutil"
+	"ethstats/server/config"
+	"net/http"
+	"time"
+)
+
+const defaultReloadInterval = 60 //second
+
+// listen serves the default mux, with tls when tls.certFile is configured.
+// The cert and key files are checked every reload interval, a renewed certificate is used by the new connections
+func (a *App) listen() error {
+	addr := config.ApplicationConfig.Host + ":" + config.ApplicationConfig.Port
+	c := config.TLSConfig
+	if c.CertFile == "" {
+		if c.ClientCa != "" || c.RequireClientCert {
+			return errors.New("tls.clientCa and tls.requireClientCert require tls.certFile")
+		}
+		a.logger.Warn("tls is not configured, the data of the nodes is sent without encryption")
+		return http.ListenAndServe(addr, nil)
+	}
+	if c.RequireClientCert && c.ClientCa == "" {
+		return errors.New("tls.requireClientCert requires tls.clientCa")
+	}
+	reloader, err := tlsutil.NewCertReloader(c.CertFile, c.KeyFile)
+	if err != nil {
+		return err
+	}
+	interval := time.Duration(c.ReloadInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultReloadInterval * time.Second
+	}
+	reloader.Watch(interval, func(reloaded bool, err error) {
+		if err != nil {
+			a.logger.Errorf("reload tls certificate error, the loaded certificate is still used: %s", err)
+			return
+		}
+		a.logger.Infof("tls certificate %s reloaded", c.CertFile)
+	})
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if c.ClientCa != "" {
+		pool, err := tlsutil.LoadCertPool(c.ClientCa)
+		if err != nil {
+			return err
+		}
+		// the dashboard and the api are served by the same listener without client certificates,
+		// so the certificate is only required by the relay
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	server := &http.Server{Addr: addr, TLSConfig: tlsConfig}
+	a.logger.Infof("serve with tls on %s", addr)
+	return server.ListenAndServeTLS("", "")
+}
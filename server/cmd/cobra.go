@@ -2,7 +2,12 @@ package cmd
 
 import (
 	"errors"
+	"ethstats/server/cmd/credential"
+	"ethstats/server/cmd/email"
 	"ethstats/server/cmd/run"
+	"ethstats/server/cmd/silence"
+	"ethstats/server/cmd/token"
+	"ethstats/server/cmd/user"
 	"ethstats/server/config"
 	"github.com/spf13/cobra"
 	"os"
@@ -25,6 +30,11 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(run.StartCmd)
+	rootCmd.AddCommand(silence.SilenceCmd)
+	rootCmd.AddCommand(email.EmailCmd)
+	rootCmd.AddCommand(token.TokenCmd)
+	rootCmd.AddCommand(user.UserCmd)
+	rootCmd.AddCommand(credential.CredentialCmd)
 }
 
 // Execute : apply commands
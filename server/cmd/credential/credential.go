@@ -0,0 +1,178 @@
+package credential
+
+import (
+	"ethstats/common/util/dateutil"
+	"ethstats/server/app/service"
+	"ethstats/server/config"
+	"fmt"
+	"github.com/bitxx/load-config/source/file"
+	"github.com/spf13/cobra"
+	"os"
+	"os/user"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	configPath    string
+	CredentialCmd *cobra.Command
+)
+
+const (
+	node       = "node"
+	ttl        = "ttl"
+	joinTokens = "join-tokens"
+)
+
+func init() {
+	CredentialCmd = &cobra.Command{
+		Use:          "credential",
+		Short:        "manage node credentials and join tokens",
+		Example:      "server credential join -c settings.yml --node \"geth-*\" --ttl 24h",
+		SilenceUsage: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			config.Setup(
+				file.NewSource(file.WithPath(configPath)),
+			)
+		},
+	}
+	CredentialCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "server configuration file")
+
+	joinCmd := &cobra.Command{
+		Use:   "join",
+		Short: "create a one-time join token, a node enrolls with it to get its credential, the token is only printed once",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return join(cmd)
+		},
+	}
+	flag := joinCmd.Flags()
+	flag.String(node, "", "pattern of the node names allowed to use the token, e.g. geth-*, empty for all nodes")
+	flag.String(ttl, "24h", "the token expires after the duration")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "list node credentials",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if tokens, _ := cmd.Flags().GetBool(joinTokens); tokens {
+				return listJoinTokens()
+			}
+			return list()
+		},
+	}
+	listCmd.Flags().Bool(joinTokens, false, "list the join tokens instead")
+
+	revokeCmd := &cobra.Command{
+		Use:   "revoke <node>",
+		Short: "revoke the credential of a node, a connected node is dropped on its next message and can't login until it enrolls again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return revoke(args[0])
+		},
+	}
+
+	rotateCmd := &cobra.Command{
+		Use:   "rotate <node>",
+		Short: "create a new key for a node, a connected node gets the key with its next message and uses it on the next login",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rotate(args[0])
+		},
+	}
+
+	CredentialCmd.AddCommand(joinCmd, listCmd, revokeCmd, rotateCmd)
+}
+
+func join(cmd *cobra.Command) error {
+	flag := cmd.Flags()
+	n, _ := flag.GetString(node)
+	t, _ := flag.GetString(ttl)
+	d, err := time.ParseDuration(t)
+	if err != nil {
+		return fmt.Errorf("invalid ttl: %s", err)
+	}
+	createdBy := ""
+	if u, err := user.Current(); err == nil {
+		createdBy = u.Username
+	}
+	store, err := service.NewCredentialStore(config.CredentialsConfig.Path)
+	if err != nil {
+		return err
+	}
+	token, secret, err := store.AddJoinToken(n, d, createdBy)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("join token added: %s, expires at %s\n", token.ID, dateutil.ConvertToStr(token.ExpiresAt, -1))
+	fmt.Println("set it as app.joinToken of the client, it can't be shown again:")
+	fmt.Println(secret)
+	return nil
+}
+
+func list() error {
+	store, err := service.NewCredentialStore(config.CredentialsConfig.Path)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NODE\tSTATE\tCREATED\tROTATED\tREVOKED\tENROLLED BY")
+	for _, c := range store.List() {
+		state := "active"
+		switch {
+		case c.RevokedAt != nil:
+			state = "revoked"
+		case c.PendingKey != "":
+			state = "rotating"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.NodeID, state, dateutil.ConvertToStr(c.CreatedAt, -1),
+			dateutil.ConvertToStrByPrt(c.RotatedAt, -1), dateutil.ConvertToStrByPrt(c.RevokedAt, -1), c.EnrolledBy)
+	}
+	return w.Flush()
+}
+
+func listJoinTokens() error {
+	store, err := service.NewCredentialStore(config.CredentialsConfig.Path)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tNODE\tSTATE\tCREATED\tEXPIRES\tCREATED BY")
+	now := time.Now()
+	for _, t := range store.JoinTokens() {
+		state := "unused"
+		switch {
+		case t.UsedAt != nil:
+			state = "used by " + t.UsedBy
+		case now.After(t.ExpiresAt):
+			state = "expired"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Node, state, dateutil.ConvertToStr(t.CreatedAt, -1),
+			dateutil.ConvertToStr(t.ExpiresAt, -1), t.CreatedBy)
+	}
+	return w.Flush()
+}
+
+func revoke(nodeID string) error {
+	store, err := service.NewCredentialStore(config.CredentialsConfig.Path)
+	if err != nil {
+		return err
+	}
+	if err = store.Revoke(nodeID); err != nil {
+		return err
+	}
+	fmt.Println("credential revoked:", nodeID)
+	fmt.Println("a connected node is dropped on its next message, create a join token to enroll it again")
+	return nil
+}
+
+func rotate(nodeID string) error {
+	store, err := service.NewCredentialStore(config.CredentialsConfig.Path)
+	if err != nil {
+		return err
+	}
+	if err = store.Rotate(nodeID); err != nil {
+		return err
+	}
+	fmt.Println("credential rotated:", nodeID)
+	fmt.Println("a connected node gets the new key with its next message, the old key works until the node uses the new one")
+	return nil
+}
@@ -0,0 +1,80 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"ethstats/common/util/emailutil"
+	"ethstats/server/config"
+	"fmt"
+	"github.com/bitxx/load-config/source/file"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+var (
+	configPath string
+	EmailCmd   *cobra.Command
+)
+
+const (
+	to      = "to"
+	verbose = "verbose"
+)
+
+func init() {
+	EmailCmd = &cobra.Command{
+		Use:          "email",
+		Short:        "email tools",
+		SilenceUsage: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			config.Setup(
+				file.NewSource(file.WithPath(configPath)),
+			)
+		},
+	}
+	EmailCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "server configuration file")
+
+	testCmd := &cobra.Command{
+		Use:     "test",
+		Short:   "send a test email with the email config and report the smtp conversation on failure",
+		Example: "server email test -c settings.yml --to ops@example.com",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return test(cmd)
+		},
+	}
+	testCmd.Flags().String(to, "", "recipients, use ',' split multiple addresses, default email.toEmail")
+	testCmd.Flags().BoolP(verbose, "v", false, "print the smtp conversation on success too")
+	EmailCmd.AddCommand(testCmd)
+}
+
+func test(cmd *cobra.Command) error {
+	recipients, _ := cmd.Flags().GetString(to)
+	if recipients == "" {
+		recipients = config.EmailConfig.ToEmail
+	}
+	if recipients == "" {
+		return errors.New("no recipients, use --to or set email.toEmail")
+	}
+	contentType := config.EmailConfig.ContentType
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+
+	var transcript bytes.Buffer
+	opts := emailutil.DefaultOptions()
+	opts.Transcript = &transcript
+	subject := config.EmailConfig.SubjectPrefix + " test email"
+	content := fmt.Sprintf("This is a test email from osmonitor server [%s], sent at %s.",
+		config.ApplicationConfig.Name, time.Now().Format("2006-01-02 15:04:05"))
+	err := emailutil.SendEmailWithOptions(opts, recipients, subject, content, config.EmailConfig.FromEmail, contentType)
+	if err != nil {
+		fmt.Println("smtp conversation:")
+		fmt.Println(transcript.String())
+		return fmt.Errorf("send test email error: %s", err)
+	}
+	if v, _ := cmd.Flags().GetBool(verbose); v {
+		fmt.Println(transcript.String())
+	}
+	fmt.Println("test email sent to", recipients)
+	return nil
+}
@@ -35,6 +35,10 @@ const (
 	emailTo            = "email-to"
 	emailSubjectPrefix = "email-subject-prefix"
 	monitorTime        = "email-monitor-time"
+	emailTlsMode       = "email-tls-mode"
+	emailSkipVerify    = "email-skip-verify"
+	emailCaFile        = "email-ca-file"
+	emailAuthMethod    = "email-auth-method"
 )
 
 func init() {
@@ -106,6 +110,18 @@ func init() {
 			if monitorTime, _ := flag.GetInt(monitorTime); monitorTime > 0 && config.EmailConfig.DelayTime <= 0 {
 				config.EmailConfig.DelayTime = monitorTime
 			}
+			if emailTlsMode, _ := flag.GetString(emailTlsMode); emailTlsMode != "" && config.EmailConfig.TlsMode == "" {
+				config.EmailConfig.TlsMode = emailTlsMode
+			}
+			if emailSkipVerify, _ := flag.GetBool(emailSkipVerify); emailSkipVerify && !config.EmailConfig.SkipVerify {
+				config.EmailConfig.SkipVerify = emailSkipVerify
+			}
+			if emailCaFile, _ := flag.GetString(emailCaFile); emailCaFile != "" && config.EmailConfig.CaFile == "" {
+				config.EmailConfig.CaFile = emailCaFile
+			}
+			if emailAuthMethod, _ := flag.GetString(emailAuthMethod); emailAuthMethod != "" && config.EmailConfig.AuthMethod == "" {
+				config.EmailConfig.AuthMethod = emailAuthMethod
+			}
 
 			if config.ApplicationConfig.Name == "" {
 				log.Fatal("param name can't empty")
@@ -147,6 +163,10 @@ func init() {
 	cmd.String(emailTo, "", "email to")
 	cmd.String(emailSubjectPrefix, "", "email subject prefix")
 	cmd.Int(monitorTime, 86400, "email monitor time")
+	cmd.String(emailTlsMode, "", "email tls mode: none, starttls, tls")
+	cmd.Bool(emailSkipVerify, false, "skip the verification of the smtp server certificate")
+	cmd.String(emailCaFile, "", "ca file to verify the smtp server certificate")
+	cmd.String(emailAuthMethod, "", "email auth method: plain, login, cram-md5, none")
 }
 
 func run() error {
@@ -0,0 +1,192 @@
+package silence
+
+import (
+	"errors"
+	"ethstats/common/util/dateutil"
+	"ethstats/server/app/model"
+	"ethstats/server/app/service"
+	"ethstats/server/config"
+	"fmt"
+	"github.com/bitxx/load-config/source/file"
+	"github.com/spf13/cobra"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	configPath string
+	SilenceCmd *cobra.Command
+)
+
+const (
+	nodeID   = "node"
+	tag      = "tag"
+	proc     = "proc"
+	alert    = "alert"
+	start    = "start"
+	end      = "end"
+	duration = "duration"
+	weekdays = "weekdays"
+	daily    = "daily"
+	timezone = "timezone"
+	comment  = "comment"
+)
+
+func init() {
+	SilenceCmd = &cobra.Command{
+		Use:          "silence",
+		Short:        "manage silences and maintenance windows",
+		Example:      "server silence add -c settings.yml --node geth-01 --duration 2h --comment upgrade",
+		SilenceUsage: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			config.Setup(
+				file.NewSource(file.WithPath(configPath)),
+			)
+		},
+	}
+	SilenceCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "server configuration file")
+
+	addCmd := &cobra.Command{
+		Use:     "add",
+		Short:   "add a silence",
+		Example: "server silence add -c settings.yml --proc geth --weekdays sun --daily 02:00-04:00",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return add(cmd)
+		},
+	}
+	flag := addCmd.Flags()
+	flag.String(nodeID, "", "node id pattern, e.g. geth-*")
+	flag.String(tag, "", "digest tag pattern")
+	flag.String(proc, "", "process name pattern, also matches the name of a heartbeat check, a job or a metric rule")
+	flag.String(alert, "", "alert name pattern, e.g. proc-down, node-error")
+	flag.String(start, "", "start time, format: 2006-01-02 15:04:05, default now")
+	flag.String(end, "", "end time, format: 2006-01-02 15:04:05")
+	flag.String(duration, "", "duration from the start time, e.g. 2h30m")
+	flag.String(weekdays, "", "weekdays of a recurring window, e.g. sun,sat")
+	flag.String(daily, "", "daily clock range of a recurring window, e.g. 02:00-04:00")
+	flag.String(timezone, "", "timezone of the times, default local")
+	flag.String(comment, "", "comment")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "list silences",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return list()
+		},
+	}
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <id>",
+		Short: "remove a silence",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return remove(args[0])
+		},
+	}
+
+	SilenceCmd.AddCommand(addCmd, listCmd, removeCmd)
+}
+
+func add(cmd *cobra.Command) error {
+	flag := cmd.Flags()
+	silence := &model.Silence{}
+	silence.NodeID, _ = flag.GetString(nodeID)
+	silence.Tag, _ = flag.GetString(tag)
+	silence.Proc, _ = flag.GetString(proc)
+	silence.Alert, _ = flag.GetString(alert)
+	silence.Weekdays, _ = flag.GetString(weekdays)
+	silence.Timezone, _ = flag.GetString(timezone)
+	silence.Comment, _ = flag.GetString(comment)
+	if u, err := user.Current(); err == nil {
+		silence.CreatedBy = u.Username
+	}
+	if silence.NodeID == "" && silence.Tag == "" && silence.Proc == "" && silence.Alert == "" {
+		return errors.New("at least one of --node, --tag, --proc or --alert is required")
+	}
+
+	if s, _ := flag.GetString(start); s != "" {
+		t, err := dateutil.ParseStrToTime(s, silence.Timezone, -1)
+		if err != nil {
+			return fmt.Errorf("invalid start time: %s", err)
+		}
+		silence.StartsAt = t
+	}
+	if e, _ := flag.GetString(end); e != "" {
+		t, err := dateutil.ParseStrToTime(e, silence.Timezone, -1)
+		if err != nil {
+			return fmt.Errorf("invalid end time: %s", err)
+		}
+		silence.EndsAt = t
+	}
+	if d, _ := flag.GetString(duration); d != "" {
+		dur, err := time.ParseDuration(d)
+		if err != nil {
+			return fmt.Errorf("invalid duration: %s", err)
+		}
+		if silence.StartsAt.IsZero() {
+			silence.StartsAt = time.Now()
+		}
+		silence.EndsAt = silence.StartsAt.Add(dur)
+	}
+	if d, _ := flag.GetString(daily); d != "" {
+		clock := strings.Split(d, "-")
+		if len(clock) != 2 {
+			return errors.New("invalid daily range, use the format 02:00-04:00")
+		}
+		silence.DailyStart, silence.DailyEnd = strings.TrimSpace(clock[0]), strings.TrimSpace(clock[1])
+	}
+	if silence.StartsAt.IsZero() && !silence.IsRecurring() {
+		silence.StartsAt = time.Now()
+	}
+
+	store, err := service.NewSilenceStore(config.SilenceConfig.Path)
+	if err != nil {
+		return err
+	}
+	if err = store.Add(silence); err != nil {
+		return err
+	}
+	fmt.Println("silence added:", silence.ID)
+	return nil
+}
+
+func list() error {
+	store, err := service.NewSilenceStore(config.SilenceConfig.Path)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tACTIVE\tNODE\tTAG\tPROC\tALERT\tSTART\tEND\tWINDOW\tCOMMENT")
+	for _, s := range store.List() {
+		window := ""
+		if s.IsRecurring() {
+			window = strings.TrimSpace(s.Weekdays + " " + s.DailyStart + "-" + s.DailyEnd)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%t\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.ID, s.ActiveAt(now),
+			s.NodeID, s.Tag, s.Proc, s.Alert, formatTime(s.StartsAt), formatTime(s.EndsAt), window, s.Comment)
+	}
+	return w.Flush()
+}
+
+func remove(id string) error {
+	store, err := service.NewSilenceStore(config.SilenceConfig.Path)
+	if err != nil {
+		return err
+	}
+	if err = store.Remove(id); err != nil {
+		return err
+	}
+	fmt.Println("silence removed:", id)
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return dateutil.ConvertToStr(t, -1)
+}
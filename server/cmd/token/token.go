@@ -0,0 +1,116 @@
+package token
+
+import (
+	"ethstats/common/util/dateutil"
+	"ethstats/server/app/model"
+	"ethstats/server/app/service"
+	"ethstats/server/config"
+	"fmt"
+	"github.com/bitxx/load-config/source/file"
+	"github.com/spf13/cobra"
+	"os"
+	"os/user"
+	"text/tabwriter"
+)
+
+var (
+	configPath string
+	TokenCmd   *cobra.Command
+)
+
+const (
+	name  = "name"
+	scope = "scope"
+)
+
+func init() {
+	TokenCmd = &cobra.Command{
+		Use:          "token",
+		Short:        "manage api tokens",
+		Example:      "server token add -c settings.yml --name grafana --scope read",
+		SilenceUsage: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			config.Setup(
+				file.NewSource(file.WithPath(configPath)),
+			)
+		},
+	}
+	TokenCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "server configuration file")
+
+	addCmd := &cobra.Command{
+		Use:   "add",
+		Short: "add a token, the token is only printed once",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return add(cmd)
+		},
+	}
+	flag := addCmd.Flags()
+	flag.String(name, "", "token name")
+	flag.String(scope, model.ScopeRead, "token scope: read, operate, admin")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "list tokens",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return list()
+		},
+	}
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <id|name>",
+		Short: "remove a token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return remove(args[0])
+		},
+	}
+
+	TokenCmd.AddCommand(addCmd, listCmd, removeCmd)
+}
+
+func add(cmd *cobra.Command) error {
+	flag := cmd.Flags()
+	n, _ := flag.GetString(name)
+	s, _ := flag.GetString(scope)
+	createdBy := ""
+	if u, err := user.Current(); err == nil {
+		createdBy = u.Username
+	}
+	store, err := service.NewTokenStore(config.ApiConfig.TokenPath)
+	if err != nil {
+		return err
+	}
+	token, secret, err := store.Add(n, s, createdBy)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("token added: %s (%s)\n", token.ID, token.Scope)
+	fmt.Println("save the token, it can't be shown again:")
+	fmt.Println(secret)
+	return nil
+}
+
+func list() error {
+	store, err := service.NewTokenStore(config.ApiConfig.TokenPath)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tNAME\tSCOPE\tCREATED\tCREATED BY")
+	for _, t := range store.List() {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.ID, t.Name, t.Scope, dateutil.ConvertToStr(t.CreatedAt, -1), t.CreatedBy)
+	}
+	return w.Flush()
+}
+
+func remove(id string) error {
+	store, err := service.NewTokenStore(config.ApiConfig.TokenPath)
+	if err != nil {
+		return err
+	}
+	if err = store.Remove(id); err != nil {
+		return err
+	}
+	fmt.Println("token removed:", id)
+	return nil
+}
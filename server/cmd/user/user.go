@@ -0,0 +1,187 @@
+package user
+
+import (
+	"bufio"
+	"errors"
+	"ethstats/common/util/dateutil"
+	"ethstats/server/app/model"
+	"ethstats/server/app/service"
+	"ethstats/server/config"
+	"fmt"
+	"github.com/bitxx/load-config/source/file"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	configPath string
+	UserCmd    *cobra.Command
+)
+
+const (
+	password = "password"
+	role     = "role"
+	labels   = "labels"
+)
+
+func init() {
+	UserCmd = &cobra.Command{
+		Use:          "user",
+		Short:        "manage dashboard users",
+		Example:      "server user add -c settings.yml alice --role operator --labels team=storage",
+		SilenceUsage: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			config.Setup(
+				file.NewSource(file.WithPath(configPath)),
+			)
+		},
+	}
+	UserCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "server configuration file")
+
+	addCmd := &cobra.Command{
+		Use:   "add <username>",
+		Short: "add a user, the password is read from stdin when --password is empty",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return add(cmd, args[0])
+		},
+	}
+	flag := addCmd.Flags()
+	flag.String(password, "", "password, at least 8 characters")
+	flag.String(role, model.RoleViewer, "role: viewer, operator, admin")
+	flag.String(labels, "", "label selector of the visible nodes, e.g. team=storage, empty means all nodes")
+
+	setCmd := &cobra.Command{
+		Use:   "set <username>",
+		Short: "change the password, role or labels of a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return set(cmd, args[0])
+		},
+	}
+	flag = setCmd.Flags()
+	flag.String(password, "", "new password, use - to read it from stdin")
+	flag.String(role, "", "new role: viewer, operator, admin")
+	flag.String(labels, "", "new label selector of the visible nodes, use - for all nodes")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "list users",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return list()
+		},
+	}
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <username>",
+		Short: "remove a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return remove(args[0])
+		},
+	}
+
+	UserCmd.AddCommand(addCmd, setCmd, listCmd, removeCmd)
+}
+
+func add(cmd *cobra.Command, username string) error {
+	flag := cmd.Flags()
+	p, _ := flag.GetString(password)
+	r, _ := flag.GetString(role)
+	l, _ := flag.GetString(labels)
+	if p == "" {
+		var err error
+		if p, err = readPassword(); err != nil {
+			return err
+		}
+	}
+	store, err := service.NewUserStore(config.ApiConfig.UserPath)
+	if err != nil {
+		return err
+	}
+	user, err := store.Add(username, p, r, l)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("user added: %s (%s)\n", user.Username, user.Role)
+	return nil
+}
+
+func set(cmd *cobra.Command, username string) error {
+	flag := cmd.Flags()
+	update := service.UserUpdate{}
+	if flag.Changed(password) {
+		p, _ := flag.GetString(password)
+		if p == "-" {
+			var err error
+			if p, err = readPassword(); err != nil {
+				return err
+			}
+		}
+		update.Password = &p
+	}
+	if flag.Changed(role) {
+		r, _ := flag.GetString(role)
+		update.Role = &r
+	}
+	if flag.Changed(labels) {
+		l, _ := flag.GetString(labels)
+		if l == "-" {
+			l = ""
+		}
+		update.Labels = &l
+	}
+	if update.Password == nil && update.Role == nil && update.Labels == nil {
+		return errors.New("at least one of --password, --role or --labels is required")
+	}
+	store, err := service.NewUserStore(config.ApiConfig.UserPath)
+	if err != nil {
+		return err
+	}
+	if err = store.Update(username, update); err != nil {
+		return err
+	}
+	fmt.Println("user changed:", username)
+	return nil
+}
+
+func list() error {
+	store, err := service.NewUserStore(config.ApiConfig.UserPath)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "USERNAME\tROLE\tLABELS\tCREATED")
+	for _, u := range store.List() {
+		l := u.Labels
+		if l == "" {
+			l = "*"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", u.Username, u.Role, l, dateutil.ConvertToStr(u.CreatedAt, -1))
+	}
+	return w.Flush()
+}
+
+func remove(username string) error {
+	store, err := service.NewUserStore(config.ApiConfig.UserPath)
+	if err != nil {
+		return err
+	}
+	if err = store.Remove(username); err != nil {
+		return err
+	}
+	fmt.Println("user removed:", username)
+	return nil
+}
+
+// readPassword reads the password from the first line of stdin
+func readPassword() (string, error) {
+	_, _ = fmt.Fprint(os.Stderr, "password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("no password is read from stdin")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
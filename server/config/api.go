@@ -0,0 +1,11 @@
+package config
+
+type Api struct {
+	TokenPath      string
+	UserPath       string
+	SessionTimeout int64 // second
+	AllowedOrigins []string
+	Auth           string // required, the default, or disabled
+}
+
+var ApiConfig = new(Api)
@@ -6,6 +6,9 @@ type Application struct {
 	Port    string
 	Version string
 	Secret  string
+	// LegacyAuth accepts the secret sent in the hello message by the clients without the challenge-response login,
+	// only enable it while the clients are upgraded
+	LegacyAuth bool
 }
 
 var ApplicationConfig = new(Application)
@@ -8,9 +8,24 @@ import (
 )
 
 type Config struct {
-	Application *Application `yaml:"application"`
-	Logger      *Logger      `yaml:"logger"`
-	Email       *Email       `yaml:"email"`
+	Application *Application                  `yaml:"application"`
+	Logger      *Logger                       `yaml:"logger"`
+	Email       *Email                        `yaml:"email"`
+	Silence     *Silence                      `yaml:"silence"`
+	Api         *Api                          `yaml:"api"`
+	Notifiers   *[]Notifier                   `yaml:"notifiers"`
+	Outbox      *Outbox                       `yaml:"outbox"`
+	Digest      *Digest                       `yaml:"digest"`
+	Event       *Event                        `yaml:"event"`
+	Reports     *[]Report                     `yaml:"reports"`
+	Routes      *[]Route                      `yaml:"routes"`
+	Rules       *[]Rule                       `yaml:"rules"`
+	Heartbeats  *[]Heartbeat                  `yaml:"heartbeats"`
+	Jobs        *Jobs                         `yaml:"jobs"`
+	TLS         *TLS                          `yaml:"tls"`
+	Credentials *Credentials                  `yaml:"credentials"`
+	NodeTags    *map[string][]string          `yaml:"nodeTags"`
+	NodeLabels  *map[string]map[string]string `yaml:"nodeLabels"`
 	callbacks   []func()
 }
 
@@ -41,6 +56,21 @@ func Setup(s source.Source,
 		Application: ApplicationConfig,
 		Logger:      LoggerConfig,
 		Email:       EmailConfig,
+		Silence:     SilenceConfig,
+		Api:         ApiConfig,
+		Notifiers:   NotifiersConfig,
+		Outbox:      OutboxConfig,
+		Digest:      DigestConfig,
+		Event:       EventConfig,
+		Reports:     ReportsConfig,
+		Routes:      RoutesConfig,
+		Rules:       RulesConfig,
+		Heartbeats:  HeartbeatsConfig,
+		Jobs:        JobsConfig,
+		TLS:         TLSConfig,
+		Credentials: CredentialsConfig,
+		NodeTags:    NodeTagsConfig,
+		NodeLabels:  NodeLabelsConfig,
 		callbacks:   fs,
 	}
 	var err error
@@ -0,0 +1,9 @@
+package config
+
+// Credentials is the per-node keys of the nodes enrolled with a join token
+type Credentials struct {
+	Path     string
+	Required bool // reject the nodes without a credential, the shared secret of the application is only used by the nodes without a credential
+}
+
+var CredentialsConfig = new(Credentials)
@@ -0,0 +1,10 @@
+package config
+
+type Digest struct {
+	Template      string   // html template file, empty means the built-in template
+	TextTemplate  string   // plain text template file, empty means the built-in template
+	DashboardUrl  string   // base url of the dashboard, used for the links in the digest
+	ExpectedNodes []string // ids of the nodes that should be online
+}
+
+var DigestConfig = new(Digest)
@@ -10,6 +10,10 @@ type Email struct {
 	ToEmail       string
 	SubjectPrefix string
 	DelayTime     int
+	TlsMode       string // none, starttls, tls; empty means tls for port 465, otherwise starttls if supported
+	SkipVerify    bool   // skip the verification of the smtp server certificate
+	CaFile        string // ca file to verify the smtp server certificate
+	AuthMethod    string // plain, login, cram-md5, none; empty means cram-md5 if supported, otherwise plain
 }
 
 var EmailConfig = new(Email)
@@ -0,0 +1,8 @@
+package config
+
+type Event struct {
+	Path      string
+	Retention int // days to keep resolved events
+}
+
+var EventConfig = new(Event)
@@ -0,0 +1,12 @@
+package config
+
+// Heartbeat is a check that expects a heartbeat every period, e.g. from a cron job
+type Heartbeat struct {
+	Name     string
+	Node     string // the node of the check used by the events, silences and routes, default the node of the last heartbeat
+	Period   int    // second, the expected interval of the heartbeats
+	Grace    int    // second, the delay accepted after the period
+	Severity string // critical, warning, info; default critical
+}
+
+var HeartbeatsConfig = new([]Heartbeat)
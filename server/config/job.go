@@ -0,0 +1,17 @@
+package config
+
+// Jobs is the runs of the jobs reported by `client wrap`
+type Jobs struct {
+	Path    string
+	History int   // count of the runs kept, default 1000
+	Checks  []Job // the first check matching the job name is used
+}
+
+// Job is the check of the runs of a job, the runs of all jobs are recorded and alert on failures
+type Job struct {
+	Name        string // pattern of the job name, e.g. backup-*
+	MaxDuration int    // second, alert when a run takes longer, 0 for no limit
+	Severity    string // critical, warning, info; default warning
+}
+
+var JobsConfig = new(Jobs)
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"path"
+	"sort"
+)
+
+// NodeTagsConfig are the tags of the nodes, the key is a node id or a pattern like "geth-*"
+var NodeTagsConfig = new(map[string][]string)
+
+// NodeLabelsConfig attaches labels to the nodes or overrides the labels sent by the clients,
+// the key is a node id or a pattern like "geth-*"
+var NodeLabelsConfig = new(map[string]map[string]string)
+
+// NodeTags returns the sorted tags of the node from the nodeTags config
+func NodeTags(id string) []string {
+	seen := make(map[string]bool)
+	tags := make([]string, 0)
+	for pattern, items := range *NodeTagsConfig {
+		if ok, _ := path.Match(pattern, id); !ok {
+			continue
+		}
+		for _, tag := range items {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// NodeLabels returns the labels sent by the client merged with the nodeLabels config.
+// The patterns are applied in lexical order and the exact node id last, so it wins
+func NodeLabels(id string, client map[string]string) map[string]string {
+	labels := make(map[string]string, len(client))
+	for k, v := range client {
+		labels[k] = v
+	}
+	var patterns []string
+	for pattern := range *NodeLabelsConfig {
+		if ok, _ := path.Match(pattern, id); ok && pattern != id {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Strings(patterns)
+	if _, ok := (*NodeLabelsConfig)[id]; ok {
+		patterns = append(patterns, id)
+	}
+	for _, pattern := range patterns {
+		for k, v := range (*NodeLabelsConfig)[pattern] {
+			labels[k] = v
+		}
+	}
+	return labels
+}
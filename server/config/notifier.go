@@ -0,0 +1,19 @@
+package config
+
+// Notifier is one notification target, multiple notifiers can be used side by side
+type Notifier struct {
+	Name    string
+	Type    string   // email, webhook, dingtalk, feishu, lark, wecom, telegram, slack, discord
+	Kinds   []string // report, alert; empty means both
+	To      string   // email only, override the recipients of the email config
+	Url     string
+	BaseUrl string // telegram: api base url, default https://api.telegram.org
+	Token   string // telegram: bot token
+	ChatID  string // telegram: chat id
+	Secret  string // webhook: hmac-sha256 key of the X-Osmonitor-Signature header; dingtalk, feishu: sign secret
+	Headers map[string]string
+	Retry   int // retry times after the first failed attempt
+	Timeout int // request timeout, unit second
+}
+
+var NotifiersConfig = new([]Notifier)
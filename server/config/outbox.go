@@ -0,0 +1,9 @@
+package config
+
+type Outbox struct {
+	Path       string
+	MinBackoff int // delay after the first failed attempt, unit second
+	MaxBackoff int // upper limit of the delay between attempts, unit second
+}
+
+var OutboxConfig = new(Outbox)
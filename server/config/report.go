@@ -0,0 +1,17 @@
+package config
+
+// Report is a named digest with its own schedule, recipients, nodes and content
+type Report struct {
+	Name      string
+	Cron      string            // minute hour day-of-month month day-of-week, e.g. "0 9 * * *"; "@every 1h" is supported too
+	Timezone  string            // timezone of the cron expression, e.g. Asia/Shanghai; empty means local
+	Notifiers []string          // names of the notifiers, empty means all notifiers which accept reports
+	To        string            // email recipients, override the recipients of the email notifiers
+	Nodes     []string          // patterns of the node ids, e.g. "geth-*"; empty means all nodes
+	Labels    map[string]string // the node labels must match, the values support patterns like "eu-*"
+	GroupBy   string            // label key, the node sections are grouped by its value
+	Scope     string            // full, events or summary; empty means full
+	SendEmpty bool              // send the report even when no event happened in the period
+}
+
+var ReportsConfig = new([]Report)
@@ -0,0 +1,18 @@
+package config
+
+// Route is a node of the alert routing tree, all non-empty matchers must match.
+// A matching route passes the alert to its child routes, its own receivers are used
+// when no child route matches. Without Continue, the later sibling routes are skipped
+type Route struct {
+	Name       string
+	Nodes      []string          // patterns of the node ids, e.g. "geth-*"
+	Tags       []string          // the node must have all tags
+	Labels     map[string]string // the node labels must match, the values support patterns like "eu-*"
+	Severities []string          // critical, warning, info
+	Kinds      []string          // event kinds, e.g. proc-down, node-error
+	Receivers  []string          // names of the notifiers
+	Continue   bool
+	Routes     []Route
+}
+
+var RoutesConfig = new([]Route)
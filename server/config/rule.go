@@ -0,0 +1,15 @@
+package config
+
+// Rule raises a metric-rule alert when a metric of a node matches the condition for the duration
+type Rule struct {
+	Name     string
+	Metric   string            // a custom metric sent by the statsd listener, e.g. api.errors, or a host stat, e.g. mem_used_percent
+	Op       string            // >, >=, <, <=, ==, !=
+	Value    float64           // the threshold
+	For      int               // second, the condition must hold for the duration before the alert, 0 to alert at once
+	Nodes    []string          // patterns of the node ids, e.g. "geth-*", empty for all nodes
+	Labels   map[string]string // the node labels must match, the values support patterns like "eu-*"
+	Severity string            // critical, warning, info; default warning
+}
+
+var RulesConfig = new([]Rule)
@@ -0,0 +1,21 @@
+package config
+
+type Silence struct {
+	Path    string
+	Windows []Window
+}
+
+// Window is a recurring maintenance window declared in settings.yml
+type Window struct {
+	Name     string
+	NodeID   string
+	Tag      string
+	Proc     string
+	Alert    string
+	Weekdays string
+	Start    string
+	End      string
+	Timezone string
+}
+
+var SilenceConfig = new(Silence)
@@ -0,0 +1,12 @@
+package config
+
+// TLS serves the relay, the api and the dashboard with https and wss when the cert file is configured
+type TLS struct {
+	CertFile          string
+	KeyFile           string
+	ReloadInterval    int    // second, check the cert and key files for changes, default 60
+	ClientCa          string // PEM encoded CAs of the client certificates, the verified certificate's CN must be the node id
+	RequireClientCert bool   // nodes without a verified client certificate are rejected, requires clientCa
+}
+
+var TLSConfig = new(TLS)
@@ -0,0 +1,23 @@
+// Package frontend embeds the web dashboard, so the server binary serves it without any external file or CDN
+package frontend
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Path is the url path of the dashboard
+const Path = "/dashboard/"
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the dashboard files under Path
+func Handler() http.Handler {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix(Path, http.FileServer(http.FS(files)))
+}
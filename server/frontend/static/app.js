@@ -0,0 +1,496 @@
+// osmonitor dashboard, driven by the topic subscriptions of the /api websocket
+(function () {
+  'use strict';
+
+  var TOPICS = ['nodes', 'metrics', 'events', 'alerts'];
+  var HISTORY = 360; // points of every metric chart
+  var CHARTS = [
+    {key: 'latency', title: '延迟', unit: 'ms'},
+    {key: 'load1', title: '负载 1m', unit: ''},
+    {key: 'mem_used_percent', title: '内存使用', unit: '%', max: 100},
+    {key: 'swap_used_percent', title: 'swap使用', unit: '%', max: 100},
+    {key: 'disk_used_percent', title: '磁盘使用', unit: '%', max: 100}
+  ];
+
+  var state = {
+    nodes: {},    // id => NodeState
+    metrics: {},  // id => NodeMetrics
+    history: {},  // id => {metric => [[time, value]]}
+    events: {},   // id => Event
+    alerts: [],   // AlertRecord, newest first
+    filter: {nodes: [], labels: ''}
+  };
+  var TOKEN_KEY = 'osmonitor-token';
+  var me = {name: '', kind: 'anonymous', scope: 'admin'};
+  var socket = null;
+  var retry = 1000;
+  var view = {name: 'overview', node: ''};
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  function esc(s) {
+    return String(s === undefined || s === null ? '' : s).replace(/[&<>"']/g, function (c) {
+      return {'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'}[c];
+    });
+  }
+
+  function fmtTime(t) {
+    if (!t || t.indexOf('0001-') === 0) {
+      return '-';
+    }
+    var d = new Date(t);
+    var pad = function (n) {
+      return n < 10 ? '0' + n : n;
+    };
+    return d.getFullYear() + '-' + pad(d.getMonth() + 1) + '-' + pad(d.getDate()) + ' ' +
+      pad(d.getHours()) + ':' + pad(d.getMinutes()) + ':' + pad(d.getSeconds());
+  }
+
+  function fmtBytes(v) {
+    var units = ['B', 'KB', 'MB', 'GB', 'TB'];
+    var i = 0;
+    while (v >= 1024 && i < units.length - 1) {
+      v /= 1024;
+      i++;
+    }
+    return v.toFixed(i ? 1 : 0) + units[i];
+  }
+
+  function labels(m) {
+    return Object.keys(m || {}).sort().map(function (k) {
+      return '<span class="label">' + esc(k) + '=' + esc(m[k]) + '</span>';
+    }).join('');
+  }
+
+  // ---------- websocket ----------
+
+  function authHeaders() {
+    var token = localStorage.getItem(TOKEN_KEY) || '';
+    return token ? {Authorization: 'Bearer ' + token} : {};
+  }
+
+  // connect checks the session or the token with the http api first, the failed websocket handshakes can't be read in the browser
+  function connect() {
+    var token = localStorage.getItem(TOKEN_KEY) || '';
+    fetch('/api/v1/me', {headers: authHeaders(), credentials: 'same-origin'}).then(function (resp) {
+      if (resp.status === 401) {
+        showLogin(token ? 'token无效' : '');
+        return;
+      }
+      return resp.json().then(function (value) {
+        me = value;
+        $('user').hidden = me.kind === 'anonymous';
+        $('user-name').textContent = me.name + (me.role ? ' (' + me.role + ')' : '') + (me.labels ? ' ' + me.labels : '');
+        openSocket(token);
+      });
+    }, function () {
+      setTimeout(connect, retry);
+      retry = Math.min(retry * 2, 30000);
+    });
+  }
+
+  function canOperate() {
+    return me.scope === 'operate' || me.scope === 'admin';
+  }
+
+  // ack acknowledges the firing event, the change is received as an events delta
+  function ack(id) {
+    fetch('/api/v1/events/' + encodeURIComponent(id) + '/ack', {
+      method: 'POST', headers: authHeaders(), credentials: 'same-origin'
+    }).then(function (resp) {
+      if (!resp.ok) {
+        resp.json().then(function (body) {
+          alert(body.error ? body.error.message : resp.statusText);
+        });
+      }
+    });
+  }
+
+  function openSocket(token) {
+    var url = (location.protocol === 'https:' ? 'wss://' : 'ws://') + location.host + '/api' +
+      (token ? '?token=' + encodeURIComponent(token) : '');
+    socket = new WebSocket(url);
+    socket.onopen = function () {
+      retry = 1000;
+      setStatus(true);
+      subscribe();
+    };
+    socket.onclose = function () {
+      setStatus(false);
+      setTimeout(connect, retry);
+      retry = Math.min(retry * 2, 30000);
+    };
+    socket.onmessage = function (e) {
+      var msg;
+      try {
+        msg = JSON.parse(e.data);
+      } catch (err) {
+        return;
+      }
+      if (!msg.emit) {
+        return;
+      }
+      var type = msg.emit[0], value = msg.emit[1];
+      if (type === 'snapshot') {
+        applySnapshot(value.topic, value.items || []);
+      } else if (type === 'delta') {
+        applyDelta(value.topic, value.item);
+      } else if (type === 'error') {
+        console.error('subscription error:', value);
+      }
+    };
+  }
+
+  function showLogin(error) {
+    setStatus(false);
+    $('login-error').textContent = error;
+    ['overview', 'node', 'events', 'alerts'].forEach(function (name) {
+      $(name).hidden = true;
+    });
+    $('login').hidden = false;
+  }
+
+  function subscribe() {
+    if (socket && socket.readyState === WebSocket.OPEN) {
+      socket.send(JSON.stringify({emit: ['subscribe', {topics: TOPICS, nodes: state.filter.nodes, labels: state.filter.labels}]}));
+    }
+  }
+
+  function setStatus(online) {
+    var el = $('status');
+    el.textContent = online ? '已连接' : '未连接';
+    el.className = 'status ' + (online ? 'online' : 'offline');
+  }
+
+  function applySnapshot(topic, items) {
+    switch (topic) {
+      case 'nodes':
+        state.nodes = {};
+        items.forEach(function (n) {
+          state.nodes[n.id] = n;
+        });
+        break;
+      case 'metrics':
+        state.metrics = {};
+        items.forEach(addMetrics);
+        break;
+      case 'events':
+        state.events = {};
+        items.forEach(function (e) {
+          state.events[e.id] = e;
+        });
+        break;
+      case 'alerts':
+        state.alerts = items;
+        break;
+    }
+    render();
+  }
+
+  function applyDelta(topic, item) {
+    switch (topic) {
+      case 'nodes':
+        state.nodes[item.id] = item;
+        break;
+      case 'metrics':
+        addMetrics(item);
+        break;
+      case 'events':
+        state.events[item.id] = item;
+        break;
+      case 'alerts':
+        state.alerts.unshift(item);
+        state.alerts.length = Math.min(state.alerts.length, 200);
+        break;
+    }
+    render();
+  }
+
+  // addMetrics saves the latest metrics and appends them to the chart history of the node
+  function addMetrics(m) {
+    state.metrics[m.id] = m;
+    var h = state.history[m.id] = state.history[m.id] || {};
+    var t = Date.now();
+    var values = Object.assign({}, m.stats || {});
+    if (m.latency >= 0) {
+      values.latency = m.latency;
+    }
+    Object.keys(values).forEach(function (k) {
+      var points = h[k] = h[k] || [];
+      points.push([t, values[k]]);
+      if (points.length > HISTORY) {
+        points.shift();
+      }
+    });
+  }
+
+  // ---------- views ----------
+
+  function route() {
+    var hash = location.hash.replace(/^#\/?/, '');
+    var parts = hash.split('/');
+    // #/nodes/<id> is accepted for the links of the digests sent by the older versions
+    if ((parts[0] === 'node' || parts[0] === 'nodes') && parts[1]) {
+      view = {name: 'node', node: decodeURIComponent(parts.slice(1).join('/'))};
+    } else if (parts[0] === 'events' || parts[0] === 'alerts') {
+      view = {name: parts[0], node: ''};
+    } else {
+      view = {name: 'overview', node: ''};
+    }
+    ['overview', 'node', 'events', 'alerts'].forEach(function (name) {
+      $(name).hidden = name !== view.name;
+    });
+    document.querySelectorAll('header nav a').forEach(function (a) {
+      var name = a.getAttribute('data-view');
+      a.className = name === view.name || (name === 'overview' && view.name === 'node') ? 'active' : '';
+    });
+    render();
+  }
+
+  function render() {
+    renderSummary();
+    switch (view.name) {
+      case 'overview':
+        renderOverview();
+        break;
+      case 'node':
+        renderNode(view.node);
+        break;
+      case 'events':
+        renderEvents();
+        break;
+      case 'alerts':
+        renderAlerts();
+        break;
+    }
+  }
+
+  function sortedNodes() {
+    return Object.keys(state.nodes).sort().map(function (id) {
+      return state.nodes[id];
+    });
+  }
+
+  function firingEvents(node) {
+    return Object.keys(state.events).map(function (id) {
+      return state.events[id];
+    }).filter(function (e) {
+      return e.state === 'firing' && (!node || e.node === node);
+    });
+  }
+
+  function renderSummary() {
+    var online = 0, offline = 0;
+    sortedNodes().forEach(function (n) {
+      n.online ? online++ : offline++;
+    });
+    $('summary').textContent = '在线 ' + online + ' / 离线 ' + offline + ' / 事件 ' + firingEvents().length;
+  }
+
+  function renderOverview() {
+    var nodes = sortedNodes();
+    if (!nodes.length) {
+      $('grid').innerHTML = '<div class="empty">没有节点</div>';
+      return;
+    }
+    $('grid').innerHTML = nodes.map(function (n) {
+      var stats = (state.metrics[n.id] || {}).stats || n.stats || {};
+      var firing = firingEvents(n.id).length;
+      var cls = !n.online ? 'down' : firing ? 'warn' : '';
+      var bars = ['mem_used_percent', 'disk_used_percent'].filter(function (k) {
+        return stats[k] !== undefined;
+      }).map(function (k) {
+        return '<div class="meta">' + (k === 'mem_used_percent' ? '内存' : '磁盘') + ' ' + stats[k] + '%</div>' +
+          '<div class="bar"><span style="width:' + Math.min(stats[k], 100) + '%"></span></div>';
+      }).join('');
+      return '<a class="card ' + cls + '" href="#/node/' + encodeURIComponent(n.id) + '">' +
+        '<div class="name">' + esc(n.id) + '</div>' +
+        '<div class="meta">' + (n.online ? '在线' : '离线') + ' · ' + esc(n.addr) +
+        (n.latency >= 0 ? ' · ' + n.latency + 'ms' : '') + (firing ? ' · ' + firing + '个事件' : '') + '</div>' +
+        bars + '<div>' + labels(n.labels) + '</div></a>';
+    }).join('');
+  }
+
+  function renderNode(id) {
+    var n = state.nodes[id];
+    $('node-title').innerHTML = esc(id) + ' ' + (n ? '<span class="status ' + (n.online ? 'online">在线' : 'offline">离线') + '</span>' : '');
+    if (!n) {
+      $('node-info').innerHTML = '<tr><td>节点不存在或不在订阅范围内</td></tr>';
+      $('node-procs').innerHTML = '';
+      $('node-charts').innerHTML = '';
+      $('node-events').innerHTML = '';
+      return;
+    }
+    var info = [
+      ['地址', n.addr],
+      ['登录时间', fmtTime(n.loginTime)],
+      ['最后通信', fmtTime(n.lastSeen)],
+      ['延迟', n.latency >= 0 ? n.latency + 'ms' : '-']
+    ];
+    var stats = n.stats || {};
+    ['mem_total_bytes', 'mem_available_bytes', 'disk_total_bytes', 'disk_free_bytes'].forEach(function (k) {
+      if (stats[k] !== undefined) {
+        info.push([k, fmtBytes(stats[k])]);
+      }
+    });
+    Object.keys(n.inventory || {}).sort().forEach(function (k) {
+      info.push([k, n.inventory[k]]);
+    });
+    var custom = (state.metrics[id] || {}).custom || n.custom || {};
+    Object.keys(custom).sort().forEach(function (k) {
+      info.push([k, custom[k]]);
+    });
+    $('node-info').innerHTML = info.map(function (row) {
+      return '<tr><td>' + esc(row[0]) + '</td><td>' + esc(row[1]) + '</td></tr>';
+    }).join('') + '<tr><td>标签</td><td>' + labels(n.labels) + '</td></tr>';
+
+    var procs = Object.keys(n.procs || {}).sort();
+    $('node-procs').innerHTML = procs.length ? '<tr><th>进程</th><th>状态</th></tr>' + procs.map(function (p) {
+      return '<tr><td>' + esc(p) + '</td><td><span class="status ' + (n.procs[p] ? 'online">运行中' : 'offline">已停止') + '</span></td></tr>';
+    }).join('') : '<tr><td class="empty">没有监控的进程</td></tr>';
+
+    var history = state.history[id] || {};
+    $('node-charts').innerHTML = CHARTS.filter(function (c) {
+      return history[c.key];
+    }).map(function (c) {
+      return chart(c, history[c.key]);
+    }).join('') || '<div class="empty">等待指标数据</div>';
+
+    var events = Object.keys(state.events).map(function (k) {
+      return state.events[k];
+    }).filter(function (e) {
+      return e.node === id;
+    });
+    $('node-events').innerHTML = timeline(events);
+  }
+
+  // chart draws the points as a svg line
+  function chart(c, points) {
+    var w = 300, h = 80;
+    var values = points.map(function (p) {
+      return p[1];
+    });
+    var max = c.max || Math.max.apply(null, values.concat([1])) * 1.1;
+    var t0 = points[0][0], t1 = points[points.length - 1][0];
+    var line = points.map(function (p) {
+      var x = t1 === t0 ? w : (p[0] - t0) / (t1 - t0) * w;
+      var y = h - p[1] / max * h;
+      return x.toFixed(1) + ',' + y.toFixed(1);
+    }).join(' ');
+    return '<div class="chart"><div class="title"><span>' + esc(c.title) + '</span><span>' +
+      esc(values[values.length - 1] + c.unit) + '</span></div>' +
+      '<svg viewBox="0 0 ' + w + ' ' + h + '" preserveAspectRatio="none"><polyline points="' + line + '"/></svg></div>';
+  }
+
+  function timeline(events) {
+    events.sort(function (a, b) {
+      return new Date(b.lastSeen) - new Date(a.lastSeen);
+    });
+    if (!events.length) {
+      return '<li class="resolved">没有事件</li>';
+    }
+    return events.map(function (e) {
+      return '<li class="' + esc(e.state) + '"><div class="time">' + fmtTime(e.firstSeen) + ' ~ ' +
+        (e.state === 'resolved' ? fmtTime(e.resolvedAt) + ' 已恢复' : fmtTime(e.lastSeen)) + ' · ' + e.count + '次' +
+        (e.silenced ? ' · 已静默' : '') + (e.ackedBy ? ' · ' + esc(e.ackedBy) + '已确认' : '') + '</div>' +
+        '<div><a href="#/node/' + encodeURIComponent(e.node) + '">' + esc(e.node) + '</a> ' + esc(e.kind) + ' ' +
+        esc(e.subject) + '</div><div>' + esc(e.content) + '</div></li>';
+    }).join('');
+  }
+
+  function renderEvents() {
+    var onlyFiring = $('events-firing').checked;
+    var events = Object.keys(state.events).map(function (k) {
+      return state.events[k];
+    }).filter(function (e) {
+      return !onlyFiring || e.state === 'firing';
+    });
+    $('timeline').innerHTML = timeline(events);
+  }
+
+  function renderAlerts() {
+    var active = firingEvents();
+    active.sort(function (a, b) {
+      return new Date(a.firstSeen) - new Date(b.firstSeen);
+    });
+    $('active').innerHTML = active.length ? '<tr><th>开始时间</th><th>节点</th><th>类型</th><th>内容</th><th>次数</th><th>确认</th></tr>' +
+      active.map(function (e) {
+        var acked = e.ackedBy ? esc(e.ackedBy) + ' ' + fmtTime(e.ackedAt) :
+          canOperate() ? '<button class="ack" data-id="' + esc(e.id) + '">确认</button>' : '-';
+        return '<tr><td>' + fmtTime(e.firstSeen) + '</td><td><a href="#/node/' + encodeURIComponent(e.node) + '">' +
+          esc(e.node) + '</a></td><td>' + esc(e.kind) + '</td><td>' + esc(e.content) + '</td><td>' + e.count + '</td><td>' +
+          acked + '</td></tr>';
+      }).join('') : '<tr><td class="empty">没有正在发生的告警</td></tr>';
+
+    $('sent').innerHTML = state.alerts.length ? '<tr><th>时间</th><th>级别</th><th>节点</th><th>告警</th><th>接收</th></tr>' +
+      state.alerts.map(function (a) {
+        return '<tr><td>' + fmtTime(a.time) + '</td><td class="severity-' + esc(a.severity) + '">' + esc(a.severity) +
+          '</td><td>' + esc(a.nodeId) + '</td><td>' + esc(a.name) + '<div>' + esc(a.content) + '</div></td><td>' +
+          esc((a.receivers || []).join(', ')) + (a.error ? '<div class="severity-critical">' + esc(a.error) + '</div>' : '') +
+          '</td></tr>';
+      }).join('') : '<tr><td class="empty">没有告警</td></tr>';
+  }
+
+  // the filter is a label selector when it contains '=', otherwise node id patterns split by ','
+  function applyFilter() {
+    var value = $('filter').value.trim();
+    state.filter = value.indexOf('=') >= 0 ? {nodes: [], labels: value} :
+      {nodes: value ? value.split(',').map(function (s) {
+        return s.trim();
+      }) : [], labels: ''};
+    subscribe();
+  }
+
+  var filterTimer = null;
+  $('filter').addEventListener('input', function () {
+    clearTimeout(filterTimer);
+    filterTimer = setTimeout(applyFilter, 400);
+  });
+  $('events-firing').addEventListener('change', render);
+  $('login-form').addEventListener('submit', function (e) {
+    e.preventDefault();
+    var username = $('login-username').value.trim();
+    var done = function () {
+      $('login').hidden = true;
+      route();
+      connect();
+    };
+    if (!username) {
+      localStorage.setItem(TOKEN_KEY, $('login-token').value.trim());
+      done();
+      return;
+    }
+    fetch('/api/v1/login', {
+      method: 'POST',
+      headers: {'Content-Type': 'application/json'},
+      credentials: 'same-origin',
+      body: JSON.stringify({username: username, password: $('login-password').value})
+    }).then(function (resp) {
+      if (!resp.ok) {
+        $('login-error').textContent = '用户名或密码错误';
+        return;
+      }
+      localStorage.removeItem(TOKEN_KEY);
+      $('login-password').value = '';
+      done();
+    });
+  });
+  $('logout').addEventListener('click', function (e) {
+    e.preventDefault();
+    localStorage.removeItem(TOKEN_KEY);
+    fetch('/api/v1/logout', {method: 'POST', credentials: 'same-origin'}).then(function () {
+      location.reload();
+    });
+  });
+  $('active').addEventListener('click', function (e) {
+    if (e.target.className === 'ack') {
+      e.target.disabled = true;
+      ack(e.target.getAttribute('data-id'));
+    }
+  });
+  window.addEventListener('hashchange', route);
+  route();
+  connect();
+})();